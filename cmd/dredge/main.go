@@ -12,7 +12,10 @@ import (
 	"github.com/urfave/cli/v2"
 
 	"github.com/DeprecatedLuar/dredge-cargo/internal/commands"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/config"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/passgen"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/secmem"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/selfheal"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/session"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
@@ -40,6 +43,17 @@ func main() {
 				Usage:   "Password for decryption (skips prompt)",
 				EnvVars: []string{"DREDGE_PASSWORD"},
 			},
+			&cli.StringFlag{
+				Name:    "identity",
+				Aliases: []string{"i"},
+				Usage:   "Identity file for unlocking as a recipient (default ~/.config/dredge/identity)",
+				EnvVars: []string{crypto.IdentityEnvVar},
+			},
+			&cli.StringFlag{
+				Name:    "keyfile",
+				Usage:   "Keyfile for vaults that require one as a second factor",
+				EnvVars: []string{crypto.KeyfileEnvVar},
+			},
 			&cli.StringFlag{
 				Name:    "vault",
 				Usage:   "Vault directory to use for this command (does not persist)",
@@ -103,9 +117,10 @@ func main() {
 				Usage:   "View an item by ID",
 				Flags: []cli.Flag{
 					&cli.BoolFlag{Name: "raw", Aliases: []string{"r"}, Usage: "Output raw content only"},
+					&cli.BoolFlag{Name: "reveal", Usage: "Show secret field values"},
 				},
 				Action: func(c *cli.Context) error {
-					return commands.HandleView(c.Args().Slice(), c.Bool("raw"))
+					return commands.HandleView(c.Args().Slice(), commands.ViewOptions{Raw: c.Bool("raw"), Reveal: c.Bool("reveal")})
 				},
 			},
 			{
@@ -140,6 +155,27 @@ func main() {
 					return commands.HandleUndo(c.Args().Slice())
 				},
 			},
+			{
+				Name:  "history",
+				Usage: "List an item's earlier revisions",
+				Action: func(c *cli.Context) error {
+					return commands.HandleHistory(c.Args().Slice())
+				},
+			},
+			{
+				Name:  "diff",
+				Usage: "Show changes from a revision (the latest by default) to the current item",
+				Action: func(c *cli.Context) error {
+					return commands.HandleDiff(c.Args().Slice())
+				},
+			},
+			{
+				Name:  "revert",
+				Usage: "Restore an earlier revision of an item",
+				Action: func(c *cli.Context) error {
+					return commands.HandleRevert(c.Args().Slice())
+				},
+			},
 			{
 				Name:    "mv",
 				Aliases: []string{"rename", "rn"},
@@ -173,6 +209,29 @@ func main() {
 					return commands.HandleCopy(c.Args().Slice())
 				},
 			},
+			{
+				Name:                   "gen",
+				Usage:                  "Generate a password or passphrase",
+				SkipFlagParsing:        true,
+				UseShortOptionHandling: false,
+				Action: func(c *cli.Context) error {
+					return commands.HandleGen(c.Args().Slice())
+				},
+			},
+			{
+				Name:  "otp",
+				Usage: "Show the current TOTP code of an item",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "copy", Aliases: []string{"c"}, Usage: "Copy the code to the clipboard instead"},
+					&cli.BoolFlag{Name: "import", Usage: "Create items from otpauth:// URIs on stdin, one per line"},
+				},
+				Action: func(c *cli.Context) error {
+					if c.Bool("import") {
+						return commands.HandleOTPImport(os.Stdin)
+					}
+					return commands.HandleOTP(c.Args().Slice(), c.Bool("copy"))
+				},
+			},
 			{
 				Name:  "export",
 				Usage: "Export a binary item to filesystem",
@@ -180,12 +239,31 @@ func main() {
 					return commands.HandleExport(c.Args().Slice())
 				},
 			},
+			{
+				Name:  "lock-item",
+				Usage: "Double-lock an item's content under its own password",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "remove", Usage: "Take the double lock off again"},
+				},
+				Action: func(c *cli.Context) error {
+					return commands.HandleLockItem(c.Args().Slice(), c.Bool("remove"))
+				},
+			},
 			{
 				Name:    "init",
 				Aliases: []string{"use"},
 				Usage:   "Initialize or activate a vault at the given path (default: current dir)",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "calibrate", Usage: "Benchmark key derivation and set the password now"},
+					&cli.DurationFlag{Name: "unlock-time", Usage: "Target unlock time for --calibrate", Value: crypto.DefaultUnlockTime},
+					&cli.StringFlag{Name: "keyfile", Usage: "Require this keyfile alongside the password (created if missing) and set the password now"},
+				},
 				Action: func(c *cli.Context) error {
-					return commands.HandleInit(c.Args().Slice())
+					return commands.HandleInit(c.Args().Slice(), commands.InitOptions{
+						Calibrate:  c.Bool("calibrate"),
+						UnlockTime: c.Duration("unlock-time"),
+						Keyfile:    c.String("keyfile"),
+					})
 				},
 			},
 			{
@@ -211,7 +289,7 @@ func main() {
 			},
 			{
 				Name:  "sync",
-				Usage: "Sync with remote (pull + push)",
+				Usage: "Sync with remote (pull, verify, push)",
 				Action: func(c *cli.Context) error {
 					return commands.HandleSync(c.Args().Slice())
 				},
@@ -223,18 +301,177 @@ func main() {
 					return commands.HandleStatus(c.Args().Slice())
 				},
 			},
+			{
+				Name:  "unlock",
+				Usage: "Unlock the vault for this terminal",
+				Flags: []cli.Flag{
+					&cli.DurationFlag{Name: "for", Usage: "Keep it unlocked this long, used or not (instead of the idle timeout)"},
+				},
+				Action: func(c *cli.Context) error {
+					return commands.HandleUnlock(c.Duration("for"))
+				},
+			},
 			{
 				Name:  "lock",
 				Usage: "Lock the vault (clears cached session key)",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "all", Usage: "Lock every vault in every terminal, including the agent's keys"},
+				},
 				Action: func(c *cli.Context) error {
-					return commands.HandleLock()
+					return commands.HandleLock(c.Bool("all"))
+				},
+			},
+			{
+				Name:  "agent",
+				Usage: "Run the key agent (keeps unlocked keys in memory instead of a file)",
+				Flags: []cli.Flag{
+					&cli.DurationFlag{Name: "timeout", Usage: "Maximum key lifetime, whatever the session asks for (0 = no cap)"},
+				},
+				Action: func(c *cli.Context) error {
+					return commands.HandleAgent(c.Duration("timeout"))
+				},
+			},
+			{
+				Name:  "rewrite",
+				Usage: "Re-encrypt every item with the vault's current settings (padding, compression)",
+				Action: func(c *cli.Context) error {
+					return commands.HandleRewrite(c.Args().Slice())
+				},
+			},
+			{
+				Name:  "fsck",
+				Usage: "Check the vault's integrity",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "repair", Usage: "Fix what can be fixed safely"},
+				},
+				Action: func(c *cli.Context) error {
+					return commands.HandleFsck(c.Bool("repair"))
+				},
+			},
+			{
+				Name:  "rekey",
+				Usage: "Re-encrypt the vault under a new data key (keeps passwords)",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "drop-recovery", Usage: "Remove recovery key slots instead of asking for their phrases"},
+				},
+				Action: func(c *cli.Context) error {
+					return commands.HandleRekey(c.Bool("drop-recovery"))
 				},
 			},
 			{
 				Name:  "passwd",
-				Usage: "Change vault password",
+				Usage: "Change vault password or key derivation cost",
+				Flags: []cli.Flag{
+					&cli.BoolFlag{Name: "calibrate", Usage: "Benchmark key derivation for this machine (keeps password)"},
+					&cli.DurationFlag{Name: "unlock-time", Usage: "Target unlock time for --calibrate", Value: crypto.DefaultUnlockTime},
+					&cli.UintFlag{Name: "kdf-time", Usage: "Argon2id iterations (keeps password)"},
+					&cli.UintFlag{Name: "kdf-memory", Usage: "Argon2id memory in MiB (keeps password)"},
+					&cli.UintFlag{Name: "kdf-threads", Usage: "Argon2id threads (keeps password)"},
+					&cli.StringFlag{Name: "add-keyfile", Usage: "Require this keyfile alongside the password (created if missing; keeps password)"},
+				},
+				Action: func(c *cli.Context) error {
+					return commands.HandlePasswd(commands.PasswdOptions{
+						Calibrate:  c.Bool("calibrate"),
+						UnlockTime: c.Duration("unlock-time"),
+						KDFTime:    uint32(c.Uint("kdf-time")),
+						KDFMemory:  uint32(c.Uint("kdf-memory")),
+						KDFThreads: uint8(c.Uint("kdf-threads")),
+						AddKeyfile: c.String("add-keyfile"),
+					})
+				},
+			},
+			{
+				Name:  "key",
+				Usage: "Manage vault key slots (one password per person or device)",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "add",
+						Usage: "Add a password slot: dredge key add <label>",
+						Action: func(c *cli.Context) error {
+							return commands.HandleKeyAdd(c.Args().Slice())
+						},
+					},
+					{
+						Name:    "list",
+						Aliases: []string{"ls"},
+						Usage:   "List key slots",
+						Action: func(c *cli.Context) error {
+							return commands.HandleKeyList()
+						},
+					},
+					{
+						Name:    "remove",
+						Aliases: []string{"rm"},
+						Usage:   "Remove a key slot: dredge key remove <label>",
+						Action: func(c *cli.Context) error {
+							return commands.HandleKeyRemove(c.Args().Slice())
+						},
+					},
+				},
+			},
+			{
+				Name:  "recovery",
+				Usage: "Create or use an offline recovery key",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "create",
+						Usage: "Generate a recovery key and print it as 24 words",
+						Action: func(c *cli.Context) error {
+							return commands.HandleRecoveryCreate(c.Args().Slice())
+						},
+					},
+					{
+						Name:  "unlock",
+						Usage: "Unlock with the recovery words and set a new password",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "slot", Usage: "Password slot to set", Value: crypto.DefaultSlotLabel},
+						},
+						Action: func(c *cli.Context) error {
+							return commands.HandleRecoveryUnlock(c.String("slot"))
+						},
+					},
+				},
+			},
+			{
+				Name:  "keygen",
+				Usage: "Create a personal identity file and print its public key",
+				Flags: []cli.Flag{
+					&cli.StringFlag{Name: "output", Aliases: []string{"o"}, Usage: "Identity file path (default ~/.config/dredge/identity)"},
+				},
 				Action: func(c *cli.Context) error {
-					return commands.HandlePasswd()
+					return commands.HandleKeygen(c.String("output"))
+				},
+			},
+			{
+				Name:  "recipients",
+				Usage: "Manage teammates' public keys that can unlock the vault",
+				Subcommands: []*cli.Command{
+					{
+						Name:  "add",
+						Usage: "Wrap the vault key for a public key: dredge recipients add <dredge1...>",
+						Flags: []cli.Flag{
+							&cli.StringFlag{Name: "label", Usage: "Name for this recipient's key slot"},
+						},
+						Action: func(c *cli.Context) error {
+							return commands.HandleRecipientsAdd(c.Args().Slice(), c.String("label"))
+						},
+					},
+					{
+						Name:    "list",
+						Aliases: []string{"ls"},
+						Usage:   "List recipients",
+						Action: func(c *cli.Context) error {
+							return commands.HandleRecipientsList()
+						},
+					},
+					{
+						Name:    "remove",
+						Aliases: []string{"rm"},
+						Usage:   "Remove a recipient by label or public key",
+						Action: func(c *cli.Context) error {
+							return commands.HandleRecipientsRemove(c.Args().Slice())
+						},
+					},
 				},
 			},
 			{
@@ -267,13 +504,35 @@ func main() {
 				session.SetVaultPath(vaultDir)
 			}
 
+			cfg, err := config.Load(session.GetVaultPath())
+			if err != nil {
+				return err
+			}
+
+			// Keys and decrypted items must not end up in a core dump
+			secmem.DebugMode = debugMode
+			if err := secmem.DisableCoreDumps(); err != nil {
+				Debugf("%v", err)
+			}
+
 			// Set debug mode for crypto package
 			crypto.DebugMode = debugMode
 			crypto.NoLock = noLock
+			crypto.OnUnlock = selfheal.RunUnlocked
+			crypto.IdentityPath = c.String("identity")
+			crypto.KeyfilePath = c.String("keyfile")
+			crypto.SessionConfig = cfg.Session
+			storage.Padding = cfg.Vault.Padding
+			storage.Compression = cfg.Vault.Compression
+			storage.HistoryLimit = cfg.Vault.History
+			passgen.Default = cfg.Vault.Gen
 
 			// Check if this is a new session (no cached password)
 			isNewSession := !crypto.HasActiveSession()
 
+			// Key unlocked by --password (if any), for key-dependent self-healing below
+			var unlockedKey []byte
+
 			// If password provided via --password flag or DREDGE_PASSWORD env var,
 			// verify immediately and hard-error on failure — never fall back to prompt.
 			// If vault doesn't exist yet, store as pending (used once by GetKeyWithVerification).
@@ -288,6 +547,7 @@ func main() {
 						return fmt.Errorf("failed to cache key: %w", err)
 					}
 					Debugf("Key derived and cached from --password/DREDGE_PASSWORD")
+					unlockedKey = key
 					isNewSession = true
 				} else {
 					// First-time vault — store pending, GetKeyWithVerification will use it
@@ -301,7 +561,7 @@ func main() {
 			sub := c.Args().First()
 
 			// Commands that don't need vault access
-			passiveCommands := []string{"", "help", "h", "update", "up", "init", "use", "lock"}
+			passiveCommands := []string{"", "help", "h", "update", "up", "init", "use", "lock", "keygen", "agent"}
 
 			contains := func(list []string, s string) bool {
 				for _, v := range list {
@@ -317,6 +577,9 @@ func main() {
 			// Run self-healing on new session (skip for passive commands — no vault access needed)
 			if isNewSession && !isPassiveCommand {
 				selfheal.Run()
+				if unlockedKey != nil {
+					selfheal.RunUnlocked(unlockedKey)
+				}
 			}
 
 			// Ensure vault is initialized
@@ -342,13 +605,13 @@ func main() {
 			if len(args) == 1 {
 				if num, err := strconv.Atoi(firstArg); err == nil && num > 0 {
 					if id, cacheErr := session.GetCachedResult(num); cacheErr == nil {
-						return commands.HandleView([]string{id})
+						return commands.HandleView([]string{id}, commands.ViewOptions{})
 					}
 					// If cache miss, fall through to try as ID/search
 				}
 
 				// Try as direct ID
-				if viewErr := commands.HandleView([]string{firstArg}); viewErr == nil {
+				if viewErr := commands.HandleView([]string{firstArg}, commands.ViewOptions{}); viewErr == nil {
 					return nil
 				} else {
 					Debugf("HandleView failed, falling back to search: %v", viewErr)
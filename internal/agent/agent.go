@@ -0,0 +1,163 @@
+// Package agent implements `dredge agent`, a per-user daemon that keeps unlocked vault
+// keys in memory and hands them out over a unix socket, instead of the key file under
+// the session directory.
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"path/filepath"
+	"time"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/session"
+)
+
+// ============================================================================
+// Protocol
+// ============================================================================
+//
+// One JSON request and one JSON response per connection. Keys are scoped like the
+// file cache: by vault and by the terminal (parent process) of the dredge process
+// that connects. The agent reads the caller's PID from the socket's peer credentials
+// and derives the terminal from it, so clients cannot ask for another session's key.
+
+const (
+	socketName  = "agent.sock"
+	dialTimeout = 500 * time.Millisecond
+	ioTimeout   = 5 * time.Second
+)
+
+// Request operations
+const (
+	opPing    = "ping"
+	opGet     = "get"
+	opPeek    = "peek"
+	opPut     = "put"
+	opLock    = "lock"
+	opLockAll = "lock-all"
+)
+
+// ErrNotRunning is returned by client calls when no agent is listening.
+var ErrNotRunning = errors.New("dredge agent is not running")
+
+type request struct {
+	Op       string `json:"op"`
+	Vault    string `json:"vault,omitempty"` // vault identifier (hash of its path)
+	Key      []byte `json:"key,omitempty"`
+	Idle     int64  `json:"idle,omitempty"`     // seconds without use before the key expires; 0 = never
+	Deadline int64  `json:"deadline,omitempty"` // unix seconds when the key expires regardless; 0 = never
+}
+
+type response struct {
+	OK       bool   `json:"ok"`
+	Error    string `json:"error,omitempty"`
+	Found    bool   `json:"found,omitempty"`
+	Key      []byte `json:"key,omitempty"`
+	Expires  int64  `json:"expires,omitempty"`  // unix seconds; 0 = never
+	Deadline int64  `json:"deadline,omitempty"` // unix seconds; 0 = never
+	Count    int    `json:"count,omitempty"`
+}
+
+// Session is a key held by the agent and when it expires.
+type Session struct {
+	Key      []byte    // nil from Peek
+	Expires  time.Time // when the key expires unless used again; zero = never
+	Deadline time.Time // when the key expires however often it is used; zero = never
+}
+
+// SocketPath returns the per-user agent socket: $XDG_RUNTIME_DIR/dredge/agent.sock
+func SocketPath() string {
+	return filepath.Join(session.BaseDir(), socketName)
+}
+
+// ============================================================================
+// Client
+// ============================================================================
+
+// call sends req to the running agent and returns its response.
+func call(req request) (*response, error) {
+	conn, err := net.DialTimeout("unix", SocketPath(), dialTimeout)
+	if err != nil {
+		return nil, ErrNotRunning
+	}
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(ioTimeout))
+
+	if err := json.NewEncoder(conn).Encode(req); err != nil {
+		return nil, fmt.Errorf("failed to talk to agent: %w", err)
+	}
+
+	var resp response
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("failed to read agent response: %w", err)
+	}
+	if !resp.OK {
+		return nil, fmt.Errorf("agent: %s", resp.Error)
+	}
+	return &resp, nil
+}
+
+// Running reports whether an agent is listening on SocketPath.
+func Running() bool {
+	_, err := call(request{Op: opPing})
+	return err == nil
+}
+
+// Get returns the session the agent holds for vault in this terminal, or nil if it
+// has none. Getting a key counts as a use and restarts its idle timeout.
+func Get(vault string) (*Session, error) {
+	return getSession(opGet, vault)
+}
+
+// Peek is Get without the key, and without counting as a use.
+func Peek(vault string) (*Session, error) {
+	return getSession(opPeek, vault)
+}
+
+func getSession(op, vault string) (*Session, error) {
+	resp, err := call(request{Op: op, Vault: vault})
+	if err != nil {
+		return nil, err
+	}
+	if !resp.Found {
+		return nil, nil
+	}
+	return &Session{Key: resp.Key, Expires: unixTime(resp.Expires), Deadline: unixTime(resp.Deadline)}, nil
+}
+
+// Put hands key for vault to the agent. It expires after idle without use and at
+// deadline regardless (0 and the zero time for never); the agent may cap both with
+// its own timeout.
+func Put(vault string, key []byte, idle time.Duration, deadline time.Time) error {
+	req := request{Op: opPut, Vault: vault, Key: key, Idle: int64(idle / time.Second)}
+	if !deadline.IsZero() {
+		req.Deadline = deadline.Unix()
+	}
+	_, err := call(req)
+	return err
+}
+
+// Lock drops the key for vault in this terminal session.
+func Lock(vault string) error {
+	_, err := call(request{Op: opLock, Vault: vault})
+	return err
+}
+
+// LockAll drops every key the agent holds and returns how many were dropped.
+func LockAll() (int, error) {
+	resp, err := call(request{Op: opLockAll})
+	if err != nil {
+		return 0, err
+	}
+	return resp.Count, nil
+}
+
+// unixTime converts unix seconds to a time; 0 is the zero time.
+func unixTime(sec int64) time.Time {
+	if sec == 0 {
+		return time.Time{}
+	}
+	return time.Unix(sec, 0)
+}
@@ -0,0 +1,44 @@
+//go:build darwin
+
+package agent
+
+import (
+	"net"
+
+	"golang.org/x/sys/unix"
+)
+
+// peerCred returns the uid (LOCAL_PEERCRED) and pid (LOCAL_PEERPID) of the process on
+// the other end of conn.
+func peerCred(conn *net.UnixConn) (int, int, error) {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0, 0, err
+	}
+
+	var uid, pid int
+	var credErr error
+	if err := raw.Control(func(fd uintptr) {
+		var cred *unix.Xucred
+		if cred, credErr = unix.GetsockoptXucred(int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERCRED); credErr != nil {
+			return
+		}
+		uid = int(cred.Uid)
+		pid, credErr = unix.GetsockoptInt(int(fd), unix.SOL_LOCAL, unix.LOCAL_PEERPID)
+	}); err != nil {
+		return 0, 0, err
+	}
+	if credErr != nil {
+		return 0, 0, credErr
+	}
+	return uid, pid, nil
+}
+
+// parentPID looks up the parent of pid with sysctl kern.proc.pid.
+func parentPID(pid int) (int, error) {
+	kp, err := unix.SysctlKinfoProc("kern.proc.pid", pid)
+	if err != nil {
+		return 0, err
+	}
+	return int(kp.Eproc.Ppid), nil
+}
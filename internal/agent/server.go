@@ -0,0 +1,252 @@
+package agent
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/secmem"
+)
+
+// ============================================================================
+// Server
+// ============================================================================
+
+// sweepInterval is how often expired keys are wiped from memory.
+const sweepInterval = time.Second
+
+// scope identifies one cached key: a vault unlocked from one terminal.
+type scope struct {
+	ppid  int
+	vault string
+}
+
+type entry struct {
+	key      *secmem.Buffer // locked into memory, see secmem
+	idle     time.Duration  // zero = no idle timeout
+	deadline time.Time      // zero = never
+	lastUse  time.Time
+}
+
+// Server holds unlocked keys in memory and answers client requests.
+type Server struct {
+	// Timeout caps the lifetime of every key, whatever clients ask for. 0 = no cap.
+	Timeout time.Duration
+
+	mu   sync.Mutex
+	keys map[scope]*entry
+	uid  int
+}
+
+// NewServer returns an agent server that expires keys after at most timeout.
+func NewServer(timeout time.Duration) *Server {
+	return &Server{
+		Timeout: timeout,
+		keys:    make(map[scope]*entry),
+		uid:     os.Getuid(),
+	}
+}
+
+// Listen creates the agent socket (mode 0600 in a 0700 directory). A stale socket
+// left by a crashed agent is replaced; a live one is an error.
+func Listen() (net.Listener, error) {
+	path := SocketPath()
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create runtime directory: %w", err)
+	}
+
+	if Running() {
+		return nil, fmt.Errorf("an agent is already listening on %s", path)
+	}
+	_ = os.Remove(path)
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to listen on %s: %w", path, err)
+	}
+	if err := os.Chmod(path, 0600); err != nil {
+		ln.Close()
+		return nil, fmt.Errorf("failed to restrict agent socket: %w", err)
+	}
+	return ln, nil
+}
+
+// Serve accepts connections until ln is closed. Expired keys are wiped in the background.
+func (s *Server) Serve(ln net.Listener) error {
+	done := make(chan struct{})
+	defer close(done)
+	go s.sweep(done)
+
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return nil
+			}
+			return err
+		}
+		go s.handle(conn)
+	}
+}
+
+// Wipe zeroes and drops every key. Called on shutdown.
+func (s *Server) Wipe() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	n := len(s.keys)
+	for sc, e := range s.keys {
+		e.key.Destroy()
+		delete(s.keys, sc)
+	}
+	return n
+}
+
+// sweep periodically wipes expired keys, so they leave memory on time even if nobody asks.
+func (s *Server) sweep(done <-chan struct{}) {
+	ticker := time.NewTicker(sweepInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case now := <-ticker.C:
+			s.mu.Lock()
+			for sc, e := range s.keys {
+				if e.expired(now) {
+					e.key.Destroy()
+					delete(s.keys, sc)
+				}
+			}
+			s.mu.Unlock()
+		}
+	}
+}
+
+// expires returns when the key expires unless used again (zero = never).
+func (e *entry) expires() time.Time {
+	var t time.Time
+	if e.idle > 0 {
+		t = e.lastUse.Add(e.idle)
+	}
+	if !e.deadline.IsZero() && (t.IsZero() || e.deadline.Before(t)) {
+		t = e.deadline
+	}
+	return t
+}
+
+func (e *entry) expired(now time.Time) bool {
+	expires := e.expires()
+	return !expires.IsZero() && now.After(expires)
+}
+
+// handle serves one request. Connections from other users are dropped without a reply.
+func (s *Server) handle(conn net.Conn) {
+	defer conn.Close()
+	_ = conn.SetDeadline(time.Now().Add(ioTimeout))
+
+	uc, ok := conn.(*net.UnixConn)
+	if !ok {
+		return
+	}
+	uid, pid, err := peerCred(uc)
+	if err != nil || uid != s.uid {
+		return
+	}
+
+	var req request
+	if err := json.NewDecoder(conn).Decode(&req); err != nil {
+		return
+	}
+
+	resp := s.dispatch(req, pid)
+	_ = json.NewEncoder(conn).Encode(resp)
+}
+
+// dispatch executes req for the client process pid.
+func (s *Server) dispatch(req request, pid int) response {
+	if req.Op == opPing {
+		return response{OK: true}
+	}
+	if req.Op == opLockAll {
+		return response{OK: true, Count: s.Wipe()}
+	}
+
+	// Keys belong to the caller's terminal, i.e. the parent of the dredge process
+	ppid, err := parentPID(pid)
+	if err != nil {
+		return response{Error: "cannot identify client session"}
+	}
+	sc := scope{ppid: ppid, vault: req.Vault}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	switch req.Op {
+	case opGet, opPeek:
+		e, ok := s.keys[sc]
+		if !ok {
+			return response{OK: true}
+		}
+		now := time.Now()
+		if e.expired(now) {
+			e.key.Destroy()
+			delete(s.keys, sc)
+			return response{OK: true}
+		}
+		resp := response{OK: true, Found: true}
+		if req.Op == opGet {
+			e.lastUse = now
+			resp.Key = e.key.Bytes()
+		}
+		if expires := e.expires(); !expires.IsZero() {
+			resp.Expires = expires.Unix()
+		}
+		if !e.deadline.IsZero() {
+			resp.Deadline = e.deadline.Unix()
+		}
+		return resp
+
+	case opPut:
+		if len(req.Key) == 0 {
+			return response{Error: "empty key"}
+		}
+		if old, ok := s.keys[sc]; ok {
+			old.key.Destroy()
+		}
+		now := time.Now()
+		s.keys[sc] = &entry{
+			key:      secmem.From(req.Key),
+			idle:     time.Duration(req.Idle) * time.Second,
+			deadline: s.capDeadline(now, unixTime(req.Deadline)),
+			lastUse:  now,
+		}
+		return response{OK: true}
+
+	case opLock:
+		if e, ok := s.keys[sc]; ok {
+			e.key.Destroy()
+			delete(s.keys, sc)
+		}
+		return response{OK: true}
+	}
+
+	return response{Error: fmt.Sprintf("unknown operation %q", req.Op)}
+}
+
+// capDeadline returns the deadline for a key stored at now: the requested one, capped by Timeout.
+func (s *Server) capDeadline(now, deadline time.Time) time.Time {
+	if s.Timeout <= 0 {
+		return deadline
+	}
+	limit := now.Add(s.Timeout)
+	if deadline.IsZero() || deadline.After(limit) {
+		return limit
+	}
+	return deadline
+}
@@ -0,0 +1,137 @@
+package agent
+
+import (
+	"bytes"
+	"testing"
+	"time"
+)
+
+// startAgent runs an agent on a socket under a temporary runtime directory.
+func startAgent(t *testing.T, timeout time.Duration) *Server {
+	t.Helper()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	ln, err := Listen()
+	if err != nil {
+		t.Fatalf("Listen failed: %v", err)
+	}
+	server := NewServer(timeout)
+	go server.Serve(ln)
+	t.Cleanup(func() { ln.Close() })
+	return server
+}
+
+func TestAgent_NotRunning(t *testing.T) {
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+
+	if Running() {
+		t.Fatal("Running should be false without an agent")
+	}
+	if _, err := Get("vault"); err != ErrNotRunning {
+		t.Errorf("Get = %v, want ErrNotRunning", err)
+	}
+}
+
+func TestAgent_PutGetLock(t *testing.T) {
+	startAgent(t, 0)
+	key := bytes.Repeat([]byte{7}, 32)
+
+	if _, err := Listen(); err == nil {
+		t.Error("a second agent should refuse to start")
+	}
+
+	if got, err := Get("vault"); err != nil || got != nil {
+		t.Fatalf("Get before Put = %v, %v; want nil, nil", got, err)
+	}
+	if err := Put("vault", key, time.Minute, time.Time{}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	got, err := Get("vault")
+	if err != nil || got == nil || !bytes.Equal(got.Key, key) {
+		t.Fatalf("Get = %v, %v; want the stored key", got, err)
+	}
+	if remaining := time.Until(got.Expires); remaining <= 0 || remaining > time.Minute {
+		t.Errorf("key expires in %s, want about a minute", remaining)
+	}
+	if !got.Deadline.IsZero() {
+		t.Errorf("Deadline = %v, want none", got.Deadline)
+	}
+
+	// Keys are scoped per vault
+	if other, _ := Get("other"); other != nil {
+		t.Error("Get should not return a key stored for another vault")
+	}
+
+	if err := Lock("vault"); err != nil {
+		t.Fatalf("Lock failed: %v", err)
+	}
+	if got, _ := Get("vault"); got != nil {
+		t.Error("key should be gone after Lock")
+	}
+}
+
+func TestAgent_IdleTimeoutSlides(t *testing.T) {
+	startAgent(t, 0)
+	key := bytes.Repeat([]byte{7}, 32)
+
+	if err := Put("vault", key, 2*time.Second, time.Time{}); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+
+	// Each Get restarts the idle timeout
+	for range 3 {
+		time.Sleep(time.Second)
+		if got, _ := Get("vault"); got == nil {
+			t.Fatal("key should stay while it is being used")
+		}
+	}
+
+	// Peek does not count as a use
+	time.Sleep(time.Second)
+	if got, _ := Peek("vault"); got == nil || got.Key != nil {
+		t.Fatalf("Peek = %v, want a session without its key", got)
+	}
+	time.Sleep(1100 * time.Millisecond)
+	if got, _ := Get("vault"); got != nil {
+		t.Error("key should have expired after the idle timeout")
+	}
+}
+
+func TestAgent_TimeoutCapsDeadline(t *testing.T) {
+	startAgent(t, time.Second)
+	key := bytes.Repeat([]byte{7}, 32)
+
+	// No limit requested: the agent's own timeout still applies
+	if err := Put("vault", key, 0, time.Now().Add(time.Hour)); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	got, _ := Get("vault")
+	if got == nil || got.Deadline.IsZero() || time.Until(got.Deadline) > time.Second {
+		t.Fatalf("Get = %v, want the deadline capped at the agent timeout", got)
+	}
+
+	time.Sleep(1100 * time.Millisecond)
+	if got, _ := Get("vault"); got != nil {
+		t.Error("key should have expired")
+	}
+}
+
+func TestAgent_LockAll(t *testing.T) {
+	startAgent(t, 0)
+	key := bytes.Repeat([]byte{7}, 32)
+
+	_ = Put("a", key, 0, time.Time{})
+	_ = Put("b", key, 0, time.Time{})
+
+	n, err := LockAll()
+	if err != nil {
+		t.Fatalf("LockAll failed: %v", err)
+	}
+	if n != 2 {
+		t.Errorf("LockAll dropped %d keys, want 2", n)
+	}
+	if got, _ := Get("a"); got != nil {
+		t.Error("keys should be gone after LockAll")
+	}
+}
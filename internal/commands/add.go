@@ -33,6 +33,10 @@ func warnIfUnpushed() {
 const (
 	idLength   = 3
 	maxRetries = 10
+
+	// maxTextFileSize caps files imported as text items (read into memory and stored in
+	// the item TOML); anything larger is streamed into storage/ as a binary blob.
+	maxTextFileSize = 8 << 20
 )
 
 // isTextContent checks if content is text (valid UTF-8, no null bytes)
@@ -49,6 +53,25 @@ func generateID() (string, error) {
 	return id[:idLength], nil
 }
 
+// newItemID returns a random ID no item uses yet.
+func newItemID() (string, error) {
+	for i := 0; i < maxRetries; i++ {
+		id, err := generateID()
+		if err != nil {
+			return "", fmt.Errorf("failed to generate ID: %w", err)
+		}
+
+		exists, err := storage.ItemExists(id)
+		if err != nil {
+			return "", fmt.Errorf("failed to check item existence: %w", err)
+		}
+		if !exists {
+			return id, nil
+		}
+	}
+	return "", fmt.Errorf("failed to generate unique ID after %d attempts", maxRetries)
+}
+
 // parseAddArgs manually parses args to extract title, content, tags, and file path
 // Supports flexible flag ordering: title can come first, -c, -t, and --file can be in any order
 func parseAddArgs(args []string) (title, content, filePath string, tags []string) {
@@ -136,6 +159,18 @@ func parseAddArgs(args []string) (title, content, filePath string, tags []string
 	return title, content, filePath, tags
 }
 
+// contentEnd returns where the -c content starting after args[c] ends: at the next -t
+// or --file, or the end. Anything else inside it, flags included, is content.
+func contentEnd(args []string, c int) int {
+	for i := c + 1; i < len(args); i++ {
+		switch args[i] {
+		case "-t", "--file", "--import":
+			return i
+		}
+	}
+	return len(args)
+}
+
 func handleAddFile(args []string, filePath string) error {
 	// Parse title and tags from args (ignore -c content flag for files)
 	title, _, _, tags := parseAddArgs(args)
@@ -146,10 +181,13 @@ func handleAddFile(args []string, filePath string) error {
 		return fmt.Errorf("failed to read file: %w", err)
 	}
 
-	// Read file content
-	fileBytes, err := os.ReadFile(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to read file: %w", err)
+	// Read file content (small files only; large ones are streamed below)
+	var fileBytes []byte
+	if fileInfo.Size() <= maxTextFileSize {
+		fileBytes, err = os.ReadFile(filePath)
+		if err != nil {
+			return fmt.Errorf("failed to read file: %w", err)
+		}
 	}
 
 	// Get filename, size, and permissions
@@ -164,7 +202,7 @@ func handleAddFile(args []string, filePath string) error {
 
 	// Detect if content is text or binary
 	var item *storage.Item
-	if isTextContent(fileBytes) {
+	if fileBytes != nil && isTextContent(fileBytes) {
 		// Text file: store as TypeText with plain content
 		item = &storage.Item{
 			Title:    title,
@@ -184,24 +222,9 @@ func handleAddFile(args []string, filePath string) error {
 	}
 
 	// Generate unique ID
-	var id string
-	for i := 0; i < maxRetries; i++ {
-		id, err = generateID()
-		if err != nil {
-			return fmt.Errorf("failed to generate ID: %w", err)
-		}
-
-		exists, err := storage.ItemExists(id)
-		if err != nil {
-			return fmt.Errorf("failed to check item existence: %w", err)
-		}
-		if !exists {
-			break
-		}
-
-		if i == maxRetries-1 {
-			return fmt.Errorf("failed to generate unique ID after %d attempts", maxRetries)
-		}
+	id, err := newItemID()
+	if err != nil {
+		return err
 	}
 
 	// Get master key
@@ -214,12 +237,12 @@ func handleAddFile(args []string, filePath string) error {
 		return fmt.Errorf("failed to create item: %w", err)
 	}
 
-	// For binary items, write the encrypted blob to storage/
+	// For binary items, stream the encrypted blob to storage/
 	if item.Type == storage.TypeBinary {
-		if err := storage.WriteStorageBlob(id, fileBytes, key); err != nil {
+		if err := writeBlobFromFile(id, filePath, fileSize, key); err != nil {
 			// Roll back the metadata item on failure
-			_ = storage.DeleteItem(id)
-			return fmt.Errorf("failed to write binary blob: %w", err)
+			_ = storage.DeleteItem(id, key)
+			return err
 		}
 	}
 
@@ -232,10 +255,50 @@ func handleAddFile(args []string, filePath string) error {
 	return nil
 }
 
+// writeBlobFromFile streams the file at path into storage/id.
+// Fails if the file changed size since it was inspected, as the item records the size.
+func writeBlobFromFile(id, path string, size int64, key []byte) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read file: %w", err)
+	}
+	defer f.Close()
+
+	n, err := storage.WriteStorageBlobFrom(id, f, key)
+	if err != nil {
+		return fmt.Errorf("failed to write binary blob: %w", err)
+	}
+	if n != size {
+		_ = storage.DeleteStorageBlob(id, key)
+		return fmt.Errorf("file changed while adding it (expected %d bytes, read %d)", size, n)
+	}
+	return nil
+}
+
 func HandleAdd(args []string, _ string) error {
+	// Generator and template flags can go anywhere outside the -c content; take them out first
+	args, gen, err := parseGenArgs(args)
+	if err != nil {
+		return err
+	}
+	if gen.used && !gen.enabled {
+		return fmt.Errorf("generator flags (--words, --copy...) need --gen")
+	}
+	args, templateName, err := takeTemplateArg(args)
+	if err != nil {
+		return err
+	}
+
 	// Parse args (empty args returns empty title/content/tags/filePath)
 	title, content, filePath, tags := parseAddArgs(args)
 
+	if gen.enabled && filePath != "" {
+		return fmt.Errorf("--gen creates a text item; it cannot be used with --file")
+	}
+	if templateName != "" && filePath != "" {
+		return fmt.Errorf("--template creates a text item; it cannot be used with --file")
+	}
+
 	// If --file flag provided, handle binary item
 	if filePath != "" {
 		if err := handleAddFile(args, filePath); err != nil {
@@ -251,48 +314,69 @@ func HandleAdd(args []string, _ string) error {
 		return fmt.Errorf("failed to get key: %w", err)
 	}
 
+	// A template adds its tags, fields and notes (-c content replaces the notes)
+	var fields []storage.Field
+	notes := content
+	field := genField
+	if templateName != "" {
+		tmpl, err := loadTemplate(templateName, key)
+		if err != nil {
+			return err
+		}
+		var templateNotes string
+		tags, fields, templateNotes = tmpl.Apply(tags)
+		if notes == "" {
+			notes = templateNotes
+		}
+		field = templateGenField(fields)
+	}
+
 	var item *storage.Item
+	var secret string
 
-	// If no content provided, open editor (includes empty args case)
-	if content == "" {
-		item, err = editor.OpenForNewItem(title, tags)
+	if gen.enabled {
+		// Generated secret goes straight into a field, never through the terminal
+		if title == "" {
+			return fmt.Errorf("title cannot be empty")
+		}
+		item = storage.NewTextItem(title, notes, tags)
+		item.Content.Fields = fields
+		if secret, err = setGenerated(item, field, gen.policy); err != nil {
+			return err
+		}
+	} else if content == "" {
+		// If no content provided, open editor (includes empty args case)
+		item, err = editor.OpenForNewItem(title, tags, fields, notes)
 		if err != nil {
 			return fmt.Errorf("failed to create item via editor: %w", err)
 		}
+		if templateName != "" {
+			item.Content.Fields = dropEmptyFields(item.Content.Fields)
+		}
 	} else {
 		// Create item directly from CLI args
 		if title == "" {
 			return fmt.Errorf("title cannot be empty")
 		}
-		item = storage.NewTextItem(title, content, tags)
+		item = storage.NewTextItem(title, notes, tags)
+		item.Content.Fields = fields
 	}
 
 	// Generate unique ID
-	var id string
-	for i := 0; i < maxRetries; i++ {
-		id, err = generateID()
-		if err != nil {
-			return fmt.Errorf("failed to generate ID: %w", err)
-		}
-
-		exists, err := storage.ItemExists(id)
-		if err != nil {
-			return fmt.Errorf("failed to check item existence: %w", err)
-		}
-		if !exists {
-			break
-		}
-
-		if i == maxRetries-1 {
-			return fmt.Errorf("failed to generate unique ID after %d attempts", maxRetries)
-		}
+	id, err := newItemID()
+	if err != nil {
+		return err
 	}
 
 	if err := storage.CreateItem(id, item, key); err != nil {
 		return fmt.Errorf("failed to create item: %w", err)
 	}
 
-	fmt.Println("+ " + ui.FormatItem(id, item.Title, item.Tags, "it#"))
+	if gen.enabled {
+		fmt.Printf("+ %s — %s\n", ui.FormatItem(id, item.Title, item.Tags, "it#"), copyGenerated(secret, gen, field))
+	} else {
+		fmt.Println("+ " + ui.FormatItem(id, item.Title, item.Tags, "it#"))
+	}
 	warnIfUnpushed()
 	return nil
 }
@@ -0,0 +1,46 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/agent"
+)
+
+// HandleAgent runs the key agent in the foreground until interrupted.
+// While it runs, unlocked keys stay in its memory instead of the session key file.
+func HandleAgent(timeout time.Duration) error {
+	if timeout < 0 {
+		return fmt.Errorf("timeout cannot be negative")
+	}
+
+	ln, err := agent.Listen()
+	if err != nil {
+		return err
+	}
+
+	server := agent.NewServer(timeout)
+
+	// Wipe keys and remove the socket on shutdown
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	go func() {
+		<-sigs
+		ln.Close()
+	}()
+
+	if timeout > 0 {
+		fmt.Fprintf(os.Stderr, "dredge agent listening on %s (keys expire after at most %s)\n", agent.SocketPath(), timeout)
+	} else {
+		fmt.Fprintf(os.Stderr, "dredge agent listening on %s (no timeout cap)\n", agent.SocketPath())
+	}
+
+	err = server.Serve(ln)
+	wiped := server.Wipe()
+	_ = os.Remove(agent.SocketPath())
+	fmt.Fprintf(os.Stderr, "dredge agent stopped (%d key(s) wiped)\n", wiped)
+	return err
+}
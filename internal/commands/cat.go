@@ -4,7 +4,7 @@ import "fmt"
 
 func HandleCat(args []string) error {
 	if len(args) < 1 {
-		return fmt.Errorf("usage: dredge cat <id>")
+		return fmt.Errorf("usage: dredge cat <id> [field]")
 	}
-	return HandleView(args, true)
+	return HandleView(args, ViewOptions{Raw: true})
 }
@@ -13,8 +13,8 @@ import (
 )
 
 func HandleCopy(args []string) error {
-	if len(args) < 1 {
-		return fmt.Errorf("usage: dredge copy <id>")
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: dredge copy <id> [field]")
 	}
 
 	ids, err := ResolveArgs(args[:1])
@@ -37,11 +37,48 @@ func HandleCopy(args []string) error {
 		return fmt.Errorf("binary items cannot be copied to clipboard — use 'dredge export %s' instead", id)
 	}
 
-	if err := writeToClipboard(item.Content.Text); err != nil {
+	if _, err := openLockedItem(id, item); err != nil {
+		return err
+	}
+
+	text, what := item.Content.Text, ""
+	var field *storage.Field
+	if len(args) == 2 {
+		if field, err = itemField(id, item, args[1]); err != nil {
+			return err
+		}
+	} else if len(item.Content.Fields) > 0 {
+		// Items with fields copy their password unless a field is named
+		if field = defaultCopyField(item); field == nil && text == "" {
+			return fmt.Errorf("[%s] has no password field; name one to copy (fields: %s)", id, strings.Join(item.FieldNames(), ", "))
+		}
+	}
+	if field != nil {
+		if field.Value == "" {
+			return fmt.Errorf("field %s of [%s] is empty", field.Name, id)
+		}
+		text, what = field.Value, field.Name+" of "
+	}
+
+	if err := writeToClipboard(text); err != nil {
 		return fmt.Errorf("clipboard error: %w", err)
 	}
 
-	fmt.Printf("Copied %s to clipboard\n", ui.FormatItem(id, item.Title, item.Tags, "it#"))
+	fmt.Printf("Copied %s%s to clipboard\n", what, ui.FormatItem(id, item.Title, item.Tags, "it#"))
+	return nil
+}
+
+// defaultCopyField is the field 'dredge copy <id>' copies: the password field, else
+// the first secret one (never an otp seed). Nil if there is neither.
+func defaultCopyField(item *storage.Item) *storage.Field {
+	if field := item.Field(genField); field != nil && field.Type == storage.FieldSecret {
+		return field
+	}
+	for k := range item.Content.Fields {
+		if item.Content.Fields[k].Type == storage.FieldSecret {
+			return &item.Content.Fields[k]
+		}
+	}
 	return nil
 }
 
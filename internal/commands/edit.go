@@ -8,17 +8,33 @@ import (
 	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/editor"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/ui"
 )
 
 func HandleEdit(args []string) error {
 	// Parse flags manually (flexible positioning)
+	args, gen, err := parseGenArgs(args)
+	if err != nil {
+		return err
+	}
+	if gen.used && !gen.enabled {
+		return fmt.Errorf("generator flags (--words, --copy...) need --gen")
+	}
+
 	var id string
 	var metadataMode bool
+	field := ""
 
-	for _, arg := range args {
-		switch arg {
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
 		case "--metadata", "-m":
 			metadataMode = true
+		case "--field":
+			if i+1 >= len(args) {
+				return fmt.Errorf("--field needs a field name")
+			}
+			i++
+			field = args[i]
 		default:
 			if id == "" && !isFlag(arg) {
 				id = arg
@@ -27,7 +43,16 @@ func HandleEdit(args []string) error {
 	}
 
 	if id == "" {
-		return fmt.Errorf("usage: dredge edit <id> [--metadata|-m]")
+		return fmt.Errorf("usage: dredge edit <id> [--metadata|-m] [--gen [length] [--field name]]")
+	}
+	if gen.enabled && metadataMode {
+		return fmt.Errorf("--gen and --metadata cannot be combined")
+	}
+	if field != "" && !gen.enabled {
+		return fmt.Errorf("--field goes with --gen")
+	}
+	if field == "" {
+		field = genField
 	}
 
 	// Resolve numbered arg to ID
@@ -57,16 +82,56 @@ func HandleEdit(args []string) error {
 		return fmt.Errorf("failed to read item [%s]: %w", id, err)
 	}
 
-	updatedItem, err := editor.OpenForExisting(item)
-	if err != nil {
+	// Double-locked items are opened with their password and sealed again under it
+	var password string
+	if item.IsLocked() {
+		password, err = ui.PromptPasswordCustom(fmt.Sprintf("Item password for [%s]: ", id))
+		if err != nil {
+			return fmt.Errorf("failed to prompt for password: %w", err)
+		}
+		if _, err := storage.OpenContent(item, password); err != nil {
+			return err
+		}
+	}
+
+	// --gen sets one field to a new secret instead of opening the editor
+	var updatedItem *storage.Item
+	var secret string
+	if gen.enabled {
+		if item.Type != storage.TypeText {
+			return fmt.Errorf("<%s> is a binary item: only text items have fields", id)
+		}
+		updatedItem = item
+		if secret, err = setGenerated(updatedItem, field, gen.policy); err != nil {
+			return err
+		}
+		updatedItem.Modified = time.Now()
+	} else if updatedItem, err = editor.OpenForExisting(item); err != nil {
 		return fmt.Errorf("failed to edit item: %w", err)
+	} else if storage.SameContent(updatedItem, item) {
+		// Nothing to save (resealing a locked item would look like a change and add a revision)
+		fmt.Printf("No changes to [%s] %s\n", id, item.Title)
+		return nil
+	}
+
+	if item.IsLocked() && item.Type == storage.TypeBinary {
+		// Keep the lock the blob was sealed with
+		updatedItem.Lock = item.Lock
+	} else if item.IsLocked() {
+		if _, err := storage.SealContent(updatedItem, password); err != nil {
+			return err
+		}
 	}
 
 	if err := storage.UpdateItem(id, updatedItem, key); err != nil {
 		return fmt.Errorf("failed to update item: %w", err)
 	}
 
-	fmt.Printf("✓ [%s] %s\n", id, updatedItem.Title)
+	if gen.enabled {
+		fmt.Printf("✓ [%s] %s — new %s\n", id, updatedItem.Title, copyGenerated(secret, gen, field))
+	} else {
+		fmt.Printf("✓ [%s] %s\n", id, updatedItem.Title)
+	}
 	warnIfUnpushed()
 	return nil
 }
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 
@@ -37,6 +38,11 @@ func HandleExport(args []string) error {
 		return fmt.Errorf("failed to read item: %w", err)
 	}
 
+	itemKey, err := openLockedItem(id, item)
+	if err != nil {
+		return err
+	}
+
 	// Determine output path
 	var outputPath string
 	if len(args) >= 2 {
@@ -71,39 +77,62 @@ func HandleExport(args []string) error {
 		return fmt.Errorf("file already exists at %s", outputPath)
 	}
 
+	// Determine file permissions: use stored mode but cap at 0600 (no group/world access)
+	var fileMode os.FileMode = 0600
+	if item.Mode != nil {
+		fileMode = os.FileMode(*item.Mode) &^ 0077
+		if fileMode == 0 {
+			fileMode = 0600
+		}
+	}
+
 	// Handle content based on item type
-	var contentToWrite []byte
+	var written int64
 	if item.Type == storage.TypeBinary {
-		// Binary item: read from storage/ directory
-		blobData, err := storage.ReadStorageBlob(id, key)
+		// Binary item: stream from storage/ directory straight to disk
+		written, err = exportBlob(id, key, itemKey, outputPath, fileMode)
 		if err != nil {
-			return fmt.Errorf("failed to read binary blob: %w", err)
+			return err
+		}
+
+		// Verify size for binary items only (text size is not stored — content is runtime)
+		if item.Size != nil && written != *item.Size {
+			_ = os.Remove(outputPath)
+			return fmt.Errorf("size mismatch: expected %d bytes, got %d bytes", *item.Size, written)
 		}
-		contentToWrite = blobData
 	} else {
 		// Text item: write content directly
-		contentToWrite = []byte(item.Content.Text)
+		if err := os.WriteFile(outputPath, []byte(item.Content.Text), fileMode); err != nil {
+			return fmt.Errorf("failed to write file: %w", err)
+		}
+		written = int64(len(item.Content.Text))
 	}
 
-	// Verify size for binary items only (text size is not stored — content is runtime)
-	if item.Type == storage.TypeBinary && item.Size != nil && int64(len(contentToWrite)) != *item.Size {
-		return fmt.Errorf("size mismatch: expected %d bytes, got %d bytes", *item.Size, len(contentToWrite))
-	}
+	fmt.Printf("Exported [%s] %s -> %s (%d bytes)\n", id, item.Title, outputPath, written)
+	return nil
+}
 
-	// Determine file permissions: use stored mode but cap at 0600 (no group/world access)
-	var fileMode os.FileMode = 0600
-	if item.Mode != nil {
-		fileMode = os.FileMode(*item.Mode) &^ 0077
-		if fileMode == 0 {
-			fileMode = 0600
-		}
+// exportBlob decrypts storage/id into outputPath chunk by chunk.
+// A partially written file is removed if decryption fails midway.
+func exportBlob(id string, key, itemKey []byte, outputPath string, mode os.FileMode) (int64, error) {
+	blob, err := openBlob(id, key, itemKey)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read binary blob: %w", err)
 	}
+	defer blob.Close()
 
-	// Write to output path
-	if err := os.WriteFile(outputPath, contentToWrite, fileMode); err != nil {
-		return fmt.Errorf("failed to write file: %w", err)
+	out, err := os.OpenFile(outputPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, mode)
+	if err != nil {
+		return 0, fmt.Errorf("failed to write file: %w", err)
 	}
 
-	fmt.Printf("Exported [%s] %s -> %s (%d bytes)\n", id, item.Title, outputPath, len(contentToWrite))
-	return nil
+	n, err := io.Copy(out, blob)
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(outputPath)
+		return n, fmt.Errorf("failed to export binary blob: %w", err)
+	}
+	return n, nil
 }
@@ -0,0 +1,381 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+)
+
+// ============================================================================
+// fsck
+// ============================================================================
+//
+// HandleFsck decrypts every item and blob and cross-checks the vault's bookkeeping
+// (links, spawned files, leftovers from an interrupted passwd, trash, vault manifest).
+// Problems make it return an error, so `dredge fsck` exits non-zero and can run from cron.
+
+// fsckProblem is one finding that makes the vault unhealthy. repair is nil when it
+// can't be fixed automatically.
+type fsckProblem struct {
+	what   string
+	repair func() error
+}
+
+type fsckReport struct {
+	key      []byte
+	vaultDir string
+
+	items, blobs, links int
+	problems            []fsckProblem
+	notes               []string
+
+	itemErrors int // items that failed to decrypt or decode
+}
+
+func (r *fsckReport) problem(repair func() error, format string, args ...any) {
+	r.problems = append(r.problems, fsckProblem{what: fmt.Sprintf(format, args...), repair: repair})
+}
+
+func (r *fsckReport) note(format string, args ...any) {
+	r.notes = append(r.notes, fmt.Sprintf(format, args...))
+}
+
+// HandleFsck checks the active vault and, with repair, fixes what it safely can.
+func HandleFsck(repair bool) error {
+	vaultDir, err := storage.GetDredgeDir()
+	if err != nil {
+		return fmt.Errorf("failed to get dredge directory: %w", err)
+	}
+
+	key, err := crypto.GetKeyWithVerification()
+	if err != nil {
+		return fmt.Errorf("key error: %w", err)
+	}
+
+	r := &fsckReport{key: key, vaultDir: vaultDir}
+	binaries, err := r.checkItems()
+	if err != nil {
+		return err
+	}
+	if err := r.checkBlobs(binaries); err != nil {
+		return err
+	}
+	r.checkLinks()
+	r.checkLeftovers()
+	r.checkTrash()
+	if err := r.checkManifest(); err != nil {
+		return err
+	}
+
+	return r.print(repair)
+}
+
+// ============================================================================
+// Checks
+// ============================================================================
+
+// checkItems decrypts and validates every item. Returns the binary items by ID.
+func (r *fsckReport) checkItems() (map[string]*storage.Item, error) {
+	ids, err := storage.ListItemIDs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list items: %w", err)
+	}
+	sort.Strings(ids)
+
+	binaries := make(map[string]*storage.Item)
+	for _, id := range ids {
+		r.items++
+		item, err := storage.ReadItemStrict(id, r.key)
+		if err != nil {
+			r.itemErrors++
+			r.problem(nil, "[%s] %v", id, err)
+			continue
+		}
+		if item.Type == storage.TypeBinary {
+			binaries[id] = item
+		}
+	}
+
+	// Temp files from an interrupted write (the real file was never replaced)
+	r.tempFiles(filepath.Join(r.vaultDir, "items"))
+	return binaries, nil
+}
+
+// checkBlobs decrypts every storage blob and compares its size with its item.
+func (r *fsckReport) checkBlobs(binaries map[string]*storage.Item) error {
+	blobIDs, err := storage.ListStorageIDs()
+	if err != nil {
+		return err
+	}
+	sort.Strings(blobIDs)
+
+	hasBlob := make(map[string]bool, len(blobIDs))
+	for _, id := range blobIDs {
+		hasBlob[id] = true
+		r.blobs++
+
+		item, ok := binaries[id]
+		if !ok {
+			if exists, _ := storage.ItemExists(id); exists {
+				continue // item is broken or not binary; reported with the items
+			}
+			r.problem(func() error { return trashBlob(id, r.key) }, "storage/%s has no item", id)
+			continue
+		}
+
+		size, err := storage.StorageBlobSize(id, r.key)
+		if err != nil {
+			r.problem(nil, "<%s> %v", id, err)
+			continue
+		}
+		// A double-locked blob holds the inner ciphertext, which only its password opens
+		if size != *item.Size && !item.IsLocked() {
+			r.problem(func() error { return fixBlobSize(id, size, r.key) },
+				"<%s> blob holds %d bytes but the item says %d", id, size, *item.Size)
+		}
+	}
+
+	for id := range binaries {
+		if !hasBlob[id] {
+			r.problem(nil, "<%s> storage blob is missing", id)
+		}
+	}
+
+	r.tempFiles(filepath.Join(r.vaultDir, "storage"))
+	return nil
+}
+
+// checkLinks cross-checks links.json against .spawned/ and the symlinks.
+func (r *fsckReport) checkLinks() {
+	manifest, err := storage.LoadManifest()
+	if err != nil {
+		r.problem(nil, "links.json: %v", err)
+		return
+	}
+
+	ids := make([]string, 0, len(manifest))
+	for id := range manifest {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	for _, id := range ids {
+		entry := manifest[id]
+		r.links++
+		unlink := func() error { return storage.Unlink(id) }
+
+		exists, _ := storage.ItemExists(id)
+		if !exists {
+			r.problem(unlink, "link %s points to missing item [%s]", entry.Path, id)
+			continue
+		}
+
+		spawnedPath, _ := storage.GetSpawnedPath(id)
+		if _, err := os.Stat(spawnedPath); err != nil {
+			// Reading a linked item recreates its spawned file and symlink
+			r.problem(func() error { _, err := storage.ReadItem(id, r.key); return err },
+				"[%s] spawned file is missing", id)
+			continue
+		}
+
+		target, err := os.Readlink(entry.Path)
+		switch {
+		case os.IsNotExist(err):
+			r.problem(func() error { return os.Symlink(spawnedPath, entry.Path) }, "[%s] symlink %s is missing", id, entry.Path)
+		case err != nil:
+			r.problem(nil, "[%s] %s is not a symlink", id, entry.Path)
+		case target != spawnedPath:
+			r.problem(nil, "[%s] symlink %s points to %s instead of the vault", id, entry.Path, target)
+		}
+
+		if storage.SpawnedFileChanged(id) {
+			r.note("[%s] %s has edits not yet synced into the vault (synced on next read, or on unlink if double-locked)", id, entry.Path)
+		}
+	}
+
+	for _, id := range storage.GetOrphanedSpawnedFiles() {
+		r.problem(func() error { return storage.RemoveSpawnedFile(id) }, ".spawned/%s is not in links.json", id)
+	}
+}
+
+// checkLeftovers finds what an interrupted passwd leaves behind (see reencryptVault).
+// They are stale once the live files decrypt with the current key.
+func (r *fsckReport) checkLeftovers() {
+	itemsDir := filepath.Join(r.vaultDir, "items")
+	_, statErr := os.Stat(itemsDir)
+	itemsMissing := os.IsNotExist(statErr)
+
+	for _, name := range []string{tmpDirName, oldDirName, storageTmpDirName, storageOldDirName, keyTmpName, keyOldName} {
+		path := filepath.Join(r.vaultDir, name)
+		if _, err := os.Lstat(path); err != nil {
+			continue
+		}
+
+		switch {
+		case name == oldDirName && itemsMissing:
+			// The swap stopped between moving items/ away and moving the new one in
+			r.problem(func() error { return os.Rename(path, itemsDir) }, "%s left by an interrupted passwd and items/ is missing (restore it)", name)
+		case r.itemErrors == 0 && !itemsMissing:
+			r.problem(func() error { return os.RemoveAll(path) }, "%s left by an interrupted passwd", name)
+		default:
+			r.problem(nil, "%s left by an interrupted passwd, and some items don't decrypt: inspect it by hand", name)
+		}
+	}
+}
+
+// checkTrash reports this vault's items in the system trash.
+func (r *fsckReport) checkTrash() {
+	entries, err := storage.ListTrash()
+	if err != nil {
+		r.note("trash: %v", err)
+		return
+	}
+
+	for _, e := range entries {
+		var parts []string
+		if e.HasInfo && !e.DeletedAt.IsZero() {
+			parts = append(parts, "deleted "+e.DeletedAt.Format("2006-01-02 15:04"))
+		}
+		if e.HasBlob {
+			parts = append(parts, "with storage blob")
+		}
+		if !e.HasInfo {
+			parts = append(parts, "missing .trashinfo")
+		}
+		r.note("trash: [%s] %s", e.ID, strings.Join(parts, ", "))
+	}
+}
+
+// checkManifest compares the vault manifest with the files on disk. Its repair re-signs
+// the vault as it is now, so it runs last, after the other repairs changed what they change.
+func (r *fsckReport) checkManifest() error {
+	check, err := storage.CheckVaultManifest(r.key, nil)
+	if err != nil {
+		return fmt.Errorf("failed to check vault manifest: %w", err)
+	}
+	if !check.Exists {
+		r.note("no vault manifest yet (written on the next change)")
+		return nil
+	}
+
+	resealed := false
+	reseal := func() error {
+		if resealed {
+			return nil
+		}
+		resealed = true
+		return storage.SealVaultManifest(r.key)
+	}
+	for _, p := range check.Problems {
+		r.problem(reseal, "%s", p)
+	}
+	if len(check.Problems) > 0 {
+		fmt.Fprintln(os.Stderr, "Warning: the vault does not match its manifest. Files were deleted, replaced or rolled")
+		fmt.Fprintln(os.Stderr, "Warning: back outside dredge. Check 'git log' before repairing: --repair accepts the vault as it is.")
+	}
+	return nil
+}
+
+// tempFiles reports *.tmp files in dir, left by a write that never completed.
+func (r *fsckReport) tempFiles(dir string) {
+	matches, _ := filepath.Glob(filepath.Join(dir, "*.tmp"))
+	for _, path := range matches {
+		rel, _ := filepath.Rel(r.vaultDir, path)
+		r.problem(func() error { return os.Remove(path) }, "%s left by an interrupted write", rel)
+	}
+}
+
+// ============================================================================
+// Repairs
+// ============================================================================
+
+// trashBlob moves an orphaned storage blob to the system trash instead of deleting it.
+func trashBlob(id string, key []byte) error {
+	if err := storage.EnsureTrashDirectories(); err != nil {
+		return err
+	}
+	blobPath, err := storage.GetStoragePath(id)
+	if err != nil {
+		return err
+	}
+	trashPath, err := storage.GetTrashStorageBlobPath(id)
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(blobPath, trashPath); err != nil {
+		return err
+	}
+	return storage.UpdateVaultManifest(key, storage.ManifestBlobPath(id))
+}
+
+// fixBlobSize records the blob's actual (authenticated) size in its item.
+func fixBlobSize(id string, size int64, key []byte) error {
+	item, err := storage.ReadItem(id, key)
+	if err != nil {
+		return err
+	}
+	item.Size = &size
+	return storage.UpdateItem(id, item, key)
+}
+
+// ============================================================================
+// Report
+// ============================================================================
+
+// warnUnreadable tells list/search that they skipped items instead of hiding them.
+func warnUnreadable(n int) {
+	if n > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: %d item(s) could not be read (run 'dredge fsck')\n", n)
+	}
+}
+
+func (r *fsckReport) print(repair bool) error {
+	fmt.Printf("Checked %d item(s), %d blob(s), %d link(s) in %s\n", r.items, r.blobs, r.links, r.vaultDir)
+
+	if len(r.notes) > 0 {
+		fmt.Println("\nNotes:")
+		for _, n := range r.notes {
+			fmt.Println("  " + n)
+		}
+	}
+
+	if len(r.problems) == 0 {
+		fmt.Println("\n✓ No problems found")
+		return nil
+	}
+
+	fmt.Println("\nProblems:")
+	remaining, repairable := 0, 0
+	for _, p := range r.problems {
+		switch {
+		case p.repair == nil:
+			fmt.Printf("  ✗ %s\n", p.what)
+			remaining++
+		case !repair:
+			fmt.Printf("  ✗ %s (repairable)\n", p.what)
+			remaining++
+			repairable++
+		default:
+			if err := p.repair(); err != nil {
+				fmt.Printf("  ✗ %s (repair failed: %v)\n", p.what, err)
+				remaining++
+			} else {
+				fmt.Printf("  ✓ %s (repaired)\n", p.what)
+			}
+		}
+	}
+
+	if remaining == 0 {
+		fmt.Printf("\n✓ Repaired %d problem(s)\n", len(r.problems))
+		return nil
+	}
+	if repairable > 0 {
+		fmt.Printf("\nRun 'dredge fsck --repair' to fix %d of them.\n", repairable)
+	}
+	return fmt.Errorf("%d problem(s) found", remaining)
+}
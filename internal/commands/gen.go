@@ -0,0 +1,179 @@
+package commands
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/passgen"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+)
+
+// ============================================================================
+// gen
+// ============================================================================
+//
+// Passwords and passphrases are generated with the vault's [vault.gen] policy (see
+// passgen.Default), adjusted by flags. 'add --gen' and 'edit --gen' put them straight
+// into a secret field so they never show up in the terminal or shell history.
+
+// genField is the field 'add --gen' and 'edit --gen' write to by default.
+const genField = "password"
+
+// genOptions are the generator flags shared by gen, add and edit.
+type genOptions struct {
+	enabled bool // --gen (add and edit)
+	used    bool // any generator flag at all
+	copy    bool // --copy
+	policy  passgen.Policy
+}
+
+// parseGenArgs takes the generator flags out of args and returns the other args:
+//
+//	--gen [length]     generate (add/edit), optionally this long
+//	--words [count]    a word passphrase instead of a password
+//	--sep SEP          between passphrase words
+//	--no-lower, --no-upper, --no-digits, --no-symbols
+//	--no-ambiguous     leave out 0 O 1 l I
+//	--copy             copy the result to the clipboard
+//
+// The content of add's -c is passed through as it is (see contentEnd).
+func parseGenArgs(args []string) ([]string, genOptions, error) {
+	opts := genOptions{policy: passgen.Default}
+	var rest []string
+
+	// optionalNumber consumes args[i+1] if it is a number
+	optionalNumber := func(i int, target *int) int {
+		if i+1 < len(args) {
+			if n, err := strconv.Atoi(args[i+1]); err == nil {
+				*target = n
+				return i + 1
+			}
+		}
+		return i
+	}
+
+	for i := 0; i < len(args); i++ {
+		switch arg := args[i]; arg {
+		case "-c":
+			end := contentEnd(args, i)
+			rest = append(rest, args[i:end]...)
+			i = end - 1
+			continue
+		case "--gen":
+			opts.enabled = true
+			i = optionalNumber(i, &opts.policy.Length)
+		case "--words":
+			opts.policy.Passphrase = true
+			i = optionalNumber(i, &opts.policy.Words)
+		case "--sep":
+			if i+1 >= len(args) {
+				return nil, opts, fmt.Errorf("--sep needs a separator")
+			}
+			i++
+			opts.policy.Separator = args[i]
+		case "--no-lower":
+			opts.policy.Lower = false
+		case "--no-upper":
+			opts.policy.Upper = false
+		case "--no-digits":
+			opts.policy.Digits = false
+		case "--no-symbols":
+			opts.policy.Symbols = false
+		case "--no-ambiguous":
+			opts.policy.ExcludeAmbiguous = true
+		case "--copy":
+			opts.copy = true
+		default:
+			rest = append(rest, arg)
+			continue
+		}
+		opts.used = true
+	}
+	return rest, opts, nil
+}
+
+// describe says what was generated, without showing it.
+func (o genOptions) describe() string {
+	if o.policy.Passphrase {
+		return fmt.Sprintf("%d-word passphrase (~%.0f bits)", o.policy.Words, o.policy.Bits())
+	}
+	return fmt.Sprintf("%d-character password (~%.0f bits)", o.policy.Length, o.policy.Bits())
+}
+
+// HandleGen prints (or copies) a new password or passphrase.
+func HandleGen(args []string) error {
+	rest, opts, err := parseGenArgs(args)
+	if err != nil {
+		return err
+	}
+	if len(rest) > 1 {
+		return fmt.Errorf("usage: dredge gen [length] [--words [count]] [--copy]")
+	}
+	if len(rest) == 1 {
+		n, err := strconv.Atoi(rest[0])
+		if err != nil {
+			return fmt.Errorf("usage: dredge gen [length] [--words [count]] [--copy]")
+		}
+		if opts.policy.Passphrase {
+			opts.policy.Words = n
+		} else {
+			opts.policy.Length = n
+		}
+	}
+
+	secret, err := opts.policy.Generate()
+	if err != nil {
+		return err
+	}
+
+	if opts.copy {
+		if err := writeToClipboard(secret); err != nil {
+			return fmt.Errorf("clipboard error: %w", err)
+		}
+		fmt.Printf("Copied a new %s to clipboard\n", opts.describe())
+		return nil
+	}
+	fmt.Println(secret)
+	return nil
+}
+
+// setGenerated generates a secret and stores it in the item's field name (added as a
+// secret field if the item doesn't have it yet; a text field becomes secret). otp fields
+// hold 2FA seeds and are refused. Returns the secret, to copy once the item is saved.
+func setGenerated(item *storage.Item, name string, policy passgen.Policy) (string, error) {
+	if !storage.ValidFieldName(name) {
+		return "", fmt.Errorf("invalid field name %q", name)
+	}
+	field := item.Field(name)
+	if field != nil && field.Type == storage.FieldOTP {
+		return "", fmt.Errorf("field %s holds a 2FA seed; generate into a secret field instead", field.Name)
+	}
+
+	secret, err := policy.Generate()
+	if err != nil {
+		return "", err
+	}
+
+	if field != nil {
+		field.Type = storage.FieldSecret
+		field.Value = secret
+	} else {
+		item.Content.Fields = append(item.Content.Fields, storage.Field{Name: name, Type: storage.FieldSecret, Value: secret})
+	}
+	if err := storage.ValidateFields(item.Content.Fields); err != nil {
+		return "", err
+	}
+	return secret, nil
+}
+
+// copyGenerated copies a generated secret if asked to, and says where it went.
+func copyGenerated(secret string, opts genOptions, field string) string {
+	note := fmt.Sprintf("%s in field '%s'", opts.describe(), field)
+	if !opts.copy {
+		return note
+	}
+	if err := writeToClipboard(secret); err != nil {
+		return note + fmt.Sprintf("; not copied: %v", err)
+	}
+	return note + ", copied to clipboard"
+}
@@ -15,64 +15,101 @@ func HandleHelp(args []string) error {
 			gohelp.Item("edit, e", "Edit an item"),
 			gohelp.Item("rm", "Remove an item"),
 			gohelp.Item("undo", "Restore last deleted item"),
+			gohelp.Item("history", "List an item's earlier revisions (kept per [vault] history)", "dredge history ssh"),
+			gohelp.Item("diff", "Show changes from a revision (the latest by default) to now", "dredge diff ssh 3"),
+			gohelp.Item("revert", "Restore an earlier revision (the current one is kept)", "dredge revert ssh 3"),
 			gohelp.Item("mv, rename, rn", "Rename an item"),
-			gohelp.Item("cat, c", "Output raw item content (for piping)"),
-			gohelp.Item("copy, cp", "Copy item content to clipboard"),
-			gohelp.Item("export", "Export a binary item to the filesystem"),
+			gohelp.Item("cat, c", "Output raw item content, binary too, or one field's value (for piping; other fields are left out)", "dredge cat github password"),
+			gohelp.Item("copy, cp", "Copy one field's value to clipboard; the password field by default, or the content for items without fields", "dredge copy github username"),
+			gohelp.Item("gen", "Generate a password ([length], --no-symbols, --no-ambiguous...) or passphrase (--words [count])", "dredge gen 32 --copy"),
+			gohelp.Item("otp", "Show the current TOTP code from an item's otp field (--copy to clipboard, --import URIs from stdin)", "dredge otp github --copy"),
+			gohelp.Item("export", "Export a binary item to the filesystem (streamed, any size)"),
+			gohelp.Item("lock-item", "Double-lock an item: its content always asks for its own password (--remove undoes it)", "dredge lock-item root-ca"),
 		).
 		Section("Links",
 			gohelp.Item("link, ln", "Link an item to a system path", "dredge link ssh-config ~/.ssh/config"),
 			gohelp.Item("unlink", "Unlink an item from a system path"),
 		).
 		Section("Vault",
-			gohelp.Item("init, use", "Initialize or activate a vault", "dredge init /path/to/vault"),
-			gohelp.Item("lock", "Lock the vault (clears cached session key)"),
-			gohelp.Item("passwd", "Change vault password"),
+			gohelp.Item("init, use", "Initialize or activate a vault (--calibrate tunes key derivation, --keyfile adds a second factor)", "dredge init /path/to/vault"),
+			gohelp.Item("unlock", "Unlock for this terminal (--for keeps it unlocked that long, used or not)", "dredge unlock --for 2h"),
+			gohelp.Item("lock", "Lock the vault (clears cached session key; --all for every vault and terminal)"),
+			gohelp.Item("rewrite", "Re-encrypt items and blobs with the vault's settings (after changing padding or compression)"),
+			gohelp.Item("fsck", "Decrypt every item and check blobs, links, leftovers and the manifest (--repair fixes them)", "dredge fsck --repair"),
+			gohelp.Item("rekey", "Rotate the data key and re-encrypt everything (after someone loses access: remove their key slot first; --drop-recovery drops recovery slots)"),
+			gohelp.Item("passwd", "Change vault password (--calibrate or --kdf-* to change cost only)", "dredge passwd --calibrate --unlock-time 2s"),
+			gohelp.Item("passwd --add-keyfile", "Require a keyfile alongside your password (created if missing)", "dredge passwd --add-keyfile /media/usb/dredge.key"),
+			gohelp.Item("key add|list|remove", "Manage key slots (one password per person or device)", "dredge key add laptop"),
+			gohelp.Item("recovery create|unlock", "Offline recovery key (24 words) to reset a forgotten password"),
+			gohelp.Item("agent", "Keep unlocked keys in memory (--timeout caps their lifetime)", "dredge agent --timeout 15m &"),
+			gohelp.Item("keygen", "Create your identity file and print your public key"),
+			gohelp.Item("recipients add|list|remove", "Let teammates unlock with their identity", "dredge recipients add dredge1..."),
 		).
 		Section("Sync",
 			gohelp.Item("remote", "Wire a git remote to the active vault", "dredge remote owner/repo"),
 			gohelp.Item("push", "Push changes to remote"),
-			gohelp.Item("pull", "Pull changes from remote"),
-			gohelp.Item("sync", "Sync with remote (pull + push)"),
-			gohelp.Item("status", "Show pending changes"),
+			gohelp.Item("pull", "Pull changes from remote and verify them against the vault manifest"),
+			gohelp.Item("sync", "Sync with remote (pull, verify, push)"),
+			gohelp.Item("status", "Show session time left and pending changes"),
 		).
 		Section("Flags",
 			gohelp.Item("--password, -p", "Password for decryption (skips prompt)"),
+			gohelp.Item("--identity, -i", "Identity file for recipient unlock (default ~/.config/dredge/identity)"),
+			gohelp.Item("--keyfile", "Keyfile for vaults that require one (or DREDGE_KEYFILE)"),
 			gohelp.Item("--vault", "Vault directory for this command (does not persist)"),
 			gohelp.Item("--luck, -l", "Force view the top search result"),
 			gohelp.Item("--no-lock", "Disable session timeout for this command"),
 		).
-		Text("Tip: bare args route automatically — 'dredge ssh' searches, 'dredge 1' opens result #1.")
+		Text("Tip: bare args route automatically — 'dredge ssh' searches, 'dredge 1' opens result #1.").
+		Text("Settings live in ~/.config/dredge/config.toml ([session] cache, idle_timeout, max_lifetime; [vault] history, the revisions kept per item, default 10); a vault's .dredge-config.toml can shorten the timeouts and raise history for everyone using it, and can set [vault] padding and compression, and [vault.gen] defaults for generated secrets.")
 
 	addPage := gohelp.NewPage("add", "Add a new item to the vault").
-		Usage("dredge add [title] [-c content] [-t tag...] [--file path]").
+		Usage("dredge add [title] [-c content] [-t tag...] [--file path] [--template name] [--gen [length]]").
 		Text("Without flags, opens your $EDITOR with a template. Fill in the title, tags, and content, then save and close to create the item.").
 		Section("Flags",
 			gohelp.Item("-c CONTENT", "Inline content — skips the editor entirely", "dredge add 'db password' -c 'hunter2'"),
 			gohelp.Item("-t TAG...", "One or more tags", "dredge add 'ssh key' -t ssh config"),
 			gohelp.Item("--file, --import PATH", "Import a file — text files are stored inline, binaries go to encrypted blob storage", "dredge add --file ~/.ssh/id_ed25519"),
+			gohelp.Item("--gen [LENGTH]", "Generate a secret into the 'password' field, never shown (--words, --no-symbols... as in gen)", "dredge add 'DB root' --gen 32 -t db"),
+			gohelp.Item("--copy", "With --gen: also copy the secret to the clipboard"),
+			gohelp.Item("--template NAME", "Start from a template's tags, fields and notes (with --gen, fills its password or first secret field)", "dredge add GitHub --template login"),
 		).
 		Text("Tags can also be written inline in the title as #words. Any #word trailing the title is treated as a tag.").
+		Section("Templates",
+			gohelp.Item("login", "username, password, url, otp"),
+			gohelp.Item("api-key", "key, secret, account, url"),
+			gohelp.Item("ssh", "host, user, passphrase, public_key; the private key goes in the notes"),
+			gohelp.Item("database", "dsn, host, port, database, username, password"),
+			gohelp.Item("server", "host, port, username, password, url"),
+			gohelp.Item("wifi", "ssid, password, security"),
+			gohelp.Item("your own", "Any item tagged #template, named by its title (it wins over a built-in of the same name)", "dredge add 'aws #template #aws'"),
+		).
+		Text("Template fields left empty in the editor are dropped.").
 		Section("Editor format",
 			gohelp.Item("line 1", "Title and optional trailing #tags"),
-			gohelp.Item("line 2", "(blank)"),
-			gohelp.Item("line 3+", "Content"),
+			gohelp.Item("next lines", "Optional fields, one per line: 'name: value', or 'name (secret): value' to mask it in view", "password (secret): hunter2"),
+			gohelp.Item("field types", "text (default), secret (masked in view), otp (a 2FA seed, otpauth:// URI or base32, for 'dredge otp')"),
+			gohelp.Item("(blank)", "Ends the fields; a line that isn't shaped like one (such as a URL) starts the content too"),
+			gohelp.Item("rest", "Content (the item's notes)"),
 		).
 		Text("Saving an empty buffer cancels the add.")
 
 	viewPage := gohelp.NewPage("view", "View an item's content").
-		Usage("dredge view <id|number> [--raw]").
+		Usage("dredge view <id|number> [field] [--raw] [--reveal]").
 		Text("Accepts an item ID, a numbered result from the last search, or a search query that resolves to a single match.").
+		Text("Fields are shown above the content; secret ones are masked. Name a field to show just that one.").
 		Section("Flags",
 			gohelp.Item("--raw, -r", "Print content only — no header, no formatting. Useful for piping.", "dredge view abc --raw | pbcopy"),
+			gohelp.Item("--reveal", "Show secret field values", "dredge view abc password --reveal"),
 		).
-		Text("'dredge cat' is shorthand for 'dredge view --raw' and is pipe-friendly by default.")
+		Text("'dredge cat' is shorthand for 'dredge view --raw' and is pipe-friendly by default: 'dredge cat abc password' prints the value as is.")
 
 	editPage := gohelp.NewPage("edit", "Edit an existing item").
-		Usage("dredge edit <id|number> [--metadata]").
-		Text("Opens the item in $EDITOR using the same template format as add: title and #tags on line 1, content from line 3 onward.").
+		Usage("dredge edit <id|number> [--metadata] [--gen [length] [--field name]]").
+		Text("Opens the item in $EDITOR using the same template format as add: title and #tags on line 1, then fields, a blank line, and the content.").
 		Section("Flags",
 			gohelp.Item("--metadata, -m", "Edit metadata only (title, tags, type, filename, mode) as raw TOML — content is untouched.", "dredge edit abc --metadata"),
+			gohelp.Item("--gen [LENGTH]", "Replace the 'password' field (or --field NAME) with a new generated secret, without opening the editor (the field becomes secret; otp fields are refused)", "dredge edit abc --gen --copy"),
 		).
 		Text("Saving without changes leaves the item unmodified. The modified timestamp is only updated when content actually changes.")
 
@@ -84,7 +121,7 @@ func HandleHelp(args []string) error {
 			gohelp.Item("--force, -f", "Overwrite an existing file or symlink at the target path"),
 			gohelp.Item("-p, --parents", "Create parent directories if they don't exist", "dredge link abc ~/.config/app/config.toml -p"),
 		).
-		Text("Only text items can be linked. Use 'dredge unlink <id>' to remove the symlink and spawned copy.")
+		Text("Only text items can be linked. A double-locked item asks for its password, and its plaintext stays on disk until it is unlinked; edits to it are saved on unlink, after asking again. Use 'dredge unlink <id>' to remove the symlink and spawned copy.")
 
 	gohelp.Run(append([]string{"help"}, args...), root, addPage, viewPage, editPage, linkPage)
 	return nil
@@ -0,0 +1,368 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"golang.org/x/term"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/ui"
+)
+
+// ============================================================================
+// history / diff / revert
+// ============================================================================
+//
+// Text items keep their last few states inside the item (see storage/history.go, and
+// [vault] history in .dredge-config.toml). history lists them, diff compares one with
+// the current item, and revert brings one back (keeping the current state as a revision,
+// so a revert can be reverted too).
+
+const (
+	// diffContext is how many unchanged lines surround each change.
+	diffContext = 3
+
+	// maxDiffCells caps the line-comparison table; larger changes are shown as one block.
+	maxDiffCells = 4 << 20
+)
+
+// HandleHistory lists an item's earlier revisions, newest first.
+func HandleHistory(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: dredge history <id>")
+	}
+	id, item, _, err := readItemWithHistory(args[0])
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(ui.FormatItem(id, item.Title, item.Tags, "it#"))
+	fmt.Println()
+	fmt.Printf("  %-8s %s  %s\n", "current", item.Modified.Local().Format("2006-01-02 15:04"), describeContent(item.Content, item.IsLocked()))
+	for k := len(item.History) - 1; k >= 0; k-- {
+		r := &item.History[k]
+		line := fmt.Sprintf("  %-8s %s  %s", "#"+strconv.Itoa(r.Rev), r.Modified.Local().Format("2006-01-02 15:04"), describeContent(storage.ItemContent{Text: r.Text, Fields: r.Fields}, r.IsLocked()))
+		if r.Title != item.Title {
+			line += fmt.Sprintf("  (title: %s)", r.Title)
+		}
+		fmt.Println(line)
+	}
+
+	if len(item.History) == 0 {
+		fmt.Println("\nNo earlier revisions.")
+	} else {
+		fmt.Printf("\nUse 'dredge diff %s <rev>' to compare, 'dredge revert %s <rev>' to restore.\n", id, id)
+	}
+	return nil
+}
+
+// HandleDiff shows what changed from a revision (the latest by default) to the current item.
+func HandleDiff(args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: dredge diff <id> [rev]")
+	}
+	id, item, _, err := readItemWithHistory(args[0])
+	if err != nil {
+		return err
+	}
+	if len(item.History) == 0 {
+		return fmt.Errorf("[%s] has no earlier revisions", id)
+	}
+
+	rev := &item.History[len(item.History)-1]
+	if len(args) == 2 {
+		if rev, err = findRevision(item, args[1]); err != nil {
+			return err
+		}
+	}
+
+	old, err := revisionContent(id, rev)
+	if err != nil {
+		return err
+	}
+	if _, err := openLockedItem(id, item); err != nil {
+		return err
+	}
+
+	color := term.IsTerminal(int(os.Stdout.Fd()))
+	fmt.Printf("--- [%s] #%d (%s)\n", id, rev.Rev, rev.Modified.Local().Format("2006-01-02 15:04"))
+	fmt.Printf("+++ [%s] current (%s)\n", id, item.Modified.Local().Format("2006-01-02 15:04"))
+
+	if rev.Title != item.Title {
+		printDiffLine('-', "title: "+rev.Title, color)
+		printDiffLine('+', "title: "+item.Title, color)
+	}
+	if oldTags, newTags := ui.FormatTags(rev.Tags), ui.FormatTags(item.Tags); oldTags != newTags {
+		printDiffLine('-', "tags: "+oldTags, color)
+		printDiffLine('+', "tags: "+newTags, color)
+	}
+	printFieldChanges(old.Fields, item.Content.Fields, color)
+	printHunks(diffLines(splitLines(old.Text), splitLines(item.Content.Text)), color)
+	return nil
+}
+
+// HandleRevert restores a revision. The state it replaces becomes a revision itself.
+func HandleRevert(args []string) error {
+	if len(args) != 2 {
+		return fmt.Errorf("usage: dredge revert <id> <rev>")
+	}
+	id, item, key, err := readItemWithHistory(args[0])
+	if err != nil {
+		return err
+	}
+	rev, err := findRevision(item, args[1])
+	if err != nil {
+		return err
+	}
+
+	wasLocked := item.IsLocked()
+	item.Restore(rev)
+	if err := storage.UpdateItem(id, item, key); err != nil {
+		return fmt.Errorf("failed to update item: %w", err)
+	}
+
+	fmt.Printf("✓ [%s] %s reverted to #%d\n", id, item.Title, rev.Rev)
+	if item.IsLocked() && !wasLocked {
+		fmt.Fprintln(os.Stderr, "Note: that revision is double-locked, under the item password it had then. Its history was dropped.")
+	}
+	warnIfUnpushed()
+	return nil
+}
+
+// readItemWithHistory resolves arg and reads the text item it names.
+func readItemWithHistory(arg string) (string, *storage.Item, []byte, error) {
+	ids, err := ResolveArgs([]string{arg})
+	if err != nil {
+		return "", nil, nil, err
+	}
+	id := ids[0]
+
+	key, err := crypto.GetKeyWithVerification()
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to get key: %w", err)
+	}
+	item, err := storage.ReadItem(id, key)
+	if err != nil {
+		return "", nil, nil, fmt.Errorf("failed to read item: %w", err)
+	}
+	if item.Type != storage.TypeText {
+		return "", nil, nil, fmt.Errorf("<%s> is a binary item: only text items keep revisions (binary ones are in git history)", id)
+	}
+	return id, item, key, nil
+}
+
+// findRevision parses a revision number ("3" or "#3") and looks it up.
+func findRevision(item *storage.Item, arg string) (*storage.Revision, error) {
+	rev, err := strconv.Atoi(strings.TrimPrefix(arg, "#"))
+	if err != nil {
+		return nil, fmt.Errorf("invalid revision %q (see 'dredge history')", arg)
+	}
+	return item.Revision(rev)
+}
+
+// revisionContent returns a revision's text and fields, asking for the item password it
+// was double-locked with, if it was.
+func revisionContent(id string, r *storage.Revision) (storage.ItemContent, error) {
+	if !r.IsLocked() {
+		return storage.ItemContent{Text: r.Text, Fields: r.Fields}, nil
+	}
+	password, err := ui.PromptPasswordCustom(fmt.Sprintf("Item password for [%s] #%d: ", id, r.Rev))
+	if err != nil {
+		return storage.ItemContent{}, fmt.Errorf("failed to prompt for password: %w", err)
+	}
+	sealed := &storage.Item{Lock: r.Lock}
+	if _, err := storage.OpenContent(sealed, password); err != nil {
+		return storage.ItemContent{}, err
+	}
+	return sealed.Content, nil
+}
+
+// describeContent summarizes content for the history listing.
+func describeContent(content storage.ItemContent, locked bool) string {
+	if locked {
+		return "double-locked"
+	}
+	text := content.Text
+	lines := len(splitLines(text))
+	var desc string
+	if n := len(text); n < 1024 {
+		desc = fmt.Sprintf("%d B, %d line(s)", n, lines)
+	} else {
+		desc = fmt.Sprintf("%.1f KB, %d line(s)", float64(len(text))/1024.0, lines)
+	}
+	if n := len(content.Fields); n > 0 {
+		desc += fmt.Sprintf(", %d field(s)", n)
+	}
+	return desc
+}
+
+// printFieldChanges prints the fields that were removed, added or changed (secret
+// values masked, as in view).
+func printFieldChanges(oldFields, newFields []storage.Field, color bool) {
+	for k := range oldFields {
+		f := &oldFields[k]
+		now := storage.FindField(newFields, f.Name)
+		if now != nil && *now == *f {
+			continue
+		}
+		printDiffLine('-', formatField(f, false), color)
+		if now != nil {
+			printDiffLine('+', formatField(now, false), color)
+		}
+	}
+	for k := range newFields {
+		if storage.FindField(oldFields, newFields[k].Name) == nil {
+			printDiffLine('+', formatField(&newFields[k], false), color)
+		}
+	}
+}
+
+// ============================================================================
+// Line diff
+// ============================================================================
+
+type diffOp struct {
+	kind byte // ' ', '-' or '+'
+	text string
+}
+
+func splitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// diffLines returns an edit script from a to b (longest common subsequence of lines).
+func diffLines(a, b []string) []diffOp {
+	pre := 0
+	for pre < len(a) && pre < len(b) && a[pre] == b[pre] {
+		pre++
+	}
+	suf := 0
+	for suf < len(a)-pre && suf < len(b)-pre && a[len(a)-1-suf] == b[len(b)-1-suf] {
+		suf++
+	}
+	am, bm := a[pre:len(a)-suf], b[pre:len(b)-suf]
+
+	var ops []diffOp
+	for _, l := range a[:pre] {
+		ops = append(ops, diffOp{' ', l})
+	}
+
+	if len(am)*len(bm) > maxDiffCells {
+		for _, l := range am {
+			ops = append(ops, diffOp{'-', l})
+		}
+		for _, l := range bm {
+			ops = append(ops, diffOp{'+', l})
+		}
+	} else {
+		// lcs[i][j] = length of the LCS of am[i:] and bm[j:]
+		w := len(bm) + 1
+		lcs := make([]int32, (len(am)+1)*w)
+		for i := len(am) - 1; i >= 0; i-- {
+			for j := len(bm) - 1; j >= 0; j-- {
+				if am[i] == bm[j] {
+					lcs[i*w+j] = lcs[(i+1)*w+j+1] + 1
+				} else {
+					lcs[i*w+j] = max(lcs[(i+1)*w+j], lcs[i*w+j+1])
+				}
+			}
+		}
+		i, j := 0, 0
+		for i < len(am) || j < len(bm) {
+			switch {
+			case i < len(am) && j < len(bm) && am[i] == bm[j]:
+				ops = append(ops, diffOp{' ', am[i]})
+				i++
+				j++
+			case i < len(am) && (j == len(bm) || lcs[(i+1)*w+j] >= lcs[i*w+j+1]):
+				ops = append(ops, diffOp{'-', am[i]})
+				i++
+			default:
+				ops = append(ops, diffOp{'+', bm[j]})
+				j++
+			}
+		}
+	}
+
+	for _, l := range a[len(a)-suf:] {
+		ops = append(ops, diffOp{' ', l})
+	}
+	return ops
+}
+
+// printHunks prints ops as unified-diff hunks with diffContext lines around changes.
+func printHunks(ops []diffOp, color bool) {
+	show := make([]bool, len(ops))
+	for k, op := range ops {
+		if op.kind == ' ' {
+			continue
+		}
+		for c := max(k-diffContext, 0); c <= min(k+diffContext, len(ops)-1); c++ {
+			show[c] = true
+		}
+	}
+
+	aLine, bLine := 1, 1
+	for k := 0; k < len(ops); {
+		if !show[k] {
+			aLine, bLine = advance(ops[k], aLine, bLine)
+			k++
+			continue
+		}
+		end := k
+		aCount, bCount := 0, 0
+		for end < len(ops) && show[end] {
+			if ops[end].kind != '+' {
+				aCount++
+			}
+			if ops[end].kind != '-' {
+				bCount++
+			}
+			end++
+		}
+		fmt.Printf("@@ -%s +%s @@\n", hunkRange(aLine, aCount), hunkRange(bLine, bCount))
+		for ; k < end; k++ {
+			printDiffLine(ops[k].kind, ops[k].text, color)
+			aLine, bLine = advance(ops[k], aLine, bLine)
+		}
+	}
+}
+
+func advance(op diffOp, aLine, bLine int) (int, int) {
+	if op.kind != '+' {
+		aLine++
+	}
+	if op.kind != '-' {
+		bLine++
+	}
+	return aLine, bLine
+}
+
+// hunkRange formats a hunk's start and length; an empty range names the line before it.
+func hunkRange(start, count int) string {
+	if count == 0 {
+		start--
+	}
+	if count == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, count)
+}
+
+func printDiffLine(kind byte, text string, color bool) {
+	switch {
+	case !color || kind == ' ':
+		fmt.Printf("%c%s\n", kind, text)
+	case kind == '-':
+		fmt.Printf("%s%c%s%s\n", ui.ColorRemoved, kind, text, ui.ColorReset)
+	default:
+		fmt.Printf("%s%c%s%s\n", ui.ColorAdded, kind, text, ui.ColorReset)
+	}
+}
@@ -6,14 +6,24 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 
 	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/git"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/session"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/ui"
 )
 
+// InitOptions controls optional key setup performed by HandleInit.
+type InitOptions struct {
+	Calibrate  bool          // benchmark Argon2id and create .dredge-key right away
+	UnlockTime time.Duration // calibration target (default crypto.DefaultUnlockTime)
+	Keyfile    string        // require this keyfile alongside the password (created if missing)
+}
+
 // HandleInit bootstraps a vault at the given path (default: current dir) and activates it.
-func HandleInit(args []string) error {
+func HandleInit(args []string, opts InitOptions) error {
 	if len(args) > 1 {
 		return fmt.Errorf("usage: dredge init [path]")
 	}
@@ -30,6 +40,11 @@ func HandleInit(args []string) error {
 
 	// Already a dredge vault — just activate it
 	if isVaultDir(absPath) {
+		if opts.Calibrate || opts.Keyfile != "" {
+			if _, err := os.Stat(filepath.Join(absPath, crypto.PasswordVerifyFile)); err == nil {
+				return fmt.Errorf("vault already has a password - use 'dredge passwd --calibrate' or 'dredge passwd --add-keyfile' instead")
+			}
+		}
 		_ = crypto.ClearSession()
 		if err := storage.SetActivePath(absPath); err != nil {
 			return fmt.Errorf("failed to set active vault: %w", err)
@@ -73,10 +88,59 @@ func HandleInit(args []string) error {
 		return fmt.Errorf("failed to initialize git repository: %w", err)
 	}
 
+	if opts.Calibrate || opts.Keyfile != "" {
+		if err := createVaultKey(absPath, opts); err != nil {
+			return err
+		}
+	}
+
 	fmt.Printf("Initialized %s\n", absPath)
 	return nil
 }
 
+// createVaultKey prompts for the vault password and writes .dredge-key right away,
+// with calibrated Argon2id parameters (--calibrate) and/or a keyfile (--keyfile).
+func createVaultKey(vaultDir string, opts InitOptions) error {
+	params := crypto.DefaultKDFParams
+	if opts.Calibrate {
+		target := opts.UnlockTime
+		if target <= 0 {
+			target = crypto.DefaultUnlockTime
+		}
+
+		fmt.Fprintf(os.Stderr, "Calibrating key derivation (target unlock time %s)...\n", target)
+		start := time.Now()
+		params = crypto.CalibrateKDF(target)
+		fmt.Fprintf(os.Stderr, "Using %s (benchmark took %s)\n", params, time.Since(start).Round(time.Millisecond))
+	}
+
+	var keyfile []byte
+	if opts.Keyfile != "" {
+		var err error
+		if keyfile, err = prepareKeyfile(opts.Keyfile, vaultDir); err != nil {
+			return err
+		}
+	}
+
+	password, err := ui.PromptPasswordWithConfirmationCustom("New vault password: ", "Retype password: ")
+	if err != nil {
+		return fmt.Errorf("failed to get password: %w", err)
+	}
+
+	// Key operations resolve the vault through the session path set at startup
+	session.SetVaultPath(vaultDir)
+
+	key, err := crypto.CreatePasswordVerificationWithParams(password, keyfile, params)
+	if err != nil {
+		return fmt.Errorf("failed to create password verification: %w", err)
+	}
+
+	if err := crypto.CacheKey(key); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to cache key: %v\n", err)
+	}
+	return nil
+}
+
 // EnsureInitialized checks that an active vault exists and is accessible.
 func EnsureInitialized() error {
 	vaultDir, err := storage.GetDredgeDir()
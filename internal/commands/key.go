@@ -0,0 +1,191 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/ui"
+)
+
+// HandleKeyAdd adds a password key slot that unwraps the same vault key.
+// Flow: unlock with any existing password → prompt new password → append slot to .dredge-key
+func HandleKeyAdd(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: dredge key add <label>")
+	}
+	label := args[0]
+	if err := crypto.ValidateSlotLabel(label); err != nil {
+		return err
+	}
+
+	vf, dataKey, err := unlockKeyFile("Existing password: ")
+	if err != nil {
+		return err
+	}
+	if vf.FindSlot(label) >= 0 {
+		return fmt.Errorf("a key slot labelled '%s' already exists", label)
+	}
+
+	password, err := ui.PromptPasswordWithConfirmationCustom(fmt.Sprintf("Password for '%s': ", label), "Retype password: ")
+	if err != nil {
+		return fmt.Errorf("failed to get new password: %w", err)
+	}
+
+	// The new slot requires the keyfile given with --keyfile, if any
+	keyfile, err := crypto.LoadKeyfile()
+	if err != nil {
+		return err
+	}
+	slot, err := crypto.NewPasswordSlotWithKeyfile(label, dataKey, password, keyfile, crypto.DefaultKDFParams)
+	if err != nil {
+		return err
+	}
+	if err := vf.AddSlot(*slot); err != nil {
+		return err
+	}
+
+	if err := writeKeyFile(vf, dataKey); err != nil {
+		return err
+	}
+
+	if keyfile != nil {
+		fmt.Printf("✓ Added key slot '%s' (requires the keyfile)\n", label)
+	} else {
+		fmt.Printf("✓ Added key slot '%s'\n", label)
+	}
+	warnIfUnpushed()
+	return nil
+}
+
+// HandleKeyList prints the vault's key slots. Labels are not secret, so no password is needed.
+func HandleKeyList() error {
+	vf, err := crypto.ReadVerifyFile()
+	if err != nil {
+		return err
+	}
+
+	for _, slot := range vf.Slots {
+		created := "-"
+		if !slot.Created.IsZero() {
+			created = slot.Created.Local().Format("2006-01-02")
+		}
+		kdf := slot.KDF
+		if slot.Type == crypto.SlotPassword {
+			kdf = slot.Params.String()
+		}
+		slotType := slot.Type
+		if slot.Keyfile {
+			slotType += "+keyfile"
+		}
+		fmt.Printf("%-20s %-16s %-34s %s\n", slot.Label, slotType, kdf, created)
+	}
+
+	if !vf.HasDataKey() {
+		fmt.Fprintln(os.Stderr, "Note: this vault predates key slots; run 'dredge passwd' once to enable 'dredge key add'")
+	}
+	return nil
+}
+
+// HandleKeyRemove deletes a key slot. Any password still on the vault authorizes it.
+func HandleKeyRemove(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: dredge key remove <label>")
+	}
+	label := args[0]
+
+	vf, dataKey, err := unlockKeyFile("Password: ")
+	if err != nil {
+		return err
+	}
+	if err := vf.RemoveSlot(label); err != nil {
+		return err
+	}
+
+	if err := writeKeyFile(vf, dataKey); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Removed key slot '%s'\n", label)
+	warnIfUnpushed()
+	return nil
+}
+
+// unlockKeyFile reads .dredge-key and unlocks it with the identity file if it is a
+// recipient, otherwise with a freshly prompted password (the session cache is bypassed, as for passwd).
+func unlockKeyFile(prompt string) (*crypto.VerifyFile, []byte, error) {
+	vf, err := crypto.ReadVerifyFile()
+	if err != nil {
+		return nil, nil, err
+	}
+	if !vf.HasDataKey() {
+		return nil, nil, fmt.Errorf("this vault predates key slots - run 'dredge passwd' once to migrate it")
+	}
+
+	identity, err := crypto.LoadIdentity()
+	if err != nil {
+		return nil, nil, err
+	}
+	if identity != nil && vf.FindRecipient(identity.PublicKey()) >= 0 {
+		dataKey, idx, err := vf.UnlockIdentity(identity)
+		if err != nil {
+			return nil, nil, err
+		}
+		fmt.Fprintf(os.Stderr, "Unlocked with identity (key slot '%s')\n", vf.Slots[idx].Label)
+		return vf, dataKey, nil
+	}
+
+	password, err := ui.PromptPasswordCustom(prompt)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to prompt for password: %w", err)
+	}
+
+	keyfile, err := crypto.LoadKeyfile()
+	if err != nil {
+		return nil, nil, err
+	}
+	dataKey, err := vf.UnlockWithKeyfile(password, keyfile)
+	if err != nil {
+		return nil, nil, fmt.Errorf("password verification failed: %w", err)
+	}
+	return vf, dataKey, nil
+}
+
+// prepareKeyfile returns the digest of the keyfile at path, generating a random one
+// if it does not exist yet. Keyfiles inside the vault are refused: they would be
+// pushed to the remote next to the ciphertexts they are meant to protect.
+func prepareKeyfile(path, vaultDir string) ([]byte, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve keyfile path: %w", err)
+	}
+	if absVault, err := filepath.Abs(vaultDir); err == nil {
+		if rel, err := filepath.Rel(absVault, absPath); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("keyfile must live outside the vault (it would be pushed to the remote)")
+		}
+	}
+
+	if _, err := os.Stat(absPath); os.IsNotExist(err) {
+		if err := crypto.GenerateKeyfile(absPath); err != nil {
+			return nil, err
+		}
+		fmt.Fprintf(os.Stderr, "✓ Created keyfile %s\n", absPath)
+		fmt.Fprintln(os.Stderr, "Back it up somewhere safe: without it this vault cannot be unlocked (except with a recovery key).")
+	}
+
+	return crypto.ReadKeyfile(absPath)
+}
+
+// writeKeyFile serializes vf and atomically replaces .dredge-key.
+func writeKeyFile(vf *crypto.VerifyFile, dataKey []byte) error {
+	data, err := vf.Bytes()
+	if err != nil {
+		return err
+	}
+	if err := updatePasswordVerification(data, dataKey); err != nil {
+		return fmt.Errorf("failed to update key file: %w", err)
+	}
+	return nil
+}
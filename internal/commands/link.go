@@ -52,6 +52,12 @@ func HandleLink(args []string) error {
 	if err != nil {
 		return fmt.Errorf("failed to read item: %w", err)
 	}
+	if item.Type != storage.TypeText {
+		return fmt.Errorf("cannot link binary items")
+	}
+	if _, err := openLockedItem(id, item); err != nil {
+		return err
+	}
 
 	var targetPath string
 	if len(positionalArgs) >= 2 {
@@ -94,10 +100,14 @@ func HandleLink(args []string) error {
 	}
 
 	// Perform link operation
-	if err := storage.Link(id, targetPath, force); err != nil {
+	if err := storage.Link(id, item, targetPath, force); err != nil {
 		return err
 	}
 
 	fmt.Printf("Linked [%s] %s -> %s\n", id, item.Title, targetPath)
+	if item.IsLocked() {
+		fmt.Fprintf(os.Stderr, "Warning: [%s] is double-locked, but its plaintext now stays on disk in %s until 'dredge unlink %s'\n", id, targetPath, id)
+		fmt.Fprintf(os.Stderr, "Edits to it are saved when it is unlinked, after asking for its password again\n")
+	}
 	return nil
 }
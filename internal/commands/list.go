@@ -22,46 +22,41 @@ func HandleList(args []string) error {
 		return fmt.Errorf("key error: %w", err)
 	}
 
-	// Load all item IDs
-	ids, err := storage.ListItemIDs()
+	// Load item metadata (one decryption, plus any items that changed since)
+	index, err := storage.LoadIndex(key)
 	if err != nil {
-		return fmt.Errorf("failed to list items: %w", err)
+		return fmt.Errorf("failed to load index: %w", err)
 	}
+	warnUnreadable(len(index.Unreadable))
 
-	if len(ids) == 0 {
+	if len(index.Entries) == 0 {
 		fmt.Println("No items found. Use 'dredge add' to create one.")
 		return nil
 	}
 
-	// Load and decrypt all items
 	type itemEntry struct {
-		id   string
-		item *storage.Item
+		id    string
+		entry *storage.IndexEntry
 	}
 
-	entries := make([]itemEntry, 0, len(ids))
-	for _, id := range ids {
-		item, err := storage.ReadItem(id, key)
-		if err != nil {
-			// Skip items that fail to decrypt
-			continue
-		}
-		entries = append(entries, itemEntry{id: id, item: item})
+	entries := make([]itemEntry, 0, len(index.Entries))
+	for id, entry := range index.Entries {
+		entries = append(entries, itemEntry{id: id, entry: entry})
 	}
 
 	// Sort by modification time (newest first)
 	sort.Slice(entries, func(i, j int) bool {
-		return entries[i].item.Modified.After(entries[j].item.Modified)
+		return entries[i].entry.Modified.After(entries[j].entry.Modified)
 	})
 
 	// Print all items
-	for _, entry := range entries {
-		line := ui.FormatItem(entry.id, entry.item.Title, entry.item.Tags, "it#")
+	for _, e := range entries {
+		line := ui.FormatItem(e.id, e.entry.Title, e.entry.Tags, "it#")
 
 		// Use angle brackets for binary items
-		if entry.item.Type == storage.TypeBinary {
+		if e.entry.Type == storage.TypeBinary {
 			// Replace [id] with <id>
-			line = strings.Replace(line, "["+entry.id+"]", "<"+entry.id+">", 1)
+			line = strings.Replace(line, "["+e.id+"]", "<"+e.id+">", 1)
 		}
 
 		fmt.Println(line)
@@ -1,7 +1,22 @@
 package commands
 
-import "github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+import (
+	"fmt"
 
-func HandleLock() error {
-	return crypto.ClearSession()
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+)
+
+// HandleLock clears the cached key for the active vault in this terminal,
+// or every cached key (all terminals, all vaults, the agent's too) with all.
+func HandleLock(all bool) error {
+	if !all {
+		return crypto.ClearSession()
+	}
+
+	cleared, err := crypto.ClearAllSessions()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("✓ Locked %d session(s)\n", cleared)
+	return nil
 }
@@ -0,0 +1,104 @@
+package commands
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/ui"
+)
+
+// HandleLockItem double-locks an item's content under its own password, or with
+// remove, takes the lock off again. Title and tags stay readable with the vault key.
+func HandleLockItem(args []string, remove bool) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: dredge lock-item [--remove] <id>")
+	}
+
+	ids, err := ResolveArgs(args)
+	if err != nil {
+		return err
+	}
+	id := ids[0]
+
+	key, err := crypto.GetKeyWithVerification()
+	if err != nil {
+		return fmt.Errorf("key error: %w", err)
+	}
+
+	item, err := storage.ReadItem(id, key)
+	if err != nil {
+		return fmt.Errorf("failed to read item: %w", err)
+	}
+
+	if remove {
+		if !item.IsLocked() {
+			return fmt.Errorf("[%s] is not double-locked", id)
+		}
+		password, err := ui.PromptPasswordCustom(fmt.Sprintf("Item password for [%s]: ", id))
+		if err != nil {
+			return fmt.Errorf("failed to prompt for password: %w", err)
+		}
+		if err := storage.UnlockItem(id, item, password, key); err != nil {
+			return err
+		}
+		fmt.Printf("✓ [%s] %s is no longer double-locked\n", id, item.Title)
+		warnIfUnpushed()
+		return nil
+	}
+
+	if item.IsLocked() {
+		return fmt.Errorf("[%s] is already double-locked", id)
+	}
+	password, err := ui.PromptPasswordWithConfirmationCustom(fmt.Sprintf("Item password for [%s]: ", id), "Retype item password: ")
+	if err != nil {
+		return fmt.Errorf("failed to get item password: %w", err)
+	}
+	if err := storage.LockItem(id, item, password, key); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ [%s] %s is double-locked (its password is asked every time, never cached)\n", id, item.Title)
+	warnIfUnpushed()
+	return nil
+}
+
+// openLockedItem prompts for the item password of a double-locked item and fills in its
+// content. Returns the item key, which binary items need to read their blob. The password
+// is never cached. Items that are not locked are returned as they are, with a nil key.
+func openLockedItem(id string, item *storage.Item) ([]byte, error) {
+	if !item.IsLocked() {
+		return nil, nil
+	}
+	password, err := ui.PromptPasswordCustom(fmt.Sprintf("Item password for [%s]: ", id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to prompt for password: %w", err)
+	}
+	return storage.OpenContent(item, password)
+}
+
+// openBlob opens the content of a binary item, through its double lock if it has one.
+func openBlob(id string, key, itemKey []byte) (io.ReadCloser, error) {
+	if itemKey != nil {
+		return storage.OpenLockedBlob(id, key, itemKey)
+	}
+	return storage.OpenStorageBlob(id, key)
+}
+
+// saveLockedLinkEdits prompts for the item password of a linked double-locked item to
+// save the edits made to its linked file, which are not synced on read like other items.
+// Call it before the link is removed, or the edits are lost.
+func saveLockedLinkEdits(id string, item *storage.Item, key []byte) error {
+	if !item.IsLocked() || !storage.SpawnedFileChanged(id) {
+		return nil
+	}
+	password, err := ui.PromptPasswordCustom(fmt.Sprintf("Item password for [%s] (to save edits to its linked file): ", id))
+	if err != nil {
+		return fmt.Errorf("failed to prompt for password: %w", err)
+	}
+	if err := storage.SaveLockedLinkEdits(id, item, password, key); err != nil {
+		return fmt.Errorf("failed to save edits to the linked file of [%s]: %w", id, err)
+	}
+	return nil
+}
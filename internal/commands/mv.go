@@ -2,10 +2,11 @@ package commands
 
 import (
 	"fmt"
-	"os"
 	"regexp"
 
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/ui"
 )
 
 var idPattern = regexp.MustCompile(`^[a-zA-Z0-9]{3}$`)
@@ -46,20 +47,33 @@ func HandleMove(args []string) error {
 		return fmt.Errorf("item [%s] already exists (cannot overwrite)", newID)
 	}
 
-	// Get file paths
-	oldPath, err := storage.GetItemPath(oldID)
+	// Items are bound to their ID, so renaming re-encrypts and needs the key
+	key, err := crypto.GetKeyWithVerification()
 	if err != nil {
-		return fmt.Errorf("failed to get old item path: %w", err)
-	}
-
-	newPath, err := storage.GetItemPath(newID)
-	if err != nil {
-		return fmt.Errorf("failed to get new item path: %w", err)
+		return fmt.Errorf("failed to get key: %w", err)
 	}
 
 	// If item is linked, unlink first (saves target path for re-linking)
-	var linkTarget string
+	var linkTarget, password string
 	if storage.IsLinked(oldID) {
+		item, err := storage.ReadItem(oldID, key)
+		if err != nil {
+			return fmt.Errorf("failed to read item: %w", err)
+		}
+		// A double-locked item needs its password to save edits and spawn it again
+		if item.IsLocked() {
+			password, err = ui.PromptPasswordCustom(fmt.Sprintf("Item password for [%s]: ", oldID))
+			if err != nil {
+				return fmt.Errorf("failed to prompt for password: %w", err)
+			}
+			if _, err := storage.OpenContent(item, password); err != nil {
+				return err
+			}
+			if err := storage.SaveLockedLinkEdits(oldID, item, password, key); err != nil {
+				return fmt.Errorf("failed to save edits to the linked file: %w", err)
+			}
+		}
+
 		// Get current link target before unlinking
 		target, exists := storage.GetLinkedPath(oldID)
 		if !exists {
@@ -73,27 +87,17 @@ func HandleMove(args []string) error {
 		}
 	}
 
-	// Rename the encrypted item file
-	if err := os.Rename(oldPath, newPath); err != nil {
-		return fmt.Errorf("failed to rename item file: %w", err)
-	}
-
-	// Rename storage blob if present (binary items)
-	oldBlobPath, err := storage.GetStoragePath(oldID)
-	if err == nil {
-		newBlobPath, err := storage.GetStoragePath(newID)
-		if err == nil {
-			if _, err := os.Stat(oldBlobPath); err == nil {
-				os.Rename(oldBlobPath, newBlobPath) // Best-effort; non-fatal
-			}
-		}
+	// Re-encrypt the item (and storage blob, if any) under the new ID
+	if err := storage.MoveItem(oldID, newID, key); err != nil {
+		return fmt.Errorf("failed to rename item: %w", err)
 	}
 
 	// If item was linked, re-link with new ID to same target
 	if linkTarget != "" {
-		if err := storage.Link(newID, linkTarget, true); err != nil {
+		err := relink(newID, linkTarget, password, key)
+		if err != nil {
 			// Try to rollback the rename
-			os.Rename(newPath, oldPath)
+			_ = storage.MoveItem(newID, oldID, key)
 			return fmt.Errorf("failed to re-link after rename (rolled back): %w", err)
 		}
 	}
@@ -102,3 +106,18 @@ func HandleMove(args []string) error {
 	warnIfUnpushed()
 	return nil
 }
+
+// relink links a renamed item to its old target again. password opens it if it is
+// double-locked.
+func relink(id, targetPath, password string, key []byte) error {
+	item, err := storage.ReadItem(id, key)
+	if err != nil {
+		return err
+	}
+	if item.IsLocked() {
+		if _, err := storage.OpenContent(item, password); err != nil {
+			return err
+		}
+	}
+	return storage.Link(id, item, targetPath, true)
+}
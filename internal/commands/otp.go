@@ -0,0 +1,173 @@
+package commands
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"golang.org/x/term"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/totp"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/ui"
+)
+
+// ============================================================================
+// otp
+// ============================================================================
+//
+// 2FA seeds live in an item's otp field (see storage/fields.go): an otpauth:// URI or a
+// bare base32 seed. Items from before fields existed work too, when their whole content
+// is an otpauth URI.
+
+// otpTag is put on items created by 'dredge otp --import'.
+const otpTag = "otp"
+
+// HandleOTP prints (or copies) the current code of an item's TOTP seed.
+func HandleOTP(args []string, copyCode bool) error {
+	// --copy may also follow the ID (flexible positioning)
+	var positional []string
+	for _, arg := range args {
+		if arg == "--copy" || arg == "-c" {
+			copyCode = true
+		} else {
+			positional = append(positional, arg)
+		}
+	}
+	args = positional
+
+	if len(args) != 1 {
+		return fmt.Errorf("usage: dredge otp <id> [--copy]")
+	}
+	ids, err := ResolveArgs(args)
+	if err != nil {
+		return err
+	}
+	id := ids[0]
+
+	key, err := crypto.GetKeyWithVerification()
+	if err != nil {
+		return fmt.Errorf("failed to get key: %w", err)
+	}
+	item, err := storage.ReadItem(id, key)
+	if err != nil {
+		return fmt.Errorf("failed to read item: %w", err)
+	}
+	if item.Type == storage.TypeBinary {
+		return fmt.Errorf("<%s> is a binary item: TOTP seeds live in text items", id)
+	}
+	if _, err := openLockedItem(id, item); err != nil {
+		return err
+	}
+
+	seed, err := otpSeed(id, item)
+	if err != nil {
+		return err
+	}
+	otpKey, err := totp.Parse(seed)
+	if err != nil {
+		return fmt.Errorf("[%s]: %w", id, err)
+	}
+
+	now := time.Now()
+	code, left := otpKey.Code(now), int(otpKey.Remaining(now).Seconds())
+
+	if copyCode {
+		if err := writeToClipboard(code); err != nil {
+			return fmt.Errorf("clipboard error: %w", err)
+		}
+		fmt.Printf("Copied code for %s to clipboard (%ds left)\n", ui.FormatItem(id, item.Title, item.Tags, "it#"), left)
+		return nil
+	}
+
+	// Scripts get the code alone
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		fmt.Println(code)
+		return nil
+	}
+	fmt.Printf("%s  (%ds left)\n", code, left)
+	return nil
+}
+
+// otpSeed finds the TOTP seed of an opened item: its first otp field, or its content
+// if that is an otpauth URI.
+func otpSeed(id string, item *storage.Item) (string, error) {
+	for _, f := range item.Content.Fields {
+		if f.Type != storage.FieldOTP {
+			continue
+		}
+		if f.Value == "" {
+			return "", fmt.Errorf("[%s] field %s is empty", id, f.Name)
+		}
+		return f.Value, nil
+	}
+	if text := strings.TrimSpace(item.Content.Text); strings.HasPrefix(strings.ToLower(text), "otpauth://") && !strings.ContainsAny(text, "\n") {
+		return text, nil
+	}
+	return "", fmt.Errorf("[%s] has no otp field (add a line 'otp (otp): <otpauth URI or base32 seed>' with 'dredge edit %s')", id, id)
+}
+
+// HandleOTPImport creates one item per otpauth URI read from r (one per line; blank
+// lines and #comments are skipped). Nothing is created if any line is invalid.
+func HandleOTPImport(r io.Reader) error {
+	type entry struct {
+		uri string
+		key *totp.Key
+	}
+	var entries []entry
+
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(strings.ToLower(line), "otpauth://") {
+			return fmt.Errorf("line %d: not an otpauth:// URI", n)
+		}
+		otpKey, err := totp.Parse(line)
+		if err != nil {
+			return fmt.Errorf("line %d: %w", n, err)
+		}
+		entries = append(entries, entry{line, otpKey})
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read input: %w", err)
+	}
+	if len(entries) == 0 {
+		return fmt.Errorf("no otpauth URIs on stdin (one per line)")
+	}
+
+	key, err := crypto.GetKeyWithVerification()
+	if err != nil {
+		return fmt.Errorf("failed to get key: %w", err)
+	}
+
+	for _, e := range entries {
+		title := e.key.Label()
+		if title == "" {
+			title = "OTP"
+		}
+		item := storage.NewTextItem(title, "", []string{otpTag})
+		if e.key.Account != "" {
+			item.Content.Fields = append(item.Content.Fields, storage.Field{Name: "username", Type: storage.FieldText, Value: e.key.Account})
+		}
+		item.Content.Fields = append(item.Content.Fields, storage.Field{Name: "otp", Type: storage.FieldOTP, Value: e.uri})
+
+		id, err := newItemID()
+		if err != nil {
+			return err
+		}
+		if err := storage.CreateItem(id, item, key); err != nil {
+			return fmt.Errorf("failed to create item: %w", err)
+		}
+		fmt.Println("+ " + ui.FormatItem(id, item.Title, item.Tags, "it#"))
+	}
+
+	warnIfUnpushed()
+	return nil
+}
@@ -5,6 +5,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/BurntSushi/toml"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
@@ -21,9 +23,45 @@ const (
 	keyOldName        = ".dredge-key.old"
 )
 
+// PasswdOptions controls key derivation changes made by HandlePasswd.
+// When any KDF option or AddKeyfile is set, the password is kept and only the slot is rewrapped.
+type PasswdOptions struct {
+	Calibrate  bool          // benchmark this machine to pick the iteration count
+	UnlockTime time.Duration // calibration target (default crypto.DefaultUnlockTime)
+	KDFTime    uint32        // Argon2id iterations
+	KDFMemory  uint32        // Argon2id memory in MiB
+	KDFThreads uint8         // Argon2id parallelism
+	AddKeyfile string        // require this keyfile from now on (created if missing)
+}
+
+// changesKDF reports whether any KDF option was requested.
+func (o PasswdOptions) changesKDF() bool {
+	return o.Calibrate || o.KDFTime != 0 || o.KDFMemory != 0 || o.KDFThreads != 0
+}
+
+// resolveKDFParams applies the requested options on top of the current parameters.
+func (o PasswdOptions) resolveKDFParams(current crypto.KDFParams) crypto.KDFParams {
+	params := current
+	if o.Calibrate {
+		fmt.Fprintln(os.Stderr, "Calibrating key derivation for this machine...")
+		params = crypto.CalibrateKDF(o.UnlockTime)
+	}
+	if o.KDFTime != 0 {
+		params.Time = o.KDFTime
+	}
+	if o.KDFMemory != 0 {
+		params.Memory = o.KDFMemory * 1024
+	}
+	if o.KDFThreads != 0 {
+		params.Threads = o.KDFThreads
+	}
+	return params
+}
+
 // HandlePasswd handles password change command
-// Flow: verify current password → prompt new password → re-encrypt all items → atomic swap
-func HandlePasswd() error {
+// Flow: verify current password → prompt new password (or new KDF cost) → rewrap the vault data key.
+// Vaults without a data key yet are re-encrypted once (items.tmp/ + atomic swap) to get one.
+func HandlePasswd(opts PasswdOptions) error {
 	fmt.Fprintln(os.Stderr, "Changing password for Dredge.")
 
 	// 1. Always prompt for current password (bypass cache for security)
@@ -32,32 +70,125 @@ func HandlePasswd() error {
 		return fmt.Errorf("failed to prompt for current password: %w", err)
 	}
 
-	// 2. Derive current master key (also verifies the password)
-	currentKey, err := crypto.DeriveKeyFromVault(currentPassword)
+	// 2. Derive current master key (also verifies the password and finds its key slot)
+	vf, err := crypto.ReadVerifyFile()
+	if err != nil {
+		return err
+	}
+
+	keyfile, err := crypto.LoadKeyfile()
+	if err != nil {
+		return err
+	}
+	currentKey, slotIdx, err := vf.UnlockSlotWithKeyfile(currentPassword, keyfile)
 	if err != nil {
 		return fmt.Errorf("current password verification failed: %w", err)
 	}
+	slot := vf.Slots[slotIdx]
 
-	// 3. Prompt for new password (with confirmation)
-	newPassword, err := ui.PromptPasswordWithConfirmationCustom("New password: ", "Retype new password: ")
+	// A keyfile slot keeps its keyfile; --add-keyfile sets or replaces it
+	newKeyfile := keyfile
+	if !slot.Keyfile {
+		newKeyfile = nil
+	}
+	if opts.AddKeyfile != "" {
+		vaultDir, err := storage.GetDredgeDir()
+		if err != nil {
+			return fmt.Errorf("failed to get dredge directory: %w", err)
+		}
+		if newKeyfile, err = prepareKeyfile(opts.AddKeyfile, vaultDir); err != nil {
+			return err
+		}
+	}
+
+	// 3. Prompt for new password (with confirmation), or keep it when only the KDF cost or keyfile changes
+	newPassword := currentPassword
+	params := slot.Params
+	if opts.changesKDF() {
+		params = opts.resolveKDFParams(slot.Params)
+		if err := params.Validate(); err != nil {
+			return err
+		}
+		if params.Time < slot.Params.Time || params.Memory < slot.Params.Memory {
+			fmt.Fprintf(os.Stderr, "Warning: new key derivation cost is lower than current (%s)\n", slot.Params)
+		}
+		fmt.Fprintf(os.Stderr, "Key derivation: %s → %s\n", slot.Params, params)
+	} else if opts.AddKeyfile == "" {
+		newPassword, err = ui.PromptPasswordWithConfirmationCustom("New password: ", "Retype new password: ")
+		if err != nil {
+			return fmt.Errorf("failed to get new password: %w", err)
+		}
+
+		if newPassword == currentPassword {
+			return fmt.Errorf("new password must be different from current password")
+		}
+	}
+
+	// 4. Vaults with a data key: rewrap it in the same slot, items and other slots stay untouched
+	if vf.HasDataKey() {
+		newSlot, err := crypto.NewPasswordSlotWithKeyfile(slot.Label, currentKey, newPassword, newKeyfile, params)
+		if err != nil {
+			return fmt.Errorf("failed to generate new verification: %w", err)
+		}
+		vf.Slots[slotIdx] = *newSlot
+
+		if err := writeKeyFile(vf, currentKey); err != nil {
+			return err
+		}
+		if len(vf.Slots) > 1 {
+			fmt.Fprintf(os.Stderr, "Updated key slot '%s'\n", slot.Label)
+		}
+		if opts.AddKeyfile != "" {
+			fmt.Fprintf(os.Stderr, "Key slot '%s' now requires the keyfile (pass --keyfile or set %s)\n", slot.Label, crypto.KeyfileEnvVar)
+			warnPasswordOnlySlots(vf)
+		}
+		warnIfUnpushed()
+		return nil
+	}
+
+	// Older vaults encrypt items with the password key itself: move them to a random
+	// data key once, so later password changes only rewrap .dredge-key
+	fmt.Fprintln(os.Stderr, "Migrating vault to a random data key (one-time re-encryption of all items)...")
+	newKeyFileBytes, newKey, err := crypto.NewVerificationFileBytesWithKeyfile(newPassword, newKeyfile, params)
 	if err != nil {
-		return fmt.Errorf("failed to get new password: %w", err)
+		return fmt.Errorf("failed to generate new verification: %w", err)
 	}
 
-	if newPassword == currentPassword {
-		return fmt.Errorf("new password must be different from current password")
+	if err := reencryptVault(currentKey, newKey, newKeyFileBytes); err != nil {
+		return err
 	}
 
-	// 4. Get all item IDs
-	itemIDs, err := storage.ListItemIDs()
+	warnIfUnpushed()
+	return nil
+}
+
+// warnPasswordOnlySlots lists password slots that still unlock without a keyfile,
+// since any one of them bypasses the second factor.
+func warnPasswordOnlySlots(vf *crypto.VerifyFile) {
+	for _, s := range vf.Slots {
+		if s.Type == crypto.SlotPassword && !s.Keyfile {
+			fmt.Fprintf(os.Stderr, "Warning: key slot '%s' still unlocks with a password alone\n", s.Label)
+		}
+	}
+}
+
+// reencryptVault re-encrypts every item and storage blob from currentKey to newKey and
+// installs newKeyFileBytes as .dredge-key. Writes go to items.tmp/ and storage.tmp/ first,
+// then directories are swapped so an interrupted run never leaves a half-converted vault.
+func reencryptVault(currentKey, newKey, newKeyFileBytes []byte) error {
+	// The manifest is re-signed under the new key afterwards, so it must vouch for the vault first
+	check, err := storage.CheckVaultManifest(currentKey, nil)
 	if err != nil {
-		return fmt.Errorf("failed to list items: %w", err)
+		return fmt.Errorf("failed to check vault manifest: %w", err)
+	}
+	if len(check.Problems) > 0 {
+		return fmt.Errorf("vault manifest does not match the vault (run 'dredge fsck' first)")
 	}
 
-	// Generate new key file bytes and derive new master key
-	newKeyFileBytes, newKey, err := crypto.NewVerificationFileBytes(newPassword)
+	// Get all item IDs
+	itemIDs, err := storage.ListItemIDs()
 	if err != nil {
-		return fmt.Errorf("failed to generate new verification: %w", err)
+		return fmt.Errorf("failed to list items: %w", err)
 	}
 
 	if len(itemIDs) == 0 {
@@ -65,7 +196,7 @@ func HandlePasswd() error {
 		if err := updatePasswordVerification(newKeyFileBytes, newKey); err != nil {
 			return fmt.Errorf("failed to update password verification: %w", err)
 		}
-		warnIfUnpushed()
+		sealManifest(newKey)
 		return nil
 	}
 
@@ -113,7 +244,7 @@ func HandlePasswd() error {
 		}
 
 		// Encrypt with new key
-		encryptedData, err := crypto.Encrypt(buf.Bytes(), newKey)
+		encryptedData, err := storage.SealPayload(buf.Bytes(), newKey, crypto.AssociatedData(crypto.KindItem, id))
 		if err != nil {
 			_ = os.RemoveAll(tmpDir)
 			return fmt.Errorf("failed to encrypt item %s: %w", id, err)
@@ -168,23 +299,11 @@ func HandlePasswd() error {
 				continue
 			}
 			id := entry.Name()
-			blobData, err := storage.ReadStorageBlob(id, currentKey)
-			if err != nil {
-				_ = os.RemoveAll(tmpDir)
-				_ = os.RemoveAll(storageTmpDir)
-				return fmt.Errorf("failed to decrypt storage blob %s: %w", id, err)
-			}
-			encrypted, err := crypto.Encrypt(blobData, newKey)
-			if err != nil {
+			if err := reencryptBlob(id, filepath.Join(storageTmpDir, id), currentKey, newKey); err != nil {
 				_ = os.RemoveAll(tmpDir)
 				_ = os.RemoveAll(storageTmpDir)
 				return fmt.Errorf("failed to re-encrypt storage blob %s: %w", id, err)
 			}
-			if err := os.WriteFile(filepath.Join(storageTmpDir, id), encrypted, 0600); err != nil {
-				_ = os.RemoveAll(tmpDir)
-				_ = os.RemoveAll(storageTmpDir)
-				return fmt.Errorf("failed to write storage blob %s: %w", id, err)
-			}
 		}
 	}
 
@@ -206,6 +325,14 @@ func HandlePasswd() error {
 	}
 
 	// 13. ATOMIC SWAP (the critical moment)
+	// items/ and storage/ move together: blobs left under the old key would be unreadable
+	hasStorage := len(storageEntries) > 0
+	restoreStorage := func() {
+		if hasStorage {
+			_ = os.Rename(storageOldDir, storageDir)
+		}
+	}
+
 	// Rename original items/ to items.old
 	if err := os.Rename(itemsDir, oldDir); err != nil {
 		_ = os.RemoveAll(tmpDir)
@@ -214,25 +341,35 @@ func HandlePasswd() error {
 		return fmt.Errorf("failed to backup items directory: %w", err)
 	}
 
+	// Rename storage/ to storage.old
+	if hasStorage {
+		if err := os.Rename(storageDir, storageOldDir); err != nil {
+			_ = os.Rename(oldDir, itemsDir)
+			_ = os.RemoveAll(tmpDir)
+			_ = os.RemoveAll(storageTmpDir)
+			_ = os.Remove(keyTmpPath)
+			return fmt.Errorf("failed to backup storage directory (restored backup): %w", err)
+		}
+	}
+
 	// Rename items.tmp/ to items/
 	if err := os.Rename(tmpDir, itemsDir); err != nil {
 		// Critical failure - try to restore
 		_ = os.Rename(oldDir, itemsDir)
+		restoreStorage()
 		_ = os.RemoveAll(storageTmpDir)
 		_ = os.Remove(keyTmpPath)
 		return fmt.Errorf("failed to activate new items directory (restored backup): %w", err)
 	}
 
-	// Swap storage/ if we have a tmp (best-effort; blobs are still readable with old key if this fails)
-	if len(storageEntries) > 0 {
-		if err := os.Rename(storageDir, storageOldDir); err != nil {
-			fmt.Fprintf(os.Stderr, "Warning: failed to backup storage directory: %v\n", err)
-		} else if err := os.Rename(storageTmpDir, storageDir); err != nil {
-			// Try to restore storage
-			_ = os.Rename(storageOldDir, storageDir)
-			fmt.Fprintf(os.Stderr, "Warning: failed to activate new storage directory: %v\n", err)
-		} else {
-			_ = os.RemoveAll(storageOldDir)
+	// Rename storage.tmp/ to storage/
+	if hasStorage {
+		if err := os.Rename(storageTmpDir, storageDir); err != nil {
+			_ = os.Rename(itemsDir, tmpDir)
+			_ = os.Rename(oldDir, itemsDir)
+			restoreStorage()
+			_ = os.Remove(keyTmpPath)
+			return fmt.Errorf("failed to activate new storage directory (restored backup): %w", err)
 		}
 	}
 
@@ -251,6 +388,7 @@ func HandlePasswd() error {
 
 	// 14. Success! Delete backups
 	_ = os.RemoveAll(oldDir)
+	_ = os.RemoveAll(storageOldDir)
 	_ = os.Remove(keyOldPath)
 
 	// 14. Update session cache with new key
@@ -258,11 +396,45 @@ func HandlePasswd() error {
 		fmt.Fprintf(os.Stderr, "Warning: failed to update session cache: %v\n", err)
 	}
 
-	warnIfUnpushed()
+	// The trash is local, outside the swap: re-encrypt it too so undo keeps working
+	failed, err := storage.ReencryptTrash(currentKey, newKey)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to re-encrypt the trash: %v\n", err)
+	} else if len(failed) > 0 {
+		fmt.Fprintf(os.Stderr, "Warning: trashed item(s) %s stay under the old key and can no longer be restored\n", strings.Join(failed, ", "))
+	}
+
+	sealManifest(newKey)
 	return nil
 }
 
-// updatePasswordVerification updates the .dredge-key file when there are no items to re-encrypt
+// sealManifest re-signs the vault manifest after every file was re-encrypted.
+func sealManifest(key []byte) {
+	if err := storage.SealVaultManifest(key); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update vault manifest: %v (run 'dredge fsck --repair')\n", err)
+	}
+}
+
+// reencryptBlob streams storage/id from currentKey into dstPath under newKey.
+func reencryptBlob(id, dstPath string, currentKey, newKey []byte) error {
+	blob, err := storage.OpenStorageBlob(id, currentKey)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	out, err := os.OpenFile(dstPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	_, err = storage.SealStream(out, blob, newKey, crypto.AssociatedData(crypto.KindBlob, id))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+// updatePasswordVerification atomically replaces .dredge-key when no items need re-encrypting
 func updatePasswordVerification(newKeyFileBytes []byte, newKey []byte) error {
 	keyPath, err := crypto.GetVerifyFilePath()
 	if err != nil {
@@ -2,7 +2,10 @@ package commands
 
 import (
 	"fmt"
+	"os"
+	"strings"
 
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/git"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
 )
@@ -15,5 +18,83 @@ func HandlePull(args []string) error {
 	}
 
 	// Pull changes
-	return git.Pull(dredgeDir)
+	if err := git.Pull(dredgeDir); err != nil {
+		return err
+	}
+	return verifyPulledVault(dredgeDir)
+}
+
+// verifyPulledVault checks what was pulled against the vault manifest: the remote's
+// manifest must be authentic and no older than one this machine saw, and every file
+// must match it except those changed locally, which are then added back on top.
+func verifyPulledVault(dredgeDir string) error {
+	upstream, ok := git.UpstreamFile(dredgeDir, storage.VaultManifestFileName)
+	if !ok {
+		if storage.HasSeenManifest() {
+			return manifestAlarm([]string{"the remote has no vault manifest, but this machine has seen one (rolled back, or pushed by an old dredge?)"})
+		}
+		return nil // The vault never had a manifest
+	}
+
+	key, err := crypto.GetKeyWithVerification()
+	if err != nil {
+		return fmt.Errorf("key error: %w", err)
+	}
+
+	if err := storage.AcceptUpstreamManifest(key, upstream); err != nil {
+		if !keyOpensVault(key) {
+			// A teammate ran 'dredge rekey': the session still holds the old key
+			return fmt.Errorf("the pulled vault is encrypted with a different key (rekeyed?) - run 'dredge lock' and pull again")
+		}
+		return manifestAlarm([]string{err.Error()})
+	}
+
+	changed, err := git.LocalChanges(dredgeDir)
+	if err != nil {
+		return err
+	}
+	exempt := make(map[string]bool)
+	var local []string
+	for _, path := range changed {
+		if strings.HasPrefix(path, "items/") || strings.HasPrefix(path, "storage/") {
+			exempt[path] = true
+			local = append(local, path)
+		}
+	}
+
+	check, err := storage.CheckVaultManifest(key, exempt)
+	if err != nil {
+		return fmt.Errorf("failed to check vault manifest: %w", err)
+	}
+	if len(check.Problems) > 0 {
+		return manifestAlarm(check.Problems)
+	}
+
+	if err := storage.UpdateVaultManifest(key, local...); err != nil {
+		return fmt.Errorf("failed to update vault manifest: %w", err)
+	}
+	return nil
+}
+
+// keyOpensVault reports whether key decrypts the first item of the vault (true if it has none).
+func keyOpensVault(key []byte) bool {
+	ids, err := storage.ListItemIDs()
+	if err != nil || len(ids) == 0 {
+		return true
+	}
+	_, err = storage.ReadItemStrict(ids[0], key)
+	return err == nil
+}
+
+// manifestAlarm prints manifest problems where they can't be missed and returns the error.
+func manifestAlarm(problems []string) error {
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "!!! WARNING: the pulled vault does not match its manifest !!!")
+	for _, p := range problems {
+		fmt.Fprintf(os.Stderr, "  ✗ %s\n", p)
+	}
+	fmt.Fprintln(os.Stderr, "Someone with write access to the remote may have deleted or rolled back items.")
+	fmt.Fprintln(os.Stderr, "Inspect 'git log' in the vault before trusting it, and don't push until you have.")
+	fmt.Fprintln(os.Stderr)
+	return fmt.Errorf("vault manifest check failed")
 }
@@ -2,6 +2,7 @@ package commands
 
 import (
 	"fmt"
+	"os"
 
 	"github.com/DeprecatedLuar/dredge-cargo/internal/git"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
@@ -15,5 +16,16 @@ func HandlePush(args []string) error {
 	}
 
 	// Push changes
-	return git.Push(dredgeDir)
+	return pushVault(dredgeDir)
+}
+
+// pushVault pushes and records the pushed manifest as the high-water mark.
+func pushVault(dredgeDir string) error {
+	if err := git.Push(dredgeDir); err != nil {
+		return err
+	}
+	if err := storage.RecordPushedManifest(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to record vault manifest counter: %v\n", err)
+	}
+	return nil
 }
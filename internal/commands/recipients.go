@@ -0,0 +1,137 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+)
+
+// HandleKeygen creates a personal X25519 identity file and prints its public key.
+// Default location: ~/.config/dredge/identity. Never overwrites an existing file.
+func HandleKeygen(output string) error {
+	path := output
+	if path == "" {
+		var err error
+		if path, err = crypto.DefaultIdentityPath(); err != nil {
+			return err
+		}
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return fmt.Errorf("identity file already exists: %s", path)
+	}
+
+	priv, err := crypto.GenerateIdentity()
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create identity directory: %w", err)
+	}
+	// O_EXCL: never clobber an identity created concurrently
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create identity file: %w", err)
+	}
+	if _, err := f.Write(crypto.IdentityFileBytes(priv)); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write identity file: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write identity file: %w", err)
+	}
+
+	fmt.Fprintf(os.Stderr, "✓ Identity written to %s\n", path)
+	fmt.Fprintln(os.Stderr, "Share the public key below; ask a vault member to run 'dredge recipients add <key>'.")
+	fmt.Println(crypto.EncodeRecipient(priv.PublicKey()))
+	return nil
+}
+
+// HandleRecipientsAdd wraps the vault key for a teammate's public key.
+func HandleRecipientsAdd(args []string, label string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: dredge recipients add <dredge1...> [--label name]")
+	}
+
+	recipient, err := crypto.ParseRecipient(args[0])
+	if err != nil {
+		return err
+	}
+	if label == "" {
+		label = crypto.DefaultRecipientLabel(recipient)
+	}
+	if err := crypto.ValidateSlotLabel(label); err != nil {
+		return err
+	}
+
+	vf, dataKey, err := unlockKeyFile("Password: ")
+	if err != nil {
+		return err
+	}
+	if idx := vf.FindRecipient(recipient); idx >= 0 {
+		return fmt.Errorf("recipient is already on this vault (key slot '%s')", vf.Slots[idx].Label)
+	}
+
+	slot, err := crypto.NewRecipientSlot(label, dataKey, recipient)
+	if err != nil {
+		return err
+	}
+	if err := vf.AddSlot(*slot); err != nil {
+		return err
+	}
+	if err := writeKeyFile(vf, dataKey); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Added recipient '%s'\n", label)
+	warnIfUnpushed()
+	return nil
+}
+
+// HandleRecipientsList prints the public keys that can unlock the vault.
+func HandleRecipientsList() error {
+	vf, err := crypto.ReadVerifyFile()
+	if err != nil {
+		return err
+	}
+
+	for _, slot := range vf.Slots {
+		if slot.Type == crypto.SlotRecipient {
+			fmt.Printf("%-20s %s\n", slot.Label, slot.Recipient)
+		}
+	}
+	return nil
+}
+
+// HandleRecipientsRemove removes a recipient slot by label or public key.
+// Note: a removed teammate who kept a copy of the data key can still read old ciphertexts.
+func HandleRecipientsRemove(args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: dredge recipients remove <label|dredge1...>")
+	}
+
+	vf, dataKey, err := unlockKeyFile("Password: ")
+	if err != nil {
+		return err
+	}
+
+	idx, err := vf.FindRecipientSlot(args[0])
+	if err != nil {
+		return err
+	}
+	label := vf.Slots[idx].Label
+
+	if err := vf.RemoveSlot(label); err != nil {
+		return err
+	}
+	if err := writeKeyFile(vf, dataKey); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Removed recipient '%s'\n", label)
+	warnIfUnpushed()
+	return nil
+}
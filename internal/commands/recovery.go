@@ -0,0 +1,152 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/ui"
+)
+
+// wordsPerLine groups the printed recovery phrase so it is easy to copy onto paper.
+const wordsPerLine = 6
+
+// HandleRecoveryCreate generates a recovery key, stores it as a key slot and prints it once.
+// Flow: unlock with any existing password → random 256-bit key → recovery slot → print 24 words
+func HandleRecoveryCreate(args []string) error {
+	if len(args) > 1 {
+		return fmt.Errorf("usage: dredge recovery create [label]")
+	}
+	label := crypto.DefaultRecoveryLabel
+	if len(args) == 1 {
+		label = args[0]
+	}
+	if err := crypto.ValidateSlotLabel(label); err != nil {
+		return err
+	}
+
+	vf, dataKey, err := unlockKeyFile("Existing password: ")
+	if err != nil {
+		return err
+	}
+	if vf.FindSlot(label) >= 0 {
+		return fmt.Errorf("a key slot labelled '%s' already exists (remove it first with 'dredge key remove %s')", label, label)
+	}
+
+	entropy, err := crypto.GenerateRecoveryKey()
+	if err != nil {
+		return err
+	}
+	phrase, err := crypto.EncodeMnemonic(entropy)
+	if err != nil {
+		return err
+	}
+
+	slot, err := crypto.NewRecoverySlot(label, dataKey, entropy)
+	if err != nil {
+		return err
+	}
+	if err := vf.AddSlot(*slot); err != nil {
+		return err
+	}
+	if err := writeKeyFile(vf, dataKey); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(os.Stderr, "✓ Added recovery key slot '%s'\n\n", label)
+	fmt.Fprintln(os.Stderr, "Write these words down and keep them offline. They are shown only once,")
+	fmt.Fprintln(os.Stderr, "and anyone holding them can unlock this vault.")
+	fmt.Fprintln(os.Stderr)
+	printPhrase(phrase)
+	fmt.Fprintln(os.Stderr)
+	fmt.Fprintln(os.Stderr, "To use it: dredge recovery unlock")
+
+	warnIfUnpushed()
+	return nil
+}
+
+// HandleRecoveryUnlock unlocks the vault with a recovery phrase and sets a new password
+// on the given password slot (created if missing).
+func HandleRecoveryUnlock(slotLabel string) error {
+	if slotLabel == "" {
+		slotLabel = crypto.DefaultSlotLabel
+	}
+	if err := crypto.ValidateSlotLabel(slotLabel); err != nil {
+		return err
+	}
+
+	vf, err := crypto.ReadVerifyFile()
+	if err != nil {
+		return err
+	}
+
+	// Read like a password: the words are the whole secret, so they must not show on screen
+	phrase, err := ui.PromptPasswordCustom("Recovery phrase (hidden): ")
+	if err != nil {
+		return err
+	}
+	entropy, err := crypto.DecodeMnemonic(phrase)
+	if err != nil {
+		return err
+	}
+
+	dataKey, _, err := vf.UnlockRecovery(entropy)
+	if err != nil {
+		return err
+	}
+
+	idx := vf.FindSlot(slotLabel)
+	if idx >= 0 && vf.Slots[idx].Type != crypto.SlotPassword {
+		return fmt.Errorf("key slot '%s' is not a password slot", slotLabel)
+	}
+
+	password, err := ui.PromptPasswordWithConfirmationCustom(fmt.Sprintf("New password for '%s': ", slotLabel), "Retype password: ")
+	if err != nil {
+		return fmt.Errorf("failed to get new password: %w", err)
+	}
+
+	params := crypto.DefaultKDFParams
+	if idx >= 0 {
+		params = vf.Slots[idx].Params
+	}
+	// The slot requires the keyfile given with --keyfile, if any; a lost keyfile is dropped
+	keyfile, err := crypto.LoadKeyfile()
+	if err != nil {
+		return err
+	}
+	if idx >= 0 && vf.Slots[idx].Keyfile && keyfile == nil {
+		fmt.Fprintf(os.Stderr, "Warning: key slot '%s' no longer requires a keyfile (add one with 'dredge passwd --add-keyfile')\n", slotLabel)
+	}
+	slot, err := crypto.NewPasswordSlotWithKeyfile(slotLabel, dataKey, password, keyfile, params)
+	if err != nil {
+		return err
+	}
+	if idx >= 0 {
+		vf.Slots[idx] = *slot
+	} else if err := vf.AddSlot(*slot); err != nil {
+		return err
+	}
+
+	// Also caches the data key, so the vault stays unlocked in this terminal
+	if err := writeKeyFile(vf, dataKey); err != nil {
+		return err
+	}
+
+	fmt.Printf("✓ Password for key slot '%s' set; vault unlocked\n", slotLabel)
+	warnIfUnpushed()
+	return nil
+}
+
+// printPhrase prints a mnemonic as numbered words, wordsPerLine per line, to stderr
+// along with its warnings (so redirecting stdout can't split them).
+func printPhrase(phrase string) {
+	words := strings.Fields(phrase)
+	for i := 0; i < len(words); i += wordsPerLine {
+		var line []string
+		for j := i; j < i+wordsPerLine && j < len(words); j++ {
+			line = append(line, fmt.Sprintf("%2d. %-9s", j+1, words[j]))
+		}
+		fmt.Fprintln(os.Stderr, "  "+strings.TrimRight(strings.Join(line, " "), " "))
+	}
+}
@@ -0,0 +1,251 @@
+package commands
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/ui"
+)
+
+// HandleRekey replaces the vault data key with a new random one and re-encrypts every
+// item and blob under it. Passwords stay the same: each key slot is rewrapped, so anyone
+// holding a copy of the old data key (a session cache file, a stolen .dredge-key plus
+// password) can no longer read what is written from now on. Recovery slots need their
+// phrase to be rewrapped; dropRecovery removes them instead.
+// Flow: unlock → new data key → rewrap slots → confirm → items.tmp/ + storage.tmp/ → atomic swap
+func HandleRekey(dropRecovery bool) error {
+	vf, err := crypto.ReadVerifyFile()
+	if err != nil {
+		return err
+	}
+	if !vf.HasDataKey() {
+		return fmt.Errorf("this vault predates key slots - run 'dredge passwd' once to migrate it")
+	}
+
+	keyfile, err := crypto.LoadKeyfile()
+	if err != nil {
+		return err
+	}
+
+	// 1. Unlock with the identity file or a freshly prompted password (never the session cache)
+	currentKey, opened, password, err := unlockForRekey(vf, keyfile)
+	if err != nil {
+		return err
+	}
+
+	newKey, err := crypto.GenerateDataKey()
+	if err != nil {
+		return err
+	}
+
+	// 2. Rewrap every slot that can be opened without the old key
+	slots, err := rewrapSlots(vf, opened, password, keyfile, currentKey, newKey, dropRecovery)
+	if err != nil {
+		return err
+	}
+	vf.Slots = slots
+
+	// Whoever the rekey is meant to lock out must not be carried over by accident
+	if !confirmRekeySlots(slots) {
+		fmt.Println("Aborted.")
+		return nil
+	}
+
+	newKeyFileBytes, err := vf.Bytes()
+	if err != nil {
+		return err
+	}
+
+	// 3. Re-encrypt and swap items/, storage/ and .dredge-key together
+	fmt.Fprintln(os.Stderr, "Re-encrypting all items with a new data key...")
+	if err := reencryptVault(currentKey, newKey, newKeyFileBytes); err != nil {
+		return err
+	}
+
+	// Cached copies of the old key are useless now, drop them everywhere
+	if _, err := crypto.ClearAllSessions(); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to clear cached sessions: %v\n", err)
+	}
+	if err := crypto.CacheKey(newKey); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to update session cache: %v\n", err)
+	}
+
+	// The index is still encrypted with the old key: rebuild it now
+	if _, err := storage.LoadIndex(newKey); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to rebuild index: %v\n", err)
+	}
+
+	fmt.Println("✓ Vault re-encrypted with a new data key")
+	fmt.Fprintln(os.Stderr, "Note: older commits in git history are still readable with the old key")
+	warnIfUnpushed()
+	return nil
+}
+
+// unlockForRekey unlocks vf like unlockKeyFile, also returning the index of the slot
+// that opened it and, for a password slot, the password (needed to rewrap that slot).
+func unlockForRekey(vf *crypto.VerifyFile, keyfile []byte) ([]byte, int, string, error) {
+	identity, err := crypto.LoadIdentity()
+	if err != nil {
+		return nil, -1, "", err
+	}
+	if identity != nil && vf.FindRecipient(identity.PublicKey()) >= 0 {
+		dataKey, idx, err := vf.UnlockIdentity(identity)
+		if err != nil {
+			return nil, -1, "", err
+		}
+		fmt.Fprintf(os.Stderr, "Unlocked with identity (key slot '%s')\n", vf.Slots[idx].Label)
+		return dataKey, idx, "", nil
+	}
+
+	password, err := ui.PromptPasswordCustom("Current password: ")
+	if err != nil {
+		return nil, -1, "", fmt.Errorf("failed to prompt for password: %w", err)
+	}
+	dataKey, idx, err := vf.UnlockSlotWithKeyfile(password, keyfile)
+	if err != nil {
+		return nil, -1, "", fmt.Errorf("password verification failed: %w", err)
+	}
+	return dataKey, idx, password, nil
+}
+
+// rewrapSlots returns vf's slots wrapping newKey instead of currentKey. The slot that
+// was opened keeps its password; other password slots are asked for theirs (empty drops
+// the slot), recipients are rewrapped from their public key, and recovery slots are
+// asked for their phrase (or dropped, with dropRecovery).
+func rewrapSlots(vf *crypto.VerifyFile, opened int, password string, keyfile, currentKey, newKey []byte, dropRecovery bool) ([]crypto.KeySlot, error) {
+	var slots []crypto.KeySlot
+	for i, slot := range vf.Slots {
+		var rewrapped *crypto.KeySlot
+		var err error
+
+		switch slot.Type {
+		case crypto.SlotRecipient:
+			pub, perr := crypto.ParseRecipient(slot.Recipient)
+			if perr != nil {
+				return nil, fmt.Errorf("key slot '%s': %w", slot.Label, perr)
+			}
+			rewrapped, err = crypto.NewRecipientSlot(slot.Label, newKey, pub)
+
+		case crypto.SlotPassword:
+			slotPassword := password
+			if i != opened {
+				slotPassword, err = promptSlotPassword(vf, i, keyfile, currentKey)
+				if err != nil {
+					return nil, err
+				}
+				if slotPassword == "" {
+					fmt.Fprintf(os.Stderr, "Removing key slot '%s'\n", slot.Label)
+					continue
+				}
+			}
+			slotKeyfile := keyfile
+			if !slot.Keyfile {
+				slotKeyfile = nil
+			}
+			rewrapped, err = crypto.NewPasswordSlotWithKeyfile(slot.Label, newKey, slotPassword, slotKeyfile, slot.Params)
+
+		case crypto.SlotRecovery:
+			if dropRecovery {
+				fmt.Fprintf(os.Stderr, "Removing recovery key slot '%s' (run 'dredge recovery create' for a new one)\n", slot.Label)
+				continue
+			}
+			entropy, perr := promptRecoveryPhrase(vf, i, currentKey)
+			if perr != nil {
+				return nil, perr
+			}
+			rewrapped, err = crypto.NewRecoverySlot(slot.Label, newKey, entropy)
+
+		default:
+			return nil, fmt.Errorf("key slot '%s' has unknown type %q", slot.Label, slot.Type)
+		}
+
+		if err != nil {
+			return nil, fmt.Errorf("failed to rewrap key slot '%s': %w", slot.Label, err)
+		}
+		rewrapped.Created = slot.Created
+		slots = append(slots, *rewrapped)
+	}
+	return slots, nil
+}
+
+// promptSlotPassword asks for the password of the slot at idx until it opens the slot
+// or the answer is empty (the slot is dropped).
+func promptSlotPassword(vf *crypto.VerifyFile, idx int, keyfile, currentKey []byte) (string, error) {
+	label := vf.Slots[idx].Label
+	if vf.Slots[idx].Keyfile && keyfile == nil {
+		fmt.Fprintf(os.Stderr, "Warning: key slot '%s' needs its keyfile, which was not given\n", label)
+		return "", nil
+	}
+
+	for {
+		password, err := ui.PromptPasswordCustom(fmt.Sprintf("Password for key slot '%s' (empty to remove it): ", label))
+		if err != nil {
+			return "", fmt.Errorf("failed to prompt for password: %w", err)
+		}
+		if password == "" {
+			return "", nil
+		}
+
+		key, err := vf.UnlockSlotAt(idx, password, keyfile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v, try again\n", err)
+			continue
+		}
+		if !bytes.Equal(key, currentKey) {
+			return "", fmt.Errorf("key slot '%s' wraps a different key (corrupted .dredge-key?)", label)
+		}
+		return password, nil
+	}
+}
+
+// promptRecoveryPhrase asks for the phrase of the recovery slot at idx until it opens the
+// slot. Recovery slots can't be dropped by leaving it empty: the user's written-down
+// phrase would silently stop working, so that cancels the rekey unless --drop-recovery.
+func promptRecoveryPhrase(vf *crypto.VerifyFile, idx int, currentKey []byte) ([]byte, error) {
+	label := vf.Slots[idx].Label
+	for {
+		phrase, err := ui.PromptPasswordCustom(fmt.Sprintf("Recovery phrase for key slot '%s' (hidden, empty to cancel): ", label))
+		if err != nil {
+			return nil, err
+		}
+		if phrase == "" {
+			return nil, fmt.Errorf("rekey cancelled: recovery key slot '%s' needs its phrase to keep working (or pass --drop-recovery to remove it)", label)
+		}
+
+		entropy, err := crypto.DecodeMnemonic(phrase)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v, try again\n", err)
+			continue
+		}
+		key, err := vf.UnlockRecoveryAt(idx, entropy)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%v, try again\n", err)
+			continue
+		}
+		if !bytes.Equal(key, currentKey) {
+			return nil, fmt.Errorf("key slot '%s' wraps a different key (corrupted .dredge-key?)", label)
+		}
+		return entropy, nil
+	}
+}
+
+// confirmRekeySlots lists the key slots that will open the re-encrypted vault and asks
+// whether to go on. Slots to leave behind should be removed before rekeying.
+func confirmRekeySlots(slots []crypto.KeySlot) bool {
+	fmt.Fprintln(os.Stderr, "The re-encrypted vault will open with these key slots:")
+	for _, slot := range slots {
+		fmt.Fprintf(os.Stderr, "  %-20s %-10s %s\n", slot.Label, slot.Type, slot.Recipient)
+	}
+	fmt.Fprintln(os.Stderr, "Anyone who should lose access must not be listed (remove them first with 'dredge recipients remove' or 'dredge key remove').")
+
+	answer, err := ui.PromptLine("Re-encrypt the vault? [y/N] ")
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(answer)
+	return answer == "y" || answer == "yes"
+}
@@ -49,6 +49,9 @@ func HandleRemove(args []string) error {
 
 		// Unlink if item has active link (cleans up spawned file and symlink)
 		if storage.IsLinked(id) {
+			if err := saveLockedLinkEdits(id, item, key); err != nil {
+				return err
+			}
 			if err := storage.Unlink(id); err != nil {
 				fmt.Fprintf(os.Stderr, "Warning: failed to unlink item [%s]: %v\n", id, err)
 				// Continue with removal anyway
@@ -56,7 +59,7 @@ func HandleRemove(args []string) error {
 		}
 
 		// Move to trash
-		if err := storage.MoveToTrash(id); err != nil {
+		if err := storage.MoveToTrash(id, key); err != nil {
 			return fmt.Errorf("failed to move item [%s] to trash: %w", id, err)
 		}
 
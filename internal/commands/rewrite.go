@@ -0,0 +1,67 @@
+package commands
+
+import (
+	"fmt"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+)
+
+// HandleRewrite re-encrypts every item and blob that isn't written with the vault's
+// current settings (padding and compression on or off). Each file is replaced atomically, so an
+// interrupted rewrite can simply be run again.
+func HandleRewrite(args []string) error {
+	if len(args) != 0 {
+		return fmt.Errorf("usage: dredge rewrite")
+	}
+
+	key, err := crypto.GetKeyWithVerification()
+	if err != nil {
+		return fmt.Errorf("key error: %w", err)
+	}
+
+	itemIDs, err := storage.ListItemIDs()
+	if err != nil {
+		return fmt.Errorf("failed to list items: %w", err)
+	}
+	blobIDs, err := storage.ListStorageIDs()
+	if err != nil {
+		return err
+	}
+
+	items, blobs := 0, 0
+	for _, id := range itemIDs {
+		rewritten, err := storage.RewriteItem(id, key)
+		if err != nil {
+			return fmt.Errorf("failed to rewrite item [%s]: %w", id, err)
+		}
+		if rewritten {
+			items++
+		}
+	}
+	for _, id := range blobIDs {
+		rewritten, err := storage.RewriteStorageBlob(id, key)
+		if err != nil {
+			return fmt.Errorf("failed to rewrite blob <%s>: %w", id, err)
+		}
+		if rewritten {
+			blobs++
+		}
+	}
+
+	settings := "without padding"
+	if storage.Padding {
+		settings = "with padding"
+	}
+	if storage.Compression {
+		settings += ", compressed where it helps"
+	}
+	if items == 0 && blobs == 0 {
+		fmt.Printf("✓ Everything is already written %s\n", settings)
+		return nil
+	}
+
+	fmt.Printf("✓ Rewrote %d item(s) and %d blob(s) %s\n", items, blobs, settings)
+	warnIfUnpushed()
+	return nil
+}
@@ -19,30 +19,26 @@ func HandleSearch(query string, luck bool) error {
 		return fmt.Errorf("key error: %w", err)
 	}
 
-	// Load all item IDs
-	ids, err := storage.ListItemIDs()
+	// Load item metadata (one decryption, plus any items that changed since)
+	index, err := storage.LoadIndex(key)
 	if err != nil {
-		return fmt.Errorf("failed to list items: %w", err)
+		return fmt.Errorf("failed to load index: %w", err)
 	}
+	warnUnreadable(len(index.Unreadable))
 
-	if len(ids) == 0 {
+	if len(index.Entries) == 0 {
 		fmt.Println("No items found. Use 'dredge add' to create one.")
 		return nil
 	}
 
-	// Load and decrypt all items
-	items := make(map[string]*storage.Item)
-	for _, id := range ids {
+	// Perform search (items too big for the index are read for their content)
+	results := search.SearchIndex(index.Entries, query, func(id string) (string, error) {
 		item, err := storage.ReadItem(id, key)
 		if err != nil {
-			// Skip items that fail to decrypt (corrupted/wrong format)
-			continue
+			return "", err
 		}
-		items[id] = item
-	}
-
-	// Perform search
-	results := search.Search(items, query)
+		return storage.SearchableText(item), nil
+	})
 
 	// Display results
 	if len(results) == 0 {
@@ -51,15 +47,15 @@ func HandleSearch(query string, luck bool) error {
 	}
 
 	if luck {
-		return HandleView([]string{results[0].ID})
+		return HandleView([]string{results[0].ID}, ViewOptions{})
 	}
 
 	// Show list
 	for _, result := range results {
-		line := ui.FormatItem(result.ID, result.Item.Title, result.Item.Tags, "it#")
+		line := ui.FormatItem(result.ID, result.Entry.Title, result.Entry.Tags, "it#")
 
 		// Use angle brackets for binary items
-		if result.Item.Type == storage.TypeBinary {
+		if result.Entry.Type == storage.TypeBinary {
 			// Replace [id] with <id>
 			line = strings.Replace(line, "["+result.ID+"]", "<"+result.ID+">", 1)
 		}
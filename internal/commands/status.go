@@ -2,7 +2,9 @@ package commands
 
 import (
 	"fmt"
+	"time"
 
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/git"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
 )
@@ -14,6 +16,37 @@ func HandleStatus(args []string) error {
 		return fmt.Errorf("failed to get dredge directory: %w", err)
 	}
 
+	// Session first: it doesn't need a remote
+	info, err := crypto.GetSessionInfo()
+	if err != nil {
+		return fmt.Errorf("failed to check session: %w", err)
+	}
+	fmt.Printf("Session: %s\n\n", describeSession(info))
+
 	// Show status
 	return git.Status(dredgeDir)
 }
+
+// describeSession summarizes a session for status and unlock, e.g.
+// "unlocked (file), locks in 4m59s if idle, in 1h59m at the latest".
+func describeSession(info crypto.SessionInfo) string {
+	if !info.Active {
+		return "locked"
+	}
+
+	s := fmt.Sprintf("unlocked (%s)", info.Backend)
+	switch {
+	case info.Expires.IsZero():
+		return s + ", no timeout"
+	case info.Deadline.IsZero():
+		return s + fmt.Sprintf(", locks in %s if idle", untilRounded(info.Expires))
+	case info.Expires.Equal(info.Deadline):
+		return s + fmt.Sprintf(", locks in %s", untilRounded(info.Deadline))
+	default:
+		return s + fmt.Sprintf(", locks in %s if idle, in %s at the latest", untilRounded(info.Expires), untilRounded(info.Deadline))
+	}
+}
+
+func untilRounded(t time.Time) time.Duration {
+	return max(time.Until(t), 0).Round(time.Second)
+}
@@ -14,6 +14,12 @@ func HandleSync(args []string) error {
 		return fmt.Errorf("failed to get dredge directory: %w", err)
 	}
 
-	// Sync (pull + push)
-	return git.Sync(dredgeDir)
+	// Pull, verify, then push: a vault that fails its manifest check is never pushed back
+	if err := git.Pull(dredgeDir); err != nil {
+		return err
+	}
+	if err := verifyPulledVault(dredgeDir); err != nil {
+		return err
+	}
+	return pushVault(dredgeDir)
 }
@@ -0,0 +1,119 @@
+package commands
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/templates"
+)
+
+// ============================================================================
+// add --template
+// ============================================================================
+//
+// A template pre-fills a new item's tags, fields and notes. Besides the built-in ones
+// (templates.Builtin), any item tagged #template is a template named after its title;
+// being an item, it is encrypted and synced like the rest of the vault.
+
+// takeTemplateArg takes "--template NAME" out of args (but not out of -c content).
+func takeTemplateArg(args []string) ([]string, string, error) {
+	var rest []string
+	name := ""
+	for i := 0; i < len(args); i++ {
+		if args[i] == "-c" {
+			end := contentEnd(args, i)
+			rest = append(rest, args[i:end]...)
+			i = end - 1
+			continue
+		}
+		if args[i] != "--template" {
+			rest = append(rest, args[i])
+			continue
+		}
+		if i+1 >= len(args) || strings.HasPrefix(args[i+1], "-") {
+			return nil, "", fmt.Errorf("--template needs a name (built-in: %s)", strings.Join(templates.Names(), ", "))
+		}
+		i++
+		name = args[i]
+	}
+	return rest, name, nil
+}
+
+// loadTemplate finds the template called name: a user template (an item tagged
+// #template with that title) if there is one, else a built-in template.
+func loadTemplate(name string, key []byte) (*templates.Template, error) {
+	index, err := storage.LoadIndex(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load index: %w", err)
+	}
+
+	var matches, userNames []string
+	for id, entry := range index.Entries {
+		if !templates.IsTemplate(entry.Tags) {
+			continue
+		}
+		userNames = append(userNames, entry.Title)
+		if strings.EqualFold(entry.Title, name) {
+			matches = append(matches, id)
+		}
+	}
+	sort.Strings(matches)
+
+	switch {
+	case len(matches) > 1:
+		return nil, fmt.Errorf("several templates are called %q: [%s] (retag or rename all but one)", name, strings.Join(matches, "] ["))
+	case len(matches) == 1:
+		id := matches[0]
+		item, err := storage.ReadItem(id, key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read template [%s]: %w", id, err)
+		}
+		if item.Type != storage.TypeText {
+			return nil, fmt.Errorf("template [%s] is a file; only text items can be templates", id)
+		}
+		if _, err := openLockedItem(id, item); err != nil {
+			return nil, err
+		}
+		return templates.FromItem(item), nil
+	}
+
+	if tmpl := templates.LookupBuiltin(name); tmpl != nil {
+		return tmpl, nil
+	}
+
+	available := templates.Names()
+	for _, n := range userNames {
+		if templates.LookupBuiltin(n) == nil {
+			available = append(available, n)
+		}
+	}
+	sort.Strings(available)
+	return nil, fmt.Errorf("no template called %q (available: %s)", name, strings.Join(available, ", "))
+}
+
+// templateGenField is the field 'add --template --gen' generates into: password if
+// the template has one, else its first secret field.
+func templateGenField(fields []storage.Field) string {
+	if storage.FindField(fields, genField) != nil {
+		return genField
+	}
+	for _, f := range fields {
+		if f.Type == storage.FieldSecret {
+			return f.Name
+		}
+	}
+	return genField
+}
+
+// dropEmptyFields removes the template fields left blank in the editor.
+func dropEmptyFields(fields []storage.Field) []storage.Field {
+	var kept []storage.Field
+	for _, f := range fields {
+		if f.Value != "" {
+			kept = append(kept, f)
+		}
+	}
+	return kept
+}
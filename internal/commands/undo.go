@@ -39,7 +39,7 @@ func HandleUndo(args []string) error {
 	restoredIDs := []string{}
 	for _, id := range ids {
 		// Restore item from trash
-		if err := storage.RestoreFromTrash(id); err != nil {
+		if err := storage.RestoreFromTrash(id, key); err != nil {
 			// If restore fails, warn and continue with remaining items
 			fmt.Fprintf(os.Stderr, "Warning: failed to restore [%s]: %v\n", id, err)
 			continue
@@ -41,6 +41,10 @@ func HandleUnlink(args []string) error {
 		return fmt.Errorf("item %s is not linked", id)
 	}
 
+	if err := saveLockedLinkEdits(id, item, key); err != nil {
+		return err
+	}
+
 	// Perform unlink operation
 	if err := storage.Unlink(id); err != nil {
 		return err
@@ -0,0 +1,40 @@
+package commands
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+)
+
+// HandleUnlock unlocks the vault for this terminal ahead of time. With lifetime > 0
+// the session lasts that long whether used or not (capped by the vault's max_lifetime),
+// instead of locking after the idle timeout.
+func HandleUnlock(lifetime time.Duration) error {
+	if lifetime < 0 {
+		return fmt.Errorf("--for cannot be negative")
+	}
+
+	key, err := crypto.GetKeyWithVerification()
+	if err != nil {
+		return fmt.Errorf("failed to get key: %w", err)
+	}
+
+	if lifetime > 0 {
+		deadline, err := crypto.SetSessionLifetime(key, lifetime)
+		if err != nil {
+			return err
+		}
+		if time.Until(deadline) < lifetime-time.Second {
+			fmt.Fprintf(os.Stderr, "Warning: session capped at %s by max_lifetime\n", untilRounded(deadline))
+		}
+	}
+
+	info, err := crypto.GetSessionInfo()
+	if err != nil {
+		return fmt.Errorf("failed to check session: %w", err)
+	}
+	fmt.Printf("✓ Vault %s\n", describeSession(info))
+	return nil
+}
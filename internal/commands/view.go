@@ -2,18 +2,45 @@ package commands
 
 import (
 	"fmt"
+	"io"
+	"os"
 	"strings"
 
+	"golang.org/x/term"
+
 	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/ui"
 )
 
-func HandleView(args []string, raw ...bool) error {
-	if len(args) < 1 {
-		return fmt.Errorf("usage: dredge view <id>")
+// secretMask stands in for secret field values (same width whatever the value).
+const secretMask = "********"
+
+// ViewOptions configures dredge view.
+type ViewOptions struct {
+	Raw    bool // content only, no header (dredge cat)
+	Reveal bool // show secret field values
+}
+
+func HandleView(args []string, opts ViewOptions) error {
+	// Flags may also follow the ID (flexible positioning)
+	var positional []string
+	for _, arg := range args {
+		switch arg {
+		case "--raw", "-r":
+			opts.Raw = true
+		case "--reveal":
+			opts.Reveal = true
+		default:
+			positional = append(positional, arg)
+		}
+	}
+	args = positional
+
+	if len(args) < 1 || len(args) > 2 {
+		return fmt.Errorf("usage: dredge view <id> [field]")
 	}
-	rawMode := len(raw) > 0 && raw[0]
+	rawMode := opts.Raw
 
 	// Resolve numbered arg to ID
 	ids, err := ResolveArgs(args[:1])
@@ -34,11 +61,47 @@ func HandleView(args []string, raw ...bool) error {
 		return fmt.Errorf("failed to read item: %w", err)
 	}
 
+	// Double-locked items ask for their own password every time
+	itemKey, err := openLockedItem(id, item)
+	if err != nil {
+		return err
+	}
+
+	// A single field: cat prints its value as is, view as a "name: value" line
+	if len(args) == 2 {
+		field, err := itemField(id, item, args[1])
+		if err != nil {
+			return err
+		}
+		if rawMode {
+			fmt.Print(field.Value)
+			if term.IsTerminal(int(os.Stdout.Fd())) {
+				fmt.Println()
+			}
+			return nil
+		}
+		fmt.Println(formatField(field, opts.Reveal))
+		return nil
+	}
+
 	if rawMode {
 		if item.Type == storage.TypeBinary {
-			return fmt.Errorf("item %s is binary — use 'dredge export' to extract it", id)
+			// Stream the blob so large files never sit in memory
+			blob, err := openBlob(id, key, itemKey)
+			if err != nil {
+				return fmt.Errorf("failed to read binary blob: %w", err)
+			}
+			defer blob.Close()
+			if _, err := io.Copy(os.Stdout, blob); err != nil {
+				return fmt.Errorf("failed to read binary blob: %w", err)
+			}
+			return nil
 		}
 		fmt.Print(item.Content.Text)
+		if len(item.Content.Fields) > 0 {
+			// Keep stdout pipeable; fields are read one at a time
+			fmt.Fprintf(os.Stderr, "(fields not shown: %s; use 'dredge cat %s <field>')\n", strings.Join(item.FieldNames(), ", "), id)
+		}
 		return nil
 	}
 
@@ -62,7 +125,13 @@ func HandleView(args []string, raw ...bool) error {
 		}
 		fmt.Printf("\nUse 'dredge export %s [path]' to extract this file.\n", id)
 	} else {
-		// For text items, show content
+		// For text items, show fields (secret ones masked) and content
+		for k := range item.Content.Fields {
+			fmt.Println(formatField(&item.Content.Fields[k], opts.Reveal))
+		}
+		if len(item.Content.Fields) > 0 && item.Content.Text != "" {
+			fmt.Println()
+		}
 		if item.Content.Text != "" {
 			fmt.Println(item.Content.Text)
 		}
@@ -70,3 +139,27 @@ func HandleView(args []string, raw ...bool) error {
 
 	return nil
 }
+
+// itemField looks up the field called name on an item read (and opened) for id.
+func itemField(id string, item *storage.Item, name string) (*storage.Field, error) {
+	if item.Type == storage.TypeBinary {
+		return nil, fmt.Errorf("<%s> is a binary item: only text items have fields", id)
+	}
+	field := item.Field(name)
+	if field == nil {
+		if len(item.Content.Fields) == 0 {
+			return nil, fmt.Errorf("[%s] has no fields", id)
+		}
+		return nil, fmt.Errorf("[%s] has no field %q (fields: %s)", id, name, strings.Join(item.FieldNames(), ", "))
+	}
+	return field, nil
+}
+
+// formatField formats a field for view, masking a secret value unless reveal is set.
+func formatField(f *storage.Field, reveal bool) string {
+	value := f.Value
+	if f.IsSecret() && !reveal && value != "" {
+		value = secretMask
+	}
+	return f.Name + ": " + value
+}
@@ -0,0 +1,224 @@
+// Package config loads settings from the user's ~/.config/dredge/config.toml and the
+// active vault's .dredge-config.toml. Vault settings override the user's for that vault,
+// except session timeouts, which a vault can only shorten, and history, which it can only
+// raise: the file is synced through the remote without authentication, so it must not be
+// able to keep everyone unlocked or have everyone's revisions trimmed away.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/passgen"
+)
+
+const (
+	// FileName is the user config file inside the dredge config directory.
+	FileName = "config.toml"
+
+	// VaultFileName is the per-vault config file at the vault root. It is committed
+	// with the vault, so everyone sharing it gets the same policy.
+	VaultFileName = ".dredge-config.toml"
+
+	// DefaultIdleTimeout locks a session after five minutes without use.
+	DefaultIdleTimeout = 5 * time.Minute
+
+	// DefaultHistory is how many earlier revisions of each item are kept.
+	DefaultHistory = 10
+)
+
+// userOnlyKeys describe this machine rather than the vault, so a vault cannot set them.
+var userOnlyKeys = [][]string{
+	{"session", "cache"},
+	{"session", "keyring"},
+}
+
+// Session cache backends (session.cache)
+const (
+	CacheAuto    = "auto"    // dredge agent when it is running, otherwise the session file
+	CacheFile    = "file"    // key file under $XDG_RUNTIME_DIR/dredge/$PPID
+	CacheKeyring = "keyring" // Linux kernel keyring; falls back to the file elsewhere
+)
+
+// Kernel keyrings for the keyring backend (session.keyring)
+const (
+	KeyringSession = "session" // the login session's keyring
+	KeyringUser    = "user"    // the per-user keyring, shared by all of the user's sessions
+)
+
+// Config is the content of config.toml. Missing keys keep their defaults.
+type Config struct {
+	Session Session `toml:"session"`
+	Vault   Vault   `toml:"vault"`
+}
+
+// Session configures how unlocked keys are cached between commands.
+type Session struct {
+	Cache   string `toml:"cache"`
+	Keyring string `toml:"keyring"`
+
+	// IdleTimeout locks the session after this long without use; every use restarts it.
+	// MaxLifetime locks it this long after unlocking, however busy. 0 disables either
+	// (in the user's config only; see Load).
+	IdleTimeout time.Duration `toml:"idle_timeout"`
+	MaxLifetime time.Duration `toml:"max_lifetime"`
+}
+
+// Vault configures how items are written. Set it in the vault's .dredge-config.toml so
+// everyone writing to the vault does the same.
+type Vault struct {
+	// Padding pads items and blobs to size buckets before encryption, so the remote
+	// can't tell a short API key from a long config file. 'dredge rewrite' applies it
+	// to existing items.
+	Padding bool `toml:"padding"`
+
+	// Compression deflates items and blobs before encryption where that makes them
+	// smaller, so text doesn't bloat the git history. 'dredge rewrite' applies it too.
+	Compression bool `toml:"compression"`
+
+	// History is how many earlier revisions of each text item are kept inside it, for
+	// 'dredge history', 'diff' and 'revert'. 0 keeps none (in the user's config only;
+	// see Load).
+	History int `toml:"history"`
+
+	// Gen is the default policy of 'dredge gen' and 'add/edit --gen' ([vault.gen]):
+	// length, lower, upper, digits, symbols, exclude_ambiguous, and passphrase, words,
+	// separator for word passphrases. Flags override it per command.
+	Gen passgen.Policy `toml:"gen"`
+}
+
+// Default returns the configuration used when config.toml is absent.
+func Default() *Config {
+	return &Config{
+		Session: Session{
+			Cache:       CacheAuto,
+			Keyring:     KeyringSession,
+			IdleTimeout: DefaultIdleTimeout,
+		},
+		Vault: Vault{
+			History: DefaultHistory,
+			Gen:     passgen.Default,
+		},
+	}
+}
+
+// Path returns ~/.config/dredge/config.toml (XDG config dir).
+func Path() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config directory: %w", err)
+	}
+	return filepath.Join(configDir, "dredge", FileName), nil
+}
+
+// Load reads the user's config.toml, then vaultDir's .dredge-config.toml on top of it
+// (vaultDir may be empty). Missing files are not an error. Of the user's and the vault's
+// session timeouts the shorter one applies; a vault's 0 is ignored. Of their history
+// settings the longer one applies.
+func Load(vaultDir string) (*Config, error) {
+	cfg := Default()
+
+	if path, err := Path(); err == nil {
+		if err := decodeFile(cfg, path, false); err != nil {
+			return nil, err
+		}
+	}
+	if vaultDir != "" {
+		user := *cfg
+		if err := decodeFile(cfg, filepath.Join(vaultDir, VaultFileName), true); err != nil {
+			return nil, err
+		}
+		cfg.Session.IdleTimeout = shorterTimeout(user.Session.IdleTimeout, cfg.Session.IdleTimeout)
+		cfg.Session.MaxLifetime = shorterTimeout(user.Session.MaxLifetime, cfg.Session.MaxLifetime)
+		// Fewer revisions trims them from every item on its next change, so a vault can
+		// only ask to keep more
+		cfg.Vault.History = max(user.Vault.History, cfg.Vault.History)
+	}
+	return cfg, nil
+}
+
+// shorterTimeout returns the stricter of the user's and the vault's timeout, where 0
+// means none: a vault can tighten the user's timeout but not lift it.
+func shorterTimeout(user, vault time.Duration) time.Duration {
+	switch {
+	case vault == 0:
+		return user
+	case user == 0:
+		return vault
+	default:
+		return min(user, vault)
+	}
+}
+
+// LoadFile reads the user config file at path on top of the defaults.
+func LoadFile(path string) (*Config, error) {
+	cfg := Default()
+	if err := decodeFile(cfg, path, false); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// decodeFile overrides cfg with the keys set in the file at path, if it exists.
+func decodeFile(cfg *Config, path string, vault bool) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return nil
+		}
+		return fmt.Errorf("failed to read config: %w", err)
+	}
+
+	meta, err := toml.Decode(string(data), cfg)
+	if err != nil {
+		return fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return fmt.Errorf("invalid config %s: unknown key %q", path, undecoded[0].String())
+	}
+	if vault {
+		for _, key := range userOnlyKeys {
+			if meta.IsDefined(key...) {
+				return fmt.Errorf("invalid config %s: %s can only be set in ~/.config/dredge/%s", path, toml.Key(key).String(), FileName)
+			}
+		}
+	}
+	if err := cfg.validate(); err != nil {
+		return fmt.Errorf("invalid config %s: %w", path, err)
+	}
+	return nil
+}
+
+func (c *Config) validate() error {
+	switch c.Session.Cache {
+	case CacheAuto, CacheFile, CacheKeyring:
+	default:
+		return fmt.Errorf("session.cache must be %q, %q or %q, got %q", CacheAuto, CacheFile, CacheKeyring, c.Session.Cache)
+	}
+	switch c.Session.Keyring {
+	case KeyringSession, KeyringUser:
+	default:
+		return fmt.Errorf("session.keyring must be %q or %q, got %q", KeyringSession, KeyringUser, c.Session.Keyring)
+	}
+	if c.Session.IdleTimeout < 0 || c.Session.MaxLifetime < 0 {
+		return fmt.Errorf("session timeouts cannot be negative")
+	}
+	if c.Vault.History < 0 {
+		return fmt.Errorf("vault.history cannot be negative")
+	}
+
+	// Both kinds must work: flags switch between them
+	password, passphrase := c.Vault.Gen, c.Vault.Gen
+	password.Passphrase, passphrase.Passphrase = false, true
+	if err := password.Validate(); err != nil {
+		return fmt.Errorf("vault.gen: %w", err)
+	}
+	if err := passphrase.Validate(); err != nil {
+		return fmt.Errorf("vault.gen: %w", err)
+	}
+	return nil
+}
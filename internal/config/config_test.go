@@ -0,0 +1,187 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), FileName)
+	if err := os.WriteFile(path, []byte(content), 0600); err != nil {
+		t.Fatalf("failed to write config: %v", err)
+	}
+	return path
+}
+
+func TestLoadFile_Missing(t *testing.T) {
+	cfg, err := LoadFile(filepath.Join(t.TempDir(), FileName))
+	if err != nil {
+		t.Fatalf("LoadFile on a missing file failed: %v", err)
+	}
+	if *cfg != *Default() {
+		t.Errorf("missing file = %+v, want defaults", cfg)
+	}
+}
+
+func TestLoadFile_Session(t *testing.T) {
+	path := writeConfig(t, "[session]\ncache = \"keyring\"\n")
+
+	cfg, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile failed: %v", err)
+	}
+	if cfg.Session.Cache != CacheKeyring {
+		t.Errorf("Cache = %q, want %q", cfg.Session.Cache, CacheKeyring)
+	}
+	if cfg.Session.Keyring != KeyringSession {
+		t.Errorf("Keyring = %q, want the default %q", cfg.Session.Keyring, KeyringSession)
+	}
+}
+
+func TestLoadFile_Invalid(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    string
+	}{
+		{"bad cache", "[session]\ncache = \"memory\"\n", "session.cache"},
+		{"bad keyring", "[session]\nkeyring = \"thread\"\n", "session.keyring"},
+		{"bad duration", "[session]\nidle_timeout = \"soon\"\n", "invalid config"},
+		{"negative timeout", "[session]\nmax_lifetime = \"-1h\"\n", "negative"},
+		{"negative history", "[vault]\nhistory = -1\n", "vault.history"},
+		{"short gen length", "[vault.gen]\nlength = 2\n", "vault.gen"},
+		{"no gen classes", "[vault.gen]\nlower = false\nupper = false\ndigits = false\nsymbols = false\n", "vault.gen"},
+		{"unknown key", "[session]\ncahce = \"file\"\n", "unknown key"},
+		{"syntax", "[session\n", "invalid config"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadFile(writeConfig(t, tt.content))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadFile = %v, want an error mentioning %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLoad_VaultOverridesUser(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("HOME", home)
+	userPath, _ := Path()
+	_ = os.MkdirAll(filepath.Dir(userPath), 0700)
+	_ = os.WriteFile(userPath, []byte("[session]\ncache = \"file\"\nidle_timeout = \"30m\"\nmax_lifetime = \"12h\"\n"), 0600)
+
+	vaultDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(vaultDir, VaultFileName), []byte("[session]\nmax_lifetime = \"2h\"\n"), 0600)
+
+	cfg, err := Load(vaultDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	want := Session{Cache: CacheFile, Keyring: KeyringSession, IdleTimeout: 30 * time.Minute, MaxLifetime: 2 * time.Hour}
+	if cfg.Session != want {
+		t.Errorf("Session = %+v, want %+v", cfg.Session, want)
+	}
+
+	// Without a vault only the user file applies
+	cfg, _ = Load("")
+	if cfg.Session.MaxLifetime != 12*time.Hour {
+		t.Errorf("MaxLifetime = %s, want the user's 12h", cfg.Session.MaxLifetime)
+	}
+}
+
+func TestLoad_VaultCannotLoosenTimeouts(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("HOME", home)
+	userPath, _ := Path()
+	_ = os.MkdirAll(filepath.Dir(userPath), 0700)
+	_ = os.WriteFile(userPath, []byte("[session]\nidle_timeout = \"10m\"\nmax_lifetime = \"4h\"\n"), 0600)
+
+	// Whoever can push to the remote could write this
+	vaultDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(vaultDir, VaultFileName), []byte("[session]\nidle_timeout = 0\nmax_lifetime = \"48h\"\n"), 0600)
+
+	cfg, err := Load(vaultDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Session.IdleTimeout != 10*time.Minute || cfg.Session.MaxLifetime != 4*time.Hour {
+		t.Errorf("timeouts = %s / %s, want the user's 10m / 4h", cfg.Session.IdleTimeout, cfg.Session.MaxLifetime)
+	}
+
+	// Without user timeouts a vault still can't turn off the default one, only add a limit
+	_ = os.WriteFile(userPath, []byte("[session]\nmax_lifetime = 0\n"), 0600)
+	cfg, err = Load(vaultDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Session.IdleTimeout != DefaultIdleTimeout || cfg.Session.MaxLifetime != 48*time.Hour {
+		t.Errorf("timeouts = %s / %s, want %s / 48h", cfg.Session.IdleTimeout, cfg.Session.MaxLifetime, DefaultIdleTimeout)
+	}
+}
+
+func TestLoad_VaultCannotLowerHistory(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", home)
+	t.Setenv("HOME", home)
+	vaultDir := t.TempDir()
+	vaultPath := filepath.Join(vaultDir, VaultFileName)
+
+	// Whoever can push to the remote could write this
+	_ = os.WriteFile(vaultPath, []byte("[vault]\nhistory = 0\n"), 0600)
+	cfg, err := Load(vaultDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	if cfg.Vault.History != DefaultHistory {
+		t.Errorf("history = %d, want the default %d", cfg.Vault.History, DefaultHistory)
+	}
+
+	// Keeping more is fine
+	_ = os.WriteFile(vaultPath, []byte("[vault]\nhistory = 50\n"), 0600)
+	if cfg, _ = Load(vaultDir); cfg.Vault.History != 50 {
+		t.Errorf("history = %d, want the vault's 50", cfg.Vault.History)
+	}
+
+	// Only the user can turn it off
+	userPath, _ := Path()
+	_ = os.MkdirAll(filepath.Dir(userPath), 0700)
+	_ = os.WriteFile(userPath, []byte("[vault]\nhistory = 0\n"), 0600)
+	_ = os.WriteFile(vaultPath, []byte("[vault]\npadding = true\n"), 0600)
+	if cfg, _ = Load(vaultDir); cfg.Vault.History != 0 {
+		t.Errorf("history = %d, want the user's 0", cfg.Vault.History)
+	}
+}
+
+func TestLoad_VaultCannotSetCache(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	vaultDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(vaultDir, VaultFileName), []byte("[session]\ncache = \"file\"\n"), 0600)
+
+	_, err := Load(vaultDir)
+	if err == nil || !strings.Contains(err.Error(), "session.cache") {
+		t.Errorf("Load = %v, want an error about session.cache", err)
+	}
+}
+
+func TestLoad_VaultGenPolicy(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	vaultDir := t.TempDir()
+	_ = os.WriteFile(filepath.Join(vaultDir, VaultFileName), []byte("[vault.gen]\nlength = 40\nsymbols = false\n"), 0600)
+
+	cfg, err := Load(vaultDir)
+	if err != nil {
+		t.Fatalf("Load failed: %v", err)
+	}
+	gen := cfg.Vault.Gen
+	if gen.Length != 40 || gen.Symbols || !gen.Lower || gen.Words != Default().Vault.Gen.Words {
+		t.Errorf("Gen = %+v, want length 40 without symbols, other keys default", gen)
+	}
+}
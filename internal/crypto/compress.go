@@ -0,0 +1,107 @@
+package crypto
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+)
+
+// ============================================================================
+// Compression
+// ============================================================================
+//
+// Ciphertext doesn't compress, so anything that should be smaller in git has to be
+// compressed before it is encrypted. Compressed payloads are raw DEFLATE (RFC 1951)
+// and set FlagCompressed in the (authenticated) header; padding, if any, is applied
+// to the compressed data. Compression is only kept where it pays: single messages are
+// compressed and kept if they shrink by at least 1/8, streams decide on their first chunk.
+
+var errBadCompression = errors.New("invalid compressed data (tampered data?)")
+
+// IsCompressed reports whether the plaintext was deflated before encryption.
+func (h Header) IsCompressed() bool {
+	return h.Flags&FlagCompressed != 0
+}
+
+// worthCompressing reports whether compressed is enough smaller than original to keep.
+func worthCompressing(original, compressed int) bool {
+	return compressed <= original-original/8
+}
+
+// deflate returns plaintext compressed, and false if that doesn't pay.
+func deflate(plaintext []byte) ([]byte, bool) {
+	if len(plaintext) == 0 {
+		return nil, false
+	}
+	var buf bytes.Buffer
+	w, _ := flate.NewWriter(&buf, flate.DefaultCompression) // only fails on a bad level
+	if _, err := w.Write(plaintext); err != nil {
+		return nil, false
+	}
+	if err := w.Close(); err != nil {
+		return nil, false
+	}
+	if !worthCompressing(len(plaintext), buf.Len()) {
+		return nil, false
+	}
+	return buf.Bytes(), true
+}
+
+// inflate reverses deflate.
+func inflate(compressed []byte) ([]byte, error) {
+	plaintext, err := io.ReadAll(newInflateReader(bytes.NewReader(compressed)))
+	if err != nil {
+		return nil, err
+	}
+	return plaintext, nil
+}
+
+// inflateReader decompresses a stream, reporting corrupt data as errBadCompression.
+type inflateReader struct {
+	r io.ReadCloser
+}
+
+func newInflateReader(src io.Reader) io.Reader {
+	return &inflateReader{r: flate.NewReader(src)}
+}
+
+func (r *inflateReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err != nil && err != io.EOF {
+		var corrupt flate.CorruptInputError
+		if errors.As(err, &corrupt) || errors.Is(err, io.ErrUnexpectedEOF) {
+			err = errBadCompression
+		}
+	}
+	return n, err
+}
+
+// compressWriter deflates into an encrypt stream; Close flushes the compressor and
+// then closes the stream.
+type compressWriter struct {
+	zw     *flate.Writer
+	stream io.WriteCloser
+}
+
+func newCompressWriter(stream io.WriteCloser) io.WriteCloser {
+	zw, _ := flate.NewWriter(stream, flate.DefaultCompression) // only fails on a bad level
+	return &compressWriter{zw: zw, stream: stream}
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	return w.zw.Write(p)
+}
+
+func (w *compressWriter) Close() error {
+	if err := w.zw.Close(); err != nil {
+		return err
+	}
+	return w.stream.Close()
+}
+
+// sampleCompresses reports whether a stream starting with sample is worth compressing.
+func sampleCompresses(sample []byte) bool {
+	_, ok := deflate(sample)
+	return ok
+}
@@ -0,0 +1,81 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"strings"
+	"testing"
+)
+
+func TestEncryptPayload_CompressesWhenItHelps(t *testing.T) {
+	key := make([]byte, KeySize)
+	ad := AssociatedData(KindItem, "abc")
+
+	text := []byte(strings.Repeat("2026-10-16 11:13:09 INFO request served in 3ms\n", 200))
+	random := make([]byte, 4096)
+	_, _ = rand.Read(random)
+
+	for _, opts := range []PayloadOptions{{Compress: true}, {Compress: true, Pad: true}} {
+		encrypted, err := EncryptPayload(text, key, ad, opts)
+		if err != nil {
+			t.Fatalf("EncryptPayload failed: %v", err)
+		}
+		header, _ := ParseHeader(encrypted)
+		if !header.IsCompressed() || header.IsPadded() != opts.Pad {
+			t.Errorf("%+v: header flags %#x", opts, header.Flags)
+		}
+		if len(encrypted) > len(text)/4 {
+			t.Errorf("%+v: %d bytes of log came out as %d", opts, len(text), len(encrypted))
+		}
+		if decrypted, err := DecryptWithAD(encrypted, key, ad); err != nil || !bytes.Equal(decrypted, text) {
+			t.Errorf("%+v: round trip failed: %v", opts, err)
+		}
+
+		// Incompressible data is stored as it is
+		encrypted, _ = EncryptPayload(random, key, ad, opts)
+		if header, _ := ParseHeader(encrypted); header.IsCompressed() {
+			t.Errorf("%+v: random data was stored compressed", opts)
+		}
+		if decrypted, err := DecryptWithAD(encrypted, key, ad); err != nil || !bytes.Equal(decrypted, random) {
+			t.Errorf("%+v: round trip of random data failed: %v", opts, err)
+		}
+	}
+}
+
+func TestEncryptStreamWith_Compression(t *testing.T) {
+	key := make([]byte, KeySize)
+	ad := AssociatedData(KindBlob, "abc")
+
+	text := []byte(strings.Repeat("INSERT INTO users VALUES (1, 'alice', 'alice@example.com');\n", 20000))
+	random := make([]byte, 2*StreamChunkSize+5)
+	_, _ = rand.Read(random)
+
+	for _, tt := range []struct {
+		name       string
+		plaintext  []byte
+		compressed bool
+	}{
+		{"sql dump", text, true},
+		{"random", random, false},
+		{"empty", nil, false},
+	} {
+		for _, pad := range []bool{false, true} {
+			var buf bytes.Buffer
+			n, err := EncryptStreamWith(&buf, bytes.NewReader(tt.plaintext), key, ad, PayloadOptions{Compress: true, Pad: pad})
+			if err != nil || n != int64(len(tt.plaintext)) {
+				t.Fatalf("%s: EncryptStreamWith = %d, %v", tt.name, n, err)
+			}
+			if header, _ := ParseHeader(buf.Bytes()); header.IsCompressed() != tt.compressed {
+				t.Errorf("%s: compressed = %v, want %v", tt.name, header.IsCompressed(), tt.compressed)
+			}
+			if tt.compressed && buf.Len() > len(tt.plaintext)/10 {
+				t.Errorf("%s: %d bytes came out as %d", tt.name, len(tt.plaintext), buf.Len())
+			}
+
+			decrypted, err := decryptStreamBytes(buf.Bytes(), key, ad)
+			if err != nil || !bytes.Equal(decrypted, tt.plaintext) {
+				t.Errorf("%s (pad %v): round trip failed (%d bytes): %v", tt.name, pad, len(decrypted), err)
+			}
+		}
+	}
+}
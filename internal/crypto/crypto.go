@@ -4,16 +4,14 @@ import (
 	"crypto/aes"
 	"crypto/cipher"
 	"crypto/rand"
-	"crypto/sha256"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"os"
 	"path/filepath"
-	"strconv"
-	"time"
-
-	"golang.org/x/crypto/argon2"
 
+	"github.com/DeprecatedLuar/dredge-cargo/internal/secmem"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/session"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/ui"
 )
@@ -21,16 +19,17 @@ import (
 // Debug mode flag (set from main)
 var DebugMode bool
 
-// NoLock disables the session timeout when set (--no-lock flag).
+// NoLock disables the session timeouts when set (--no-lock flag).
 var NoLock bool
 
-// SessionTimeout is the duration after which a cached key is considered expired.
-const SessionTimeout = 5 * 60 // seconds
-
 // pendingPassword holds a password provided via --password flag, used once by GetKeyWithVerification.
 // In-memory only — never written to disk.
 var pendingPassword string
 
+// OnUnlock, if set, is called with the master key right after the vault is unlocked
+// by password (not on session cache hits). Set from main to run key-dependent self-healing.
+var OnUnlock func(key []byte)
+
 // SetPendingPassword stores a password to be used once by GetKeyWithVerification instead of prompting.
 // Called from main.go when --password flag is provided.
 func SetPendingPassword(pw string) {
@@ -46,7 +45,8 @@ const (
 	NonceSize = 12 // 96 bits (standard GCM nonce size)
 	KeySize   = 32 // 256 bits for AES-256
 
-	// Argon2id parameters (per RFC 9106 recommendations)
+	// Legacy Argon2id parameters (vaults created before .dredge-key recorded them).
+	// New vaults use DefaultKDFParams; see kdf.go.
 	Argon2Time      = 1         // 1 iteration
 	Argon2Memory    = 64 * 1024 // 64 MB
 	Argon2Threads   = 4         // 4 parallel threads
@@ -57,6 +57,15 @@ const (
 const (
 	PasswordVerifyFile  = ".dredge-key"
 	VerificationContent = "dredge-vault-v1"
+
+	// VerifyFileVersion is the current .dredge-key format.
+	// Version 1 is the legacy binary layout: [16B salt][12B nonce][ciphertext + auth tag]
+	// Version 2 is JSON with KDF parameters; the password-derived key encrypts items directly.
+	// Version 3 wraps a random vault data key with the password-derived key.
+	// Version 4 holds a list of key slots, each wrapping the same data key.
+	VerifyFileVersion = 4
+
+	kdfNameArgon2id = "argon2id"
 )
 
 // ============================================================================
@@ -64,21 +73,58 @@ const (
 // ============================================================================
 
 // Encrypt encrypts plaintext using a pre-derived 32-byte key (AES-256-GCM).
-// Returns binary format: [12B nonce][N bytes ciphertext + 16B auth tag]
+// Returns envelope format: [8B header][12B nonce][N bytes ciphertext + 16B auth tag]
 // Use DeriveKey or GetKeyWithVerification to obtain a key.
 func Encrypt(plaintext []byte, key []byte) ([]byte, error) {
-	if len(key) != KeySize {
-		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	return EncryptWithAD(plaintext, key, nil)
+}
+
+// EncryptWithAD is Encrypt with associated data (see AssociatedData) authenticated
+// alongside the header. The same ad must be passed to DecryptWithAD.
+func EncryptWithAD(plaintext []byte, key []byte, ad []byte) ([]byte, error) {
+	return encryptEnvelope(plaintext, key, ad, 0)
+}
+
+// EncryptPaddedWithAD is EncryptWithAD with the plaintext padded to its size bucket
+// first, so the ciphertext length only reveals the bucket (see PaddedSize).
+func EncryptPaddedWithAD(plaintext []byte, key []byte, ad []byte) ([]byte, error) {
+	return EncryptPayload(plaintext, key, ad, PayloadOptions{Pad: true})
+}
+
+// PayloadOptions selects what is done to a plaintext before it is encrypted.
+type PayloadOptions struct {
+	Pad      bool // pad to the size bucket (see PaddedSize)
+	Compress bool // deflate, where that makes it smaller (see compress.go)
+}
+
+// flags returns the header flags for opts, before the compression decision.
+func (o PayloadOptions) flags() byte {
+	if o.Pad {
+		return FlagPadded
 	}
+	return 0
+}
 
-	block, err := aes.NewCipher(key)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
+// EncryptPayload is EncryptWithAD with the plaintext compressed and/or padded first.
+func EncryptPayload(plaintext []byte, key []byte, ad []byte, opts PayloadOptions) ([]byte, error) {
+	flags := opts.flags()
+	if opts.Compress {
+		if compressed, ok := deflate(plaintext); ok {
+			plaintext = compressed
+			flags |= FlagCompressed
+		}
+	}
+	if opts.Pad {
+		plaintext = pad(plaintext)
 	}
+	return encryptEnvelope(plaintext, key, ad, flags)
+}
 
-	gcm, err := cipher.NewGCM(block)
+// encryptEnvelope seals plaintext as a single GCM message with the given extra header flags.
+func encryptEnvelope(plaintext []byte, key []byte, ad []byte, flags byte) ([]byte, error) {
+	gcm, err := newGCM(key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
+		return nil, err
 	}
 
 	nonce := make([]byte, NonceSize)
@@ -86,149 +132,179 @@ func Encrypt(plaintext []byte, key []byte) ([]byte, error) {
 		return nil, fmt.Errorf("failed to generate nonce: %w", err)
 	}
 
-	ciphertext := gcm.Seal(nil, nonce, plaintext, nil)
+	h := defaultHeader()
+	h.Flags |= flags
+	if ad != nil {
+		h.Flags |= FlagBoundAD
+	}
+	header := h.Bytes()
 
-	result := make([]byte, 0, NonceSize+len(ciphertext))
+	result := make([]byte, 0, HeaderSize+NonceSize+len(plaintext)+gcm.Overhead())
+	result = append(result, header...)
 	result = append(result, nonce...)
-	result = append(result, ciphertext...)
 
-	return result, nil
+	// Header (and ad, if any) is authenticated as additional data
+	return gcm.Seal(result, nonce, plaintext, additionalData(header, ad)), nil
 }
 
 // Decrypt decrypts data using a pre-derived 32-byte key.
-// Input format: [12B nonce][N bytes ciphertext + 16B auth tag]
+// Accepts both the envelope format and the legacy headerless format
+// ([12B nonce][N bytes ciphertext + 16B auth tag]).
 func Decrypt(encrypted []byte, key []byte) ([]byte, error) {
+	return DecryptWithAD(encrypted, key, nil)
+}
+
+// DecryptWithAD decrypts data written by EncryptWithAD with the same ad.
+// Payloads written without associated data (legacy or unbound envelopes) are still
+// accepted so existing vaults keep working until selfheal rewrites them bound; after
+// that, vault files are opened with DecryptBound.
+func DecryptWithAD(encrypted []byte, key []byte, ad []byte) ([]byte, error) {
 	if len(key) != KeySize {
 		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
 	}
 
-	minSize := NonceSize + 16 // 16 = GCM auth tag
-	if len(encrypted) < minSize {
-		return nil, fmt.Errorf("encrypted data too short: got %d bytes, need at least %d", len(encrypted), minSize)
+	header, ok := ParseHeader(encrypted)
+	if !ok {
+		return decryptLegacy(encrypted, key)
 	}
 
-	nonce := encrypted[:NonceSize]
-	ciphertext := encrypted[NonceSize:]
-
-	block, err := aes.NewCipher(key)
+	plaintext, err := decryptEnvelope(header, encrypted, key, ad)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create cipher: %w", err)
+		// A legacy nonce starts with the magic bytes by chance once in 2^32; try that before failing
+		if legacy, legacyErr := decryptLegacy(encrypted, key); legacyErr == nil {
+			return legacy, nil
+		}
+		return nil, err
 	}
+	return plaintext, nil
+}
 
-	gcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create GCM: %w", err)
+// DecryptBound is DecryptWithAD for payloads that must be bound to ad: unbound envelopes
+// and legacy headerless payloads are rejected instead of opened. Used once every file of
+// a vault is bound, so an old unbound ciphertext can't be passed off as another item.
+func DecryptBound(encrypted []byte, key []byte, ad []byte) ([]byte, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
 	}
-
-	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
-	if err != nil {
-		return nil, fmt.Errorf("decryption failed (wrong key or tampered data): %w", err)
+	header, ok := ParseHeader(encrypted)
+	if !ok || !header.IsBound() || ad == nil {
+		return nil, errNotBound
 	}
-
-	return plaintext, nil
+	return decryptEnvelope(header, encrypted, key, ad)
 }
 
-// DeriveKey derives an encryption key from password and salt using Argon2id.
-func DeriveKey(password string, salt []byte) []byte {
-	return argon2.IDKey(
-		[]byte(password),
-		salt,
-		Argon2Time,
-		Argon2Memory,
-		Argon2Threads,
-		Argon2KeyLength,
-	)
-}
+// errNotBound rejects an unbound or legacy payload where only bound ones are accepted.
+var errNotBound = errors.New("payload is not bound to its item ID (written by an old dredge, or moved here from another ID)")
 
-// ExtractSalt returns the first SaltSize bytes from data (global salt from .dredge-key).
-func ExtractSalt(data []byte) []byte {
-	if len(data) < SaltSize {
-		return nil
+// decryptEnvelope opens a payload in envelope format (header included in encrypted).
+func decryptEnvelope(header Header, encrypted []byte, key []byte, ad []byte) ([]byte, error) {
+	if err := header.validate(); err != nil {
+		return nil, err
 	}
-	return data[:SaltSize]
-}
 
-// ============================================================================
-// Session Management
-// ============================================================================
+	if header.IsStream() {
+		return nil, fmt.Errorf("payload is a chunked stream and must be read with NewDecryptReader")
+	}
+	if header.IsBound() && ad == nil {
+		return nil, fmt.Errorf("payload is bound to an item ID and cannot be opened without it")
+	}
+	if !header.IsBound() {
+		ad = nil
+	}
 
-const sessionCacheFile = ".key" // Raw 32-byte derived master key
+	minSize := HeaderSize + NonceSize + 16 // 16 = GCM auth tag
+	if len(encrypted) < minSize {
+		return nil, fmt.Errorf("encrypted data too short: got %d bytes, need at least %d", len(encrypted), minSize)
+	}
 
-// vaultKeyDir returns the session subdirectory scoped to the active vault,
-// so switching vaults never reuses a cached key from a different vault.
-// Path: $XDG_RUNTIME_DIR/dredge/$PPID/<vaulthash>/
-func vaultKeyDir() string {
-	h := sha256.Sum256([]byte(session.GetVaultPath()))
-	return filepath.Join(session.Dir(), fmt.Sprintf("%x", h)[:8])
-}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return nil, err
+	}
 
-// GetCachedKey retrieves the cached 32-byte master key from session.
-// Returns nil if cache doesn't exist or is not exactly KeySize bytes.
-func GetCachedKey() ([]byte, error) {
-	cachePath := filepath.Join(vaultKeyDir(), sessionCacheFile)
+	nonce := encrypted[HeaderSize : HeaderSize+NonceSize]
+	ciphertext := encrypted[HeaderSize+NonceSize:]
 
-	info, err := os.Stat(cachePath)
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, additionalData(encrypted[:HeaderSize], ad))
 	if err != nil {
-		if os.IsNotExist(err) {
-			return nil, nil
+		if ad != nil {
+			return nil, fmt.Errorf("decryption failed (wrong key, tampered data, or file moved from another ID): %w", err)
 		}
-		return nil, fmt.Errorf("failed to stat session cache: %w", err)
+		return nil, fmt.Errorf("decryption failed (wrong key or tampered data): %w", err)
 	}
 
-	if !NoLock && time.Since(info.ModTime()) > time.Duration(SessionTimeout)*time.Second {
-		_ = os.Remove(cachePath)
-		return nil, nil // expired
+	if header.IsPadded() {
+		if plaintext, err = unpad(plaintext); err != nil {
+			return nil, err
+		}
+	}
+	if header.IsCompressed() {
+		return inflate(plaintext)
+	}
+	return plaintext, nil
+}
+
+// additionalData concatenates the envelope header and the optional caller ad.
+func additionalData(header []byte, ad []byte) []byte {
+	if ad == nil {
+		return header
+	}
+	return append(append(make([]byte, 0, len(header)+len(ad)), header...), ad...)
+}
+
+// decryptLegacy opens a headerless payload: [12B nonce][N bytes ciphertext + 16B auth tag]
+func decryptLegacy(encrypted []byte, key []byte) ([]byte, error) {
+	minSize := NonceSize + 16 // 16 = GCM auth tag
+	if len(encrypted) < minSize {
+		return nil, fmt.Errorf("encrypted data too short: got %d bytes, need at least %d", len(encrypted), minSize)
 	}
 
-	data, err := os.ReadFile(cachePath)
+	gcm, err := newGCM(key)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read session cache: %w", err)
+		return nil, err
 	}
 
-	if len(data) != KeySize {
-		return nil, nil // corrupt cache, treat as missing
+	nonce := encrypted[:NonceSize]
+	ciphertext := encrypted[NonceSize:]
+
+	plaintext, err := gcm.Open(nil, nonce, ciphertext, nil)
+	if err != nil {
+		return nil, fmt.Errorf("decryption failed (wrong key or tampered data): %w", err)
 	}
 
-	return data, nil
+	return plaintext, nil
 }
 
-// CacheKey stores the 32-byte master key in session.
-func CacheKey(key []byte) error {
+// newGCM creates an AES-256-GCM AEAD for a 32-byte key.
+func newGCM(key []byte) (cipher.AEAD, error) {
 	if len(key) != KeySize {
-		return fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
 	}
 
-	if err := os.MkdirAll(vaultKeyDir(), 0700); err != nil {
-		return fmt.Errorf("failed to create session directory: %w", err)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
 	}
 
-	cachePath := filepath.Join(vaultKeyDir(), sessionCacheFile)
-	if err := os.WriteFile(cachePath, key, 0600); err != nil {
-		return fmt.Errorf("failed to cache key: %w", err)
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
 	}
 
-	return nil
-}
-
-// ClearSession removes the session cache file.
-func ClearSession() error {
-	cachePath := filepath.Join(vaultKeyDir(), sessionCacheFile)
-	err := os.Remove(cachePath)
-	if err != nil && !os.IsNotExist(err) {
-		return fmt.Errorf("failed to clear session cache: %w", err)
-	}
-	return nil
+	return gcm, nil
 }
 
-// HasActiveSession checks if a valid session key exists for current terminal.
-func HasActiveSession() bool {
-	key, err := GetCachedKey()
-	return err == nil && len(key) == KeySize
+// DeriveKey derives an encryption key from password and salt using Argon2id with LegacyKDFParams.
+func DeriveKey(password string, salt []byte) []byte {
+	return DeriveKeyWithParams(password, salt, LegacyKDFParams)
 }
 
-// GetPPID returns the parent process ID (for debugging/testing).
-func GetPPID() string {
-	return strconv.Itoa(os.Getppid())
+// ExtractSalt returns the first SaltSize bytes from data (global salt from .dredge-key).
+func ExtractSalt(data []byte) []byte {
+	if len(data) < SaltSize {
+		return nil
+	}
+	return data[:SaltSize]
 }
 
 // ============================================================================
@@ -255,60 +331,237 @@ func PasswordVerificationExists() bool {
 	return err == nil
 }
 
-// NewVerificationFileBytes generates the bytes for a .dredge-key file and the derived master key.
-// File format: [16B salt][12B nonce][ciphertext + auth tag]
-// Returns (fileBytes, masterKey, error). Use this when you need both the bytes and the key.
-func NewVerificationFileBytes(password string) ([]byte, []byte, error) {
-	if password == "" {
-		return nil, nil, fmt.Errorf("password cannot be empty")
+// VerifyFile is the parsed content of .dredge-key.
+// Older single-password formats are converted to one slot when parsed.
+type VerifyFile struct {
+	Version int       `json:"version"`
+	Slots   []KeySlot `json:"slots"`
+}
+
+// verifyFileJSON is the on-disk JSON layout, including the single-password fields of versions 2-3.
+type verifyFileJSON struct {
+	Version    int        `json:"version"`
+	Slots      []KeySlot  `json:"slots"`
+	KDF        string     `json:"kdf"`
+	Params     *KDFParams `json:"params"`
+	Salt       []byte     `json:"salt"`
+	Verify     []byte     `json:"verify"`
+	WrappedKey []byte     `json:"wrapped_key"`
+}
+
+// ParseVerifyFile decodes .dredge-key bytes in either the JSON format or the legacy binary layout.
+func ParseVerifyFile(data []byte) (*VerifyFile, error) {
+	var raw verifyFileJSON
+	if err := json.Unmarshal(data, &raw); err != nil {
+		// Legacy binary layout
+		if len(data) < SaltSize+NonceSize+16 {
+			return nil, fmt.Errorf("verification file corrupted (too short)")
+		}
+		slot := KeySlot{
+			Label:  DefaultSlotLabel,
+			Type:   SlotPassword,
+			KDF:    kdfNameArgon2id,
+			Params: LegacyKDFParams,
+			Salt:   data[:SaltSize],
+			Verify: data[SaltSize:],
+		}
+		return &VerifyFile{Version: 1, Slots: []KeySlot{slot}}, nil
+	}
+
+	if raw.Version > VerifyFileVersion {
+		return nil, fmt.Errorf("unsupported .dredge-key version %d (upgrade dredge)", raw.Version)
 	}
 
-	salt := make([]byte, SaltSize)
-	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
-		return nil, nil, fmt.Errorf("failed to generate salt: %w", err)
+	vf := &VerifyFile{Version: raw.Version, Slots: raw.Slots}
+	if raw.Version < 4 {
+		slot := KeySlot{
+			Label:      DefaultSlotLabel,
+			Type:       SlotPassword,
+			KDF:        raw.KDF,
+			Salt:       raw.Salt,
+			Verify:     raw.Verify,
+			WrappedKey: raw.WrappedKey,
+		}
+		if raw.Params != nil {
+			slot.Params = *raw.Params
+		}
+		if raw.Version >= 3 {
+			slot.Verify = nil
+		} else {
+			slot.WrappedKey = nil
+		}
+		vf.Slots = []KeySlot{slot}
+	}
+
+	if len(vf.Slots) == 0 {
+		return nil, fmt.Errorf("verification file has no key slots")
+	}
+	for i := range vf.Slots {
+		if err := vf.Slots[i].validate(); err != nil {
+			return nil, fmt.Errorf("key slot %q: %w", vf.Slots[i].Label, err)
+		}
 	}
+	return vf, nil
+}
 
-	key := DeriveKey(password, salt)
+// HasDataKey reports whether the vault uses a random data key wrapped by its key slots.
+// Older vaults encrypt items with the password-derived key itself.
+func (vf *VerifyFile) HasDataKey() bool {
+	return vf.Version >= 3
+}
 
-	encrypted, err := Encrypt([]byte(VerificationContent), key)
+// Bytes serializes the verification file in the current JSON format.
+// Only vaults with a data key can be written; older ones are migrated by passwd.
+func (vf *VerifyFile) Bytes() ([]byte, error) {
+	if !vf.HasDataKey() {
+		return nil, fmt.Errorf("vault has no data key yet (run 'dredge passwd' to migrate it)")
+	}
+
+	out := VerifyFile{Version: VerifyFileVersion, Slots: vf.Slots}
+	data, err := json.MarshalIndent(out, "", "  ")
 	if err != nil {
-		return nil, nil, fmt.Errorf("failed to encrypt verification: %w", err)
+		return nil, fmt.Errorf("failed to marshal verification file: %w", err)
 	}
+	return append(data, '\n'), nil
+}
 
-	fileBytes := append(salt, encrypted...)
-	return fileBytes, key, nil
+// Unlock tries password against every password slot and returns the vault master key.
+func (vf *VerifyFile) Unlock(password string) ([]byte, error) {
+	key, _, err := vf.UnlockSlotWithKeyfile(password, nil)
+	return key, err
 }
 
-// CreatePasswordVerification creates the .dredge-key file with the given password.
-func CreatePasswordVerification(password string) error {
-	if password == "" {
-		return fmt.Errorf("password cannot be empty")
+// UnlockWithKeyfile is Unlock with a keyfile digest for slots that require one.
+func (vf *VerifyFile) UnlockWithKeyfile(password string, keyfile []byte) ([]byte, error) {
+	key, _, err := vf.UnlockSlotWithKeyfile(password, keyfile)
+	return key, err
+}
+
+// UnlockSlot is Unlock that also returns the index of the slot the password opened.
+// Every slot costs one key derivation, so a wrong password takes as long as the slots combined.
+func (vf *VerifyFile) UnlockSlot(password string) ([]byte, int, error) {
+	return vf.UnlockSlotWithKeyfile(password, nil)
+}
+
+// UnlockSlotWithKeyfile is UnlockSlot with a keyfile digest (nil when none was supplied).
+// Keyfile slots are skipped without a keyfile, so they cost nothing in that case.
+func (vf *VerifyFile) UnlockSlotWithKeyfile(password string, keyfile []byte) ([]byte, int, error) {
+	pw := []byte(password)
+	defer secmem.Wipe(pw)
+	return vf.unlockSlot(pw, keyfile)
+}
+
+// unlockSlot is UnlockSlotWithKeyfile for a password that can be wiped.
+func (vf *VerifyFile) unlockSlot(password, keyfile []byte) ([]byte, int, error) {
+	needsKeyfile := false
+	for i := range vf.Slots {
+		if vf.Slots[i].Type != SlotPassword {
+			continue
+		}
+		if vf.Slots[i].Keyfile && keyfile == nil {
+			needsKeyfile = true
+			continue
+		}
+		key, err := vf.Slots[i].unlock(password, keyfile)
+		if err == nil {
+			return key, i, nil
+		}
+		if err != errWrongPassword {
+			return nil, -1, err
+		}
+	}
+	if needsKeyfile {
+		return nil, -1, fmt.Errorf("wrong password, or this vault needs its keyfile (--keyfile or %s)", KeyfileEnvVar)
+	}
+	if keyfile != nil && vf.HasKeyfileSlots() {
+		return nil, -1, fmt.Errorf("wrong password or keyfile")
+	}
+	return nil, -1, fmt.Errorf("wrong password")
+}
+
+// UnlockSlotAt tries password against the password slot at idx only.
+func (vf *VerifyFile) UnlockSlotAt(idx int, password string, keyfile []byte) ([]byte, error) {
+	if idx < 0 || idx >= len(vf.Slots) || vf.Slots[idx].Type != SlotPassword {
+		return nil, fmt.Errorf("no password slot at index %d", idx)
+	}
+	if vf.Slots[idx].Keyfile && keyfile == nil {
+		return nil, fmt.Errorf("key slot %q needs its keyfile (--keyfile or %s)", vf.Slots[idx].Label, KeyfileEnvVar)
+	}
+	pw := []byte(password)
+	defer secmem.Wipe(pw)
+	key, err := vf.Slots[idx].unlock(pw, keyfile)
+	if err == errWrongPassword {
+		return nil, fmt.Errorf("wrong password for key slot %q", vf.Slots[idx].Label)
 	}
+	return key, err
+}
 
+// HasKeyfileSlots reports whether any password slot requires a keyfile.
+func (vf *VerifyFile) HasKeyfileSlots() bool {
+	for i := range vf.Slots {
+		if vf.Slots[i].Keyfile {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadVerifyFile reads and parses the active vault's .dredge-key.
+func ReadVerifyFile() (*VerifyFile, error) {
 	path, err := GetVerifyFilePath()
 	if err != nil {
-		return fmt.Errorf("failed to get verify file path: %w", err)
+		return nil, fmt.Errorf("failed to get verify file path: %w", err)
 	}
 
-	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
-		return fmt.Errorf("failed to create directory: %w", err)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("password verification file not found (run 'dredge add' to create vault)")
+		}
+		return nil, fmt.Errorf("failed to read verification file: %w", err)
 	}
 
-	data, _, err := NewVerificationFileBytes(password)
+	return ParseVerifyFile(data)
+}
+
+// NewVerificationFileBytes generates a fresh vault data key and the .dredge-key bytes
+// wrapping it in a single password slot.
+// Returns (fileBytes, masterKey, error). Use this when you need both the bytes and the key.
+func NewVerificationFileBytes(password string, params KDFParams) ([]byte, []byte, error) {
+	return NewVerificationFileBytesWithKeyfile(password, nil, params)
+}
+
+// NewVerificationFileBytesWithKeyfile is NewVerificationFileBytes whose slot also
+// requires the keyfile with the given digest (nil for none).
+func NewVerificationFileBytesWithKeyfile(password string, keyfile []byte, params KDFParams) ([]byte, []byte, error) {
+	dataKey, err := GenerateDataKey()
 	if err != nil {
-		return err
+		return nil, nil, err
 	}
 
-	if err := os.WriteFile(path, data, 0600); err != nil {
-		return fmt.Errorf("failed to write verification file: %w", err)
+	slot, err := NewPasswordSlotWithKeyfile(DefaultSlotLabel, dataKey, password, keyfile, params)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	return nil
+	vf := &VerifyFile{Version: VerifyFileVersion, Slots: []KeySlot{*slot}}
+	fileBytes, err := vf.Bytes()
+	if err != nil {
+		return nil, nil, err
+	}
+	return fileBytes, dataKey, nil
 }
 
-// DeriveKeyFromVault reads .dredge-key, derives the master key from password, and verifies it.
-// Returns the master key if password is correct. Does NOT cache the key.
-func DeriveKeyFromVault(password string) ([]byte, error) {
+// CreatePasswordVerification creates the .dredge-key file with the given password and default KDF parameters.
+func CreatePasswordVerification(password string) error {
+	_, err := CreatePasswordVerificationWithParams(password, nil, DefaultKDFParams)
+	return err
+}
+
+// CreatePasswordVerificationWithParams creates the .dredge-key file using the given KDF parameters.
+// A non-nil keyfile digest is required alongside the password from then on.
+// Returns the new vault master key. Does NOT cache the key.
+func CreatePasswordVerificationWithParams(password string, keyfile []byte, params KDFParams) ([]byte, error) {
 	if password == "" {
 		return nil, fmt.Errorf("password cannot be empty")
 	}
@@ -318,34 +571,61 @@ func DeriveKeyFromVault(password string) ([]byte, error) {
 		return nil, fmt.Errorf("failed to get verify file path: %w", err)
 	}
 
-	if _, err := os.Stat(path); os.IsNotExist(err) {
-		return nil, fmt.Errorf("password verification file not found (run 'dredge add' to create vault)")
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return nil, fmt.Errorf("failed to create directory: %w", err)
 	}
 
-	data, err := os.ReadFile(path)
+	data, key, err := NewVerificationFileBytesWithKeyfile(password, keyfile, params)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read verification file: %w", err)
+		return nil, err
 	}
 
-	if len(data) < SaltSize+NonceSize+16 {
-		return nil, fmt.Errorf("verification file corrupted (too short)")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write verification file: %w", err)
 	}
 
-	salt := data[:SaltSize]
-	encrypted := data[SaltSize:]
+	return key, nil
+}
 
-	key := DeriveKey(password, salt)
+// DeriveKeyFromVault reads .dredge-key and unlocks the master key with password
+// (plus the configured keyfile, see LoadKeyfile).
+// Uses the KDF parameters recorded in the file. Returns the master key, in locked
+// memory, if password is correct. Does NOT cache the key.
+func DeriveKeyFromVault(password string) ([]byte, error) {
+	pw := []byte(password)
+	defer secmem.Wipe(pw)
+	key, err := unlockVault(pw)
+	return lockKey(key), err
+}
+
+// unlockVault is DeriveKeyFromVault for a password that can be wiped.
+func unlockVault(password []byte) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, fmt.Errorf("password cannot be empty")
+	}
 
-	decrypted, err := Decrypt(encrypted, key)
+	vf, err := ReadVerifyFile()
 	if err != nil {
-		return nil, fmt.Errorf("wrong password")
+		return nil, err
 	}
 
-	if string(decrypted) != VerificationContent {
-		return nil, fmt.Errorf("verification file corrupted (unexpected content)")
+	keyfile, err := LoadKeyfile()
+	if err != nil {
+		return nil, err
 	}
+	defer secmem.Wipe(keyfile)
 
-	return key, nil
+	key, _, err := vf.unlockSlot(password, keyfile)
+	return key, err
+}
+
+// lockKey moves key into locked memory (see secmem) and wipes the original. The
+// vault key is used until the process exits, so it is never released.
+func lockKey(key []byte) []byte {
+	if key == nil {
+		return nil
+	}
+	return secmem.From(key).Bytes()
 }
 
 // VerifyPassword checks if the given password is correct for the current vault.
@@ -368,60 +648,103 @@ func GetKeyWithVerification() ([]byte, error) {
 		return cached, nil
 	}
 
-	// No cached key — use pending password (from --password flag) or prompt
-	var password string
+	// No cached key — try the identity file when no password was given on the command line
+	if pendingPassword == "" {
+		key, err := unlockWithIdentity()
+		if err != nil {
+			return nil, err
+		}
+		if key != nil {
+			return finishUnlock(key), nil
+		}
+	}
+
+	// Use pending password (from --password flag) or prompt
+	var secret *secmem.Buffer
 	if pendingPassword != "" {
-		password = pendingPassword
+		secret = secmem.From([]byte(pendingPassword))
 		pendingPassword = "" // clear immediately after use
 	} else {
 		var err error
-		password, err = ui.PromptPassword()
+		secret, err = ui.PromptSecret("Password: ")
 		if err != nil {
 			return nil, fmt.Errorf("failed to prompt for password: %w", err)
 		}
 	}
+	defer secret.Destroy()
+	password := secret.Bytes()
 
 	if DebugMode {
 		fmt.Fprintf(os.Stderr, "[DEBUG] password len=%d\n", len(password))
 	}
 
-	if password == "" {
+	if len(password) == 0 {
 		return nil, fmt.Errorf("password cannot be empty")
 	}
 
 	var key []byte
 
 	if !PasswordVerificationExists() {
-		// First time — create verification file
-		path, err := GetVerifyFilePath()
+		// First time — create verification file (bound to the keyfile, if one is configured)
+		keyfile, err := LoadKeyfile()
 		if err != nil {
 			return nil, err
 		}
-		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
-			return nil, fmt.Errorf("failed to create directory: %w", err)
-		}
-		fileBytes, derivedKey, err := NewVerificationFileBytes(password)
+		derivedKey, err := CreatePasswordVerificationWithParams(string(password), keyfile, DefaultKDFParams)
 		if err != nil {
 			return nil, fmt.Errorf("failed to create password verification: %w", err)
 		}
-		if err := os.WriteFile(path, fileBytes, 0600); err != nil {
-			return nil, fmt.Errorf("failed to write verification file: %w", err)
-		}
 		fmt.Fprintln(os.Stderr, "Created password verification file")
 		key = derivedKey
 	} else {
 		// Verify password and derive key
-		derivedKey, err := DeriveKeyFromVault(password)
+		derivedKey, err := unlockVault(password)
 		if err != nil {
 			return nil, err
 		}
 		key = derivedKey
 	}
 
-	// Cache the key
+	return finishUnlock(key), nil
+}
+
+// unlockWithIdentity unlocks a vault through its recipient slot for the configured identity.
+// Returns (nil, nil) when the vault has no recipients or no identity is available.
+func unlockWithIdentity() ([]byte, error) {
+	if !PasswordVerificationExists() {
+		return nil, nil
+	}
+	vf, err := ReadVerifyFile()
+	if err != nil || !vf.HasRecipients() {
+		return nil, nil
+	}
+
+	identity, err := LoadIdentity()
+	if err != nil {
+		return nil, err
+	}
+	if identity == nil || vf.FindRecipient(identity.PublicKey()) < 0 {
+		return nil, nil
+	}
+
+	key, _, err := vf.UnlockIdentity(identity)
+	if err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// finishUnlock moves a freshly unlocked key into locked memory, caches it and runs
+// the OnUnlock hook.
+func finishUnlock(key []byte) []byte {
+	key = lockKey(key)
 	if err := CacheKey(key); err != nil {
 		fmt.Fprintf(os.Stderr, "Warning: failed to cache key: %v\n", err)
 	}
 
-	return key, nil
+	if OnUnlock != nil {
+		OnUnlock(key)
+	}
+
+	return key
 }
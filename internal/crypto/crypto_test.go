@@ -22,8 +22,8 @@ func TestEncryptDecrypt_RoundTrip(t *testing.T) {
 		t.Fatalf("Encrypt failed: %v", err)
 	}
 
-	// Verify encrypted data is at least nonce + ciphertext + auth tag
-	minExpectedSize := NonceSize + len(plaintext) + 16 // 16 = GCM auth tag
+	// Verify encrypted data is at least header + nonce + ciphertext + auth tag
+	minExpectedSize := HeaderSize + NonceSize + len(plaintext) + 16 // 16 = GCM auth tag
 	if len(encrypted) < minExpectedSize {
 		t.Errorf("Encrypted data too short: got %d bytes, expected at least %d", len(encrypted), minExpectedSize)
 	}
@@ -123,9 +123,9 @@ func TestEncrypt_UniqueNonces(t *testing.T) {
 		t.Fatalf("Second encrypt failed: %v", err)
 	}
 
-	// Nonces should be different (first NonceSize bytes)
-	nonce1 := encrypted1[:NonceSize]
-	nonce2 := encrypted2[:NonceSize]
+	// Nonces should be different (NonceSize bytes after the envelope header)
+	nonce1 := encrypted1[HeaderSize : HeaderSize+NonceSize]
+	nonce2 := encrypted2[HeaderSize : HeaderSize+NonceSize]
 
 	if bytes.Equal(nonce1, nonce2) {
 		t.Error("Nonces should be unique for each encryption, but they're identical")
@@ -0,0 +1,134 @@
+package crypto
+
+import (
+	"bytes"
+	"fmt"
+)
+
+// ============================================================================
+// Envelope Format
+// ============================================================================
+//
+// Every ciphertext written by Encrypt starts with a small self-describing header:
+//
+//	[4B magic "DRDG"][1B version][1B cipher][1B kdf][1B flags][12B nonce][ciphertext + 16B tag]
+//
+// The header is passed to AES-GCM as additional data, so it cannot be altered
+// without failing authentication. Ciphertexts without the magic bytes are the
+// legacy headerless format ([12B nonce][ciphertext + tag]) and are still readable.
+//
+// Payloads that belong to a vault item set FlagBoundAD and also authenticate
+// AssociatedData(kind, id), so a file moved to another ID (or an item swapped
+// with a storage blob) fails to decrypt instead of silently opening.
+//
+// Storage blobs set FlagStream and are split into authenticated chunks (stream.go).
+// Vaults with padding enabled set FlagPadded (padding.go), and vaults with compression
+// enabled set FlagCompressed on payloads that were worth compressing (compress.go).
+
+const (
+	EnvelopeMagic   = "DRDG"
+	EnvelopeVersion = 1
+	HeaderSize      = 8
+)
+
+// Cipher identifiers
+const (
+	CipherAES256GCM byte = 1
+)
+
+// KDF identifiers (how the encrypting key was obtained)
+const (
+	KDFArgon2id byte = 1
+)
+
+// Header flags
+const (
+	FlagBoundAD byte = 1 << 0 // caller-supplied associated data is authenticated after the header
+	FlagStream  byte = 1 << 1 // chunked stream (see stream.go) instead of a single GCM message
+	FlagPadded  byte = 1 << 2 // plaintext padded to a size bucket (see padding.go)
+
+	FlagCompressed byte = 1 << 3 // plaintext deflated before padding and encryption (see compress.go)
+
+	knownFlags = FlagBoundAD | FlagStream | FlagPadded | FlagCompressed
+)
+
+// Associated data kinds: what a bound payload is stored as
+const (
+	KindItem   = "item"   // items/<id> (TOML)
+	KindBlob   = "blob"   // storage/<id> (binary content)
+	KindKey    = "key"    // vault data key wrapped in .dredge-key
+	KindIndex  = "index"  // .dredge-index (metadata of every item)
+	KindLocked = "locked" // content of a double-locked item, sealed inside the item
+)
+
+// dataKeyAD binds a wrapped data key to its role, so no other vault payload can stand in for it.
+var dataKeyAD = AssociatedData(KindKey, "vault")
+
+// AssociatedData returns the additional data that binds a payload to its kind and item ID.
+func AssociatedData(kind, id string) []byte {
+	return []byte("dredge/" + kind + "/" + id)
+}
+
+// Header is the parsed envelope header of an encrypted payload.
+type Header struct {
+	Version byte
+	Cipher  byte
+	KDF     byte
+	Flags   byte
+}
+
+// defaultHeader returns the header used for newly encrypted payloads.
+func defaultHeader() Header {
+	return Header{
+		Version: EnvelopeVersion,
+		Cipher:  CipherAES256GCM,
+		KDF:     KDFArgon2id,
+	}
+}
+
+// Bytes serializes the header to its 8-byte wire form.
+func (h Header) Bytes() []byte {
+	b := make([]byte, 0, HeaderSize)
+	b = append(b, EnvelopeMagic...)
+	return append(b, h.Version, h.Cipher, h.KDF, h.Flags)
+}
+
+// validate checks that this build knows how to open a payload with this header.
+func (h Header) validate() error {
+	if h.Version == 0 || h.Version > EnvelopeVersion {
+		return fmt.Errorf("unsupported envelope version %d (upgrade dredge)", h.Version)
+	}
+	if h.Cipher != CipherAES256GCM {
+		return fmt.Errorf("unsupported cipher id %d (upgrade dredge)", h.Cipher)
+	}
+	if h.Flags&^knownFlags != 0 {
+		return fmt.Errorf("unsupported envelope flags %#x (upgrade dredge)", h.Flags)
+	}
+	return nil
+}
+
+// IsBound reports whether the payload authenticates associated data (item ID and kind).
+func (h Header) IsBound() bool {
+	return h.Flags&FlagBoundAD != 0
+}
+
+// ParseHeader extracts the envelope header from data.
+// Returns false if data does not start with the envelope magic (legacy format).
+func ParseHeader(data []byte) (Header, bool) {
+	if len(data) < HeaderSize || !bytes.HasPrefix(data, []byte(EnvelopeMagic)) {
+		return Header{}, false
+	}
+	return Header{
+		Version: data[4],
+		Cipher:  data[5],
+		KDF:     data[6],
+		Flags:   data[7],
+	}, true
+}
+
+// IsLegacyFormat reports whether data is a headerless ciphertext from before the envelope format.
+// Only the leading bytes are inspected, so callers may pass a short prefix of a file.
+func IsLegacyFormat(data []byte) bool {
+	_, ok := ParseHeader(data)
+	return !ok
+}
@@ -0,0 +1,179 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+// encryptLegacy produces the pre-envelope format: [12B nonce][ciphertext + tag]
+func encryptLegacy(t *testing.T, plaintext, key []byte) []byte {
+	t.Helper()
+	gcm, err := newGCM(key)
+	if err != nil {
+		t.Fatalf("newGCM failed: %v", err)
+	}
+	nonce := make([]byte, NonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		t.Fatalf("nonce generation failed: %v", err)
+	}
+	return gcm.Seal(nonce, nonce, plaintext, nil)
+}
+
+func TestEncrypt_WritesEnvelopeHeader(t *testing.T) {
+	key := testKey(t)
+
+	encrypted, err := Encrypt([]byte("payload"), key)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	header, ok := ParseHeader(encrypted)
+	if !ok {
+		t.Fatal("Encrypt output has no envelope header")
+	}
+	if header.Version != EnvelopeVersion || header.Cipher != CipherAES256GCM || header.KDF != KDFArgon2id {
+		t.Errorf("unexpected header: %+v", header)
+	}
+	if IsLegacyFormat(encrypted) {
+		t.Error("IsLegacyFormat should be false for envelope output")
+	}
+}
+
+func TestDecrypt_LegacyFormat(t *testing.T) {
+	key := testKey(t)
+	plaintext := []byte("written by an older dredge")
+
+	legacy := encryptLegacy(t, plaintext, key)
+	if !IsLegacyFormat(legacy) {
+		t.Fatal("IsLegacyFormat should be true for headerless ciphertext")
+	}
+
+	decrypted, err := Decrypt(legacy, key)
+	if err != nil {
+		t.Fatalf("Decrypt legacy failed: %v", err)
+	}
+	if !bytes.Equal(decrypted, plaintext) {
+		t.Errorf("Decrypted legacy data mismatch.\nGot:  %q\nWant: %q", decrypted, plaintext)
+	}
+}
+
+func TestDecrypt_TamperedHeader(t *testing.T) {
+	key := testKey(t)
+
+	encrypted, err := Encrypt([]byte("payload"), key)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	// Flip the flags byte: header is authenticated, so this must fail
+	encrypted[7] ^= 0x01
+	if _, err := Decrypt(encrypted, key); err == nil {
+		t.Error("Decrypt should fail when the envelope header is modified")
+	}
+}
+
+func TestDecrypt_UnsupportedVersion(t *testing.T) {
+	key := testKey(t)
+
+	encrypted, err := Encrypt([]byte("payload"), key)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	encrypted[4] = EnvelopeVersion + 1
+	if _, err := Decrypt(encrypted, key); err == nil {
+		t.Error("Decrypt should reject an unknown envelope version")
+	}
+}
+
+func TestEncryptWithAD_BindsAssociatedData(t *testing.T) {
+	key := testKey(t)
+	ad := AssociatedData(KindItem, "abc")
+
+	encrypted, err := EncryptWithAD([]byte("payload"), key, ad)
+	if err != nil {
+		t.Fatalf("EncryptWithAD failed: %v", err)
+	}
+
+	header, _ := ParseHeader(encrypted)
+	if !header.IsBound() {
+		t.Error("EncryptWithAD output should set FlagBoundAD")
+	}
+
+	if _, err := DecryptWithAD(encrypted, key, ad); err != nil {
+		t.Fatalf("DecryptWithAD with matching ad failed: %v", err)
+	}
+
+	// Moved to another ID, or reinterpreted as a storage blob
+	for _, wrong := range [][]byte{AssociatedData(KindItem, "xyz"), AssociatedData(KindBlob, "abc")} {
+		if _, err := DecryptWithAD(encrypted, key, wrong); err == nil {
+			t.Errorf("DecryptWithAD should fail with ad %q", wrong)
+		}
+	}
+
+	if _, err := Decrypt(encrypted, key); err == nil {
+		t.Error("Decrypt without ad should fail for a bound payload")
+	}
+}
+
+func TestDecryptWithAD_AcceptsUnboundPayloads(t *testing.T) {
+	key := testKey(t)
+	ad := AssociatedData(KindItem, "abc")
+
+	unbound, err := Encrypt([]byte("payload"), key)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	// Written before ID binding existed: still readable until selfheal rewrites it
+	for _, data := range [][]byte{unbound, encryptLegacy(t, []byte("payload"), key)} {
+		if _, err := DecryptWithAD(data, key, ad); err != nil {
+			t.Errorf("DecryptWithAD should accept unbound payload: %v", err)
+		}
+	}
+}
+
+func TestDecryptBound_RejectsUnboundPayloads(t *testing.T) {
+	key := testKey(t)
+	ad := AssociatedData(KindItem, "abc")
+
+	bound, err := EncryptWithAD([]byte("payload"), key, ad)
+	if err != nil {
+		t.Fatalf("EncryptWithAD failed: %v", err)
+	}
+	if got, err := DecryptBound(bound, key, ad); err != nil || string(got) != "payload" {
+		t.Errorf("DecryptBound(bound) = %q, %v", got, err)
+	}
+	if _, err := DecryptBound(bound, key, AssociatedData(KindItem, "xyz")); err == nil {
+		t.Error("DecryptBound should fail for another ID")
+	}
+
+	unbound, err := Encrypt([]byte("payload"), key)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	for _, data := range [][]byte{unbound, encryptLegacy(t, []byte("payload"), key)} {
+		if _, err := DecryptBound(data, key, ad); err == nil {
+			t.Error("DecryptBound should refuse an unbound payload")
+		}
+		if _, err := NewBoundDecryptReader(bytes.NewReader(data), key, ad); err == nil {
+			t.Error("NewBoundDecryptReader should refuse an unbound payload")
+		}
+	}
+}
+
+func TestDecrypt_UnknownFlags(t *testing.T) {
+	key := testKey(t)
+
+	encrypted, err := Encrypt([]byte("payload"), key)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+
+	encrypted[7] = 0x80
+	if _, err := Decrypt(encrypted, key); err == nil {
+		t.Error("Decrypt should reject unknown envelope flags")
+	}
+}
@@ -0,0 +1,109 @@
+package crypto
+
+import (
+	"fmt"
+	"time"
+
+	"golang.org/x/crypto/argon2"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/secmem"
+)
+
+// ============================================================================
+// KDF Parameters
+// ============================================================================
+
+// KDFParams holds the Argon2id cost parameters recorded in .dredge-key.
+type KDFParams struct {
+	Time    uint32 `json:"time"`    // iterations
+	Memory  uint32 `json:"memory"`  // KiB
+	Threads uint8  `json:"threads"` // parallelism
+}
+
+// LegacyKDFParams are the parameters every vault used before they were recorded in .dredge-key.
+var LegacyKDFParams = KDFParams{Time: Argon2Time, Memory: Argon2Memory, Threads: Argon2Threads}
+
+// DefaultKDFParams are used for new vaults (RFC 9106 second recommended option).
+var DefaultKDFParams = KDFParams{Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// Bounds accepted when reading parameters from disk. The upper bounds stop a
+// tampered .dredge-key from making every unlock exhaust memory or hang.
+const (
+	minKDFTime    = 1
+	maxKDFTime    = 64
+	minKDFMemory  = 8 * 1024        // 8 MiB
+	maxKDFMemory  = 4 * 1024 * 1024 // 4 GiB
+	minKDFThreads = 1
+)
+
+// DefaultUnlockTime is the target used by CalibrateKDF when none is given.
+const DefaultUnlockTime = time.Second
+
+// Validate checks that the parameters are within the accepted bounds.
+func (p KDFParams) Validate() error {
+	if p.Time < minKDFTime || p.Time > maxKDFTime {
+		return fmt.Errorf("argon2 time must be between %d and %d, got %d", minKDFTime, maxKDFTime, p.Time)
+	}
+	if p.Memory < minKDFMemory || p.Memory > maxKDFMemory {
+		return fmt.Errorf("argon2 memory must be between %d and %d MiB, got %d KiB", minKDFMemory/1024, maxKDFMemory/1024, p.Memory)
+	}
+	if p.Threads < minKDFThreads {
+		return fmt.Errorf("argon2 threads must be at least %d", minKDFThreads)
+	}
+	return nil
+}
+
+// String formats the parameters for display (e.g. "time=3 memory=64MiB threads=4").
+func (p KDFParams) String() string {
+	return fmt.Sprintf("time=%d memory=%dMiB threads=%d", p.Time, p.Memory/1024, p.Threads)
+}
+
+// DeriveKeyWithParams derives an encryption key from password and salt using Argon2id with explicit parameters.
+func DeriveKeyWithParams(password string, salt []byte, params KDFParams) []byte {
+	pw := []byte(password)
+	defer secmem.Wipe(pw)
+	return deriveKey(pw, salt, params)
+}
+
+// deriveKey is DeriveKeyWithParams for a password that can be wiped.
+func deriveKey(password, salt []byte, params KDFParams) []byte {
+	return argon2.IDKey(
+		password,
+		salt,
+		params.Time,
+		params.Memory,
+		params.Threads,
+		Argon2KeyLength,
+	)
+}
+
+// CalibrateKDF benchmarks Argon2id on this machine and returns parameters whose
+// derivation takes roughly target. Memory and threads stay at DefaultKDFParams;
+// only the iteration count is scaled, and never below the default.
+func CalibrateKDF(target time.Duration) KDFParams {
+	if target <= 0 {
+		target = DefaultUnlockTime
+	}
+
+	params := DefaultKDFParams
+	probe := params
+	probe.Time = 1
+
+	salt := make([]byte, SaltSize)
+	start := time.Now()
+	DeriveKeyWithParams("calibration", salt, probe)
+	perIteration := time.Since(start)
+	if perIteration <= 0 {
+		perIteration = time.Millisecond
+	}
+
+	iterations := uint32(target / perIteration)
+	if iterations > params.Time {
+		params.Time = iterations
+	}
+	if params.Time > maxKDFTime {
+		params.Time = maxKDFTime
+	}
+
+	return params
+}
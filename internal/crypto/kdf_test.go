@@ -0,0 +1,153 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+// fastKDFParams keeps tests quick while staying within the accepted bounds.
+var fastKDFParams = KDFParams{Time: 1, Memory: minKDFMemory, Threads: 1}
+
+func TestKDFParams_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		params  KDFParams
+		wantErr bool
+	}{
+		{"default", DefaultKDFParams, false},
+		{"legacy", LegacyKDFParams, false},
+		{"zero time", KDFParams{Time: 0, Memory: 64 * 1024, Threads: 4}, true},
+		{"huge time", KDFParams{Time: maxKDFTime + 1, Memory: 64 * 1024, Threads: 4}, true},
+		{"tiny memory", KDFParams{Time: 3, Memory: 1024, Threads: 4}, true},
+		{"huge memory", KDFParams{Time: 3, Memory: maxKDFMemory + 1, Threads: 4}, true},
+		{"zero threads", KDFParams{Time: 3, Memory: 64 * 1024, Threads: 0}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.params.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestVerifyFile_RoundTrip(t *testing.T) {
+	fileBytes, key, err := NewVerificationFileBytes("hunter2", fastKDFParams)
+	if err != nil {
+		t.Fatalf("NewVerificationFileBytes failed: %v", err)
+	}
+
+	vf, err := ParseVerifyFile(fileBytes)
+	if err != nil {
+		t.Fatalf("ParseVerifyFile failed: %v", err)
+	}
+	if vf.Version != VerifyFileVersion {
+		t.Errorf("Version = %d, want %d", vf.Version, VerifyFileVersion)
+	}
+	if vf.Slots[0].Params != fastKDFParams {
+		t.Errorf("Params = %v, want %v", vf.Slots[0].Params, fastKDFParams)
+	}
+
+	unlocked, err := vf.Unlock("hunter2")
+	if err != nil {
+		t.Fatalf("Unlock with correct password failed: %v", err)
+	}
+	if !bytes.Equal(unlocked, key) {
+		t.Error("Unlock returned a different key than was created")
+	}
+
+	if _, err := vf.Unlock("wrong"); err == nil {
+		t.Error("Unlock should fail with wrong password")
+	}
+}
+
+func TestVerifyFile_LegacyBinaryLayout(t *testing.T) {
+	salt := make([]byte, SaltSize)
+	rand.Read(salt)
+	key := DeriveKey("hunter2", salt)
+
+	// Legacy files were [salt][nonce][ciphertext] with no envelope header
+	verify := encryptLegacy(t, []byte(VerificationContent), key)
+	data := append(append([]byte{}, salt...), verify...)
+
+	vf, err := ParseVerifyFile(data)
+	if err != nil {
+		t.Fatalf("ParseVerifyFile failed on legacy layout: %v", err)
+	}
+	if vf.Version != 1 {
+		t.Errorf("Version = %d, want 1", vf.Version)
+	}
+	if vf.Slots[0].Params != LegacyKDFParams {
+		t.Errorf("Params = %v, want legacy %v", vf.Slots[0].Params, LegacyKDFParams)
+	}
+
+	unlocked, err := vf.Unlock("hunter2")
+	if err != nil {
+		t.Fatalf("Unlock of legacy file failed: %v", err)
+	}
+	if !bytes.Equal(unlocked, key) {
+		t.Error("legacy unlock returned a different key")
+	}
+}
+
+func TestParseVerifyFile_RejectsInvalidParams(t *testing.T) {
+	fileBytes, _, err := NewVerificationFileBytes("hunter2", fastKDFParams)
+	if err != nil {
+		t.Fatalf("NewVerificationFileBytes failed: %v", err)
+	}
+
+	tampered := bytes.Replace(fileBytes, []byte(`"memory": 8192`), []byte(`"memory": 999999999`), 1)
+	if bytes.Equal(tampered, fileBytes) {
+		t.Fatal("test setup: memory field not found in verification file")
+	}
+	if _, err := ParseVerifyFile(tampered); err == nil {
+		t.Error("ParseVerifyFile should reject out-of-range parameters")
+	}
+}
+
+func TestCalibrateKDF_NeverBelowDefault(t *testing.T) {
+	params := CalibrateKDF(time.Millisecond)
+	if params.Time < DefaultKDFParams.Time {
+		t.Errorf("calibrated time %d is below default %d", params.Time, DefaultKDFParams.Time)
+	}
+	if err := params.Validate(); err != nil {
+		t.Errorf("calibrated params invalid: %v", err)
+	}
+}
+
+func TestVerifyFile_Version2StillUnlocks(t *testing.T) {
+	salt := make([]byte, SaltSize)
+	rand.Read(salt)
+	key := DeriveKeyWithParams("hunter2", salt, fastKDFParams)
+
+	verify, err := Encrypt([]byte(VerificationContent), key)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	data, err := json.Marshal(map[string]any{
+		"version": 2, "kdf": kdfNameArgon2id, "params": fastKDFParams, "salt": salt, "verify": verify,
+	})
+	if err != nil {
+		t.Fatalf("Marshal failed: %v", err)
+	}
+
+	vf, err := ParseVerifyFile(data)
+	if err != nil {
+		t.Fatalf("ParseVerifyFile(v2) failed: %v", err)
+	}
+	if vf.HasDataKey() {
+		t.Error("version 2 files have no data key")
+	}
+	unlocked, err := vf.Unlock("hunter2")
+	if err != nil {
+		t.Fatalf("Unlock(v2) failed: %v", err)
+	}
+	if !bytes.Equal(unlocked, key) {
+		t.Error("version 2 master key should be the password-derived key")
+	}
+}
@@ -0,0 +1,104 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/secmem"
+)
+
+// ============================================================================
+// Keyfiles
+// ============================================================================
+//
+// A keyfile is a second unlock factor: any file (a USB stick, ~/.config/...) whose
+// SHA-256 digest is mixed into the Argon2id input of a password slot. It never goes
+// to the git remote, so a leaked remote plus a phished password is not enough.
+
+const (
+	// KeyfileEnvVar supplies the keyfile path when --keyfile is not given.
+	KeyfileEnvVar = "DREDGE_KEYFILE"
+
+	// keyfileSize is the amount of random data written by GenerateKeyfile.
+	keyfileSize = 64
+)
+
+// KeyfilePath is the keyfile used to unlock keyfile-protected slots (set from main).
+// Empty means DREDGE_KEYFILE, then no keyfile.
+var KeyfilePath string
+
+// ReadKeyfile returns the SHA-256 digest of the keyfile at path.
+// Any non-empty file works; only its digest is ever used.
+func ReadKeyfile(path string) ([]byte, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open keyfile: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	n, err := io.Copy(h, f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read keyfile: %w", err)
+	}
+	if n == 0 {
+		return nil, fmt.Errorf("keyfile %s is empty", path)
+	}
+	return h.Sum(nil), nil
+}
+
+// LoadKeyfile resolves the keyfile to unlock with: KeyfilePath, then DREDGE_KEYFILE.
+// Returns (nil, nil) when neither is set.
+func LoadKeyfile() ([]byte, error) {
+	path := KeyfilePath
+	if path == "" {
+		path = os.Getenv(KeyfileEnvVar)
+	}
+	if path == "" {
+		return nil, nil
+	}
+	return ReadKeyfile(path)
+}
+
+// GenerateKeyfile writes keyfileSize random bytes to path. Never overwrites an existing file.
+func GenerateKeyfile(path string) error {
+	data := make([]byte, keyfileSize)
+	if _, err := io.ReadFull(rand.Reader, data); err != nil {
+		return fmt.Errorf("failed to generate keyfile: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return fmt.Errorf("failed to create keyfile directory: %w", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0400)
+	if err != nil {
+		return fmt.Errorf("failed to create keyfile: %w", err)
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write keyfile: %w", err)
+	}
+	if err := f.Close(); err != nil {
+		return fmt.Errorf("failed to write keyfile: %w", err)
+	}
+	return nil
+}
+
+// keyfileInput combines a password with a keyfile digest into the Argon2id input, in a
+// secure buffer the caller destroys. Without a keyfile the password is used unchanged,
+// so plain password slots keep working.
+func keyfileInput(password, keyfile []byte) *secmem.Buffer {
+	if keyfile == nil {
+		in := secmem.New(len(password))
+		copy(in.Bytes(), password)
+		return in
+	}
+	in := secmem.New(len(password) + 1 + len(keyfile))
+	n := copy(in.Bytes(), password)
+	copy(in.Bytes()[n+1:], keyfile)
+	return in
+}
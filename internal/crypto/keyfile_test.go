@@ -0,0 +1,96 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestReadKeyfile(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	path := filepath.Join(tmpDir, "key")
+	if err := GenerateKeyfile(path); err != nil {
+		t.Fatalf("GenerateKeyfile failed: %v", err)
+	}
+	if err := GenerateKeyfile(path); err == nil {
+		t.Error("GenerateKeyfile should refuse to overwrite an existing keyfile")
+	}
+
+	data, _ := os.ReadFile(path)
+	if len(data) != keyfileSize {
+		t.Errorf("keyfile is %d bytes, want %d", len(data), keyfileSize)
+	}
+
+	digest, err := ReadKeyfile(path)
+	if err != nil {
+		t.Fatalf("ReadKeyfile failed: %v", err)
+	}
+	want := sha256.Sum256(data)
+	if !bytes.Equal(digest, want[:]) {
+		t.Error("ReadKeyfile should return the SHA-256 of the file")
+	}
+
+	empty := filepath.Join(tmpDir, "empty")
+	_ = os.WriteFile(empty, nil, 0600)
+	if _, err := ReadKeyfile(empty); err == nil {
+		t.Error("ReadKeyfile should reject an empty file")
+	}
+}
+
+func TestKeyfileSlot_RequiresKeyfile(t *testing.T) {
+	keyfile := sha256.Sum256([]byte("usb stick"))
+	other := sha256.Sum256([]byte("another file"))
+
+	fileBytes, dataKey, err := NewVerificationFileBytesWithKeyfile("phished", keyfile[:], fastKDFParams)
+	if err != nil {
+		t.Fatalf("NewVerificationFileBytesWithKeyfile failed: %v", err)
+	}
+	vf, err := ParseVerifyFile(fileBytes)
+	if err != nil {
+		t.Fatalf("ParseVerifyFile failed: %v", err)
+	}
+	if !vf.Slots[0].Keyfile {
+		t.Fatal("slot should record that it requires a keyfile")
+	}
+
+	// Password alone is not enough
+	_, err = vf.Unlock("phished")
+	if err == nil || !strings.Contains(err.Error(), "keyfile") {
+		t.Errorf("Unlock without keyfile = %v; want an error mentioning the keyfile", err)
+	}
+
+	if _, err := vf.UnlockWithKeyfile("phished", other[:]); err == nil {
+		t.Error("UnlockWithKeyfile should fail with the wrong keyfile")
+	}
+	if _, err := vf.UnlockWithKeyfile("wrong", keyfile[:]); err == nil {
+		t.Error("UnlockWithKeyfile should fail with the wrong password")
+	}
+
+	key, err := vf.UnlockWithKeyfile("phished", keyfile[:])
+	if err != nil {
+		t.Fatalf("UnlockWithKeyfile failed: %v", err)
+	}
+	if !bytes.Equal(key, dataKey) {
+		t.Error("UnlockWithKeyfile returned the wrong data key")
+	}
+}
+
+func TestKeyfileSlot_PlainSlotIgnoresKeyfile(t *testing.T) {
+	keyfile := sha256.Sum256([]byte("usb stick"))
+
+	fileBytes, dataKey, _ := NewVerificationFileBytes("laptop", fastKDFParams)
+	vf, _ := ParseVerifyFile(fileBytes)
+
+	// A keyfile supplied for another vault must not lock this one
+	key, err := vf.UnlockWithKeyfile("laptop", keyfile[:])
+	if err != nil {
+		t.Fatalf("UnlockWithKeyfile on a plain slot failed: %v", err)
+	}
+	if !bytes.Equal(key, dataKey) {
+		t.Error("UnlockWithKeyfile returned the wrong data key")
+	}
+}
@@ -0,0 +1,194 @@
+//go:build linux
+
+package crypto
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"golang.org/x/sys/unix"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/config"
+)
+
+// ============================================================================
+// Kernel Keyring
+// ============================================================================
+//
+// Session keys are stored as "user" keys in the session (or user) keyring, named like
+// the file cache is laid out (see keyringDescription). The kernel enforces the timeout
+// itself: an expired key can no longer be read, and it is garbage collected.
+
+const (
+	keyringKeyType = "user"
+
+	// Possessor: all; other processes of the same user: view, read, search (like a 0600 file)
+	keyringPerm = 0x3f000000 | 0x000b0000
+)
+
+// keyringID resolves the configured keyring to its serial number. It is never created:
+// a process without a session keyring (no pam_keyinit) would otherwise get a fresh one
+// that dies with it, so the kernel's user-session keyring is used instead.
+func keyringID(name string) (int, error) {
+	spec := unix.KEY_SPEC_SESSION_KEYRING
+	if name == config.KeyringUser {
+		spec = unix.KEY_SPEC_USER_KEYRING
+	}
+	id, err := unix.KeyctlGetKeyringID(spec, false)
+	if err != nil && spec == unix.KEY_SPEC_SESSION_KEYRING {
+		id, err = unix.KeyctlGetKeyringID(unix.KEY_SPEC_USER_SESSION_KEYRING, false)
+	}
+	if err != nil {
+		return 0, keyringError("open", err)
+	}
+	return id, nil
+}
+
+// keyringError maps errors meaning "no keyring support here" (old kernel, seccomp
+// filter in containers) to errKeyringUnavailable.
+func keyringError(op string, err error) error {
+	if errors.Is(err, unix.ENOSYS) || errors.Is(err, unix.EPERM) || errors.Is(err, unix.EOPNOTSUPP) {
+		return errKeyringUnavailable
+	}
+	return fmt.Errorf("failed to %s kernel keyring: %w", op, err)
+}
+
+// keyringMissing reports whether err means the key is absent, expired or revoked.
+func keyringMissing(err error) bool {
+	return errors.Is(err, unix.ENOKEY) || errors.Is(err, unix.EKEYEXPIRED) || errors.Is(err, unix.EKEYREVOKED)
+}
+
+// keyringGet returns the payload stored under desc, or nil if there is none.
+func keyringGet(ring, desc string) ([]byte, error) {
+	ringID, err := keyringID(ring)
+	if err != nil {
+		return nil, err
+	}
+	id, err := unix.KeyctlSearch(ringID, keyringKeyType, desc, 0)
+	if err != nil {
+		if keyringMissing(err) {
+			return nil, nil
+		}
+		return nil, keyringError("search", err)
+	}
+
+	buf := make([]byte, sessionRecordSize)
+	n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, id, buf, 0)
+	if err != nil {
+		if keyringMissing(err) {
+			return nil, nil
+		}
+		return nil, keyringError("read", err)
+	}
+	if n > len(buf) {
+		return nil, nil // not one of ours, treat as missing
+	}
+	return buf[:n], nil
+}
+
+// keyringPut stores payload under desc, replacing any previous one. ttl = 0 means no timeout.
+func keyringPut(ring, desc string, payload []byte, ttl time.Duration) error {
+	ringID, err := keyringID(ring)
+	if err != nil {
+		return err
+	}
+	id, err := unix.AddKey(keyringKeyType, desc, payload, ringID)
+	if err != nil {
+		return keyringError("add key to", err)
+	}
+	if err := unix.KeyctlSetperm(id, keyringPerm); err != nil {
+		_, _ = unix.KeyctlInt(unix.KEYCTL_INVALIDATE, id, 0, 0, 0)
+		return keyringError("set permissions in", err)
+	}
+
+	// Always set: an updated key keeps the old timeout otherwise
+	secs := 0
+	if ttl > 0 {
+		secs = max(1, int(ttl/time.Second))
+	}
+	if _, err := unix.KeyctlInt(unix.KEYCTL_SET_TIMEOUT, id, secs, 0, 0); err != nil {
+		_, _ = unix.KeyctlInt(unix.KEYCTL_INVALIDATE, id, 0, 0, 0)
+		return keyringError("set timeout in", err)
+	}
+	return nil
+}
+
+// keyringClear invalidates the key stored under desc; silent if missing.
+func keyringClear(ring, desc string) error {
+	ringID, err := keyringID(ring)
+	if err != nil {
+		return err
+	}
+	id, err := unix.KeyctlSearch(ringID, keyringKeyType, desc, 0)
+	if err != nil {
+		if keyringMissing(err) {
+			return nil
+		}
+		return keyringError("search", err)
+	}
+	if _, err := unix.KeyctlInt(unix.KEYCTL_INVALIDATE, id, 0, 0, 0); err != nil && !keyringMissing(err) {
+		return keyringError("clear key in", err)
+	}
+	return nil
+}
+
+// keyringClearAll invalidates every dredge key linked into the session and user
+// keyrings. Returns how many were cleared.
+func keyringClearAll() (int, error) {
+	cleared := 0
+	for _, ring := range []string{config.KeyringSession, config.KeyringUser} {
+		var ids []int
+		ringID, err := keyringID(ring)
+		if err == nil {
+			ids, err = keyringContents(ringID)
+		}
+		if err != nil {
+			if err == errKeyringUnavailable {
+				return cleared, nil
+			}
+			return cleared, err
+		}
+
+		for _, id := range ids {
+			// "type;uid;gid;perm;description"
+			info, err := unix.KeyctlString(unix.KEYCTL_DESCRIBE, id)
+			if err != nil {
+				continue
+			}
+			fields := strings.SplitN(info, ";", 5)
+			if len(fields) != 5 || fields[0] != keyringKeyType || !strings.HasPrefix(fields[4], keyringPrefix) {
+				continue
+			}
+			if _, err := unix.KeyctlInt(unix.KEYCTL_INVALIDATE, id, 0, 0, 0); err == nil {
+				cleared++
+			}
+		}
+	}
+	return cleared, nil
+}
+
+// keyringContents lists the key IDs linked into a keyring.
+func keyringContents(ringID int) ([]int, error) {
+	size, err := unix.KeyctlBuffer(unix.KEYCTL_READ, ringID, nil, 0)
+	if err != nil {
+		if keyringMissing(err) {
+			return nil, nil
+		}
+		return nil, keyringError("list", err)
+	}
+
+	buf := make([]byte, size)
+	n, err := unix.KeyctlBuffer(unix.KEYCTL_READ, ringID, buf, 0)
+	if err != nil {
+		return nil, keyringError("list", err)
+	}
+
+	ids := make([]int, 0, n/4)
+	for i := 0; i+4 <= min(n, len(buf)); i += 4 {
+		ids = append(ids, int(int32(binary.NativeEndian.Uint32(buf[i:]))))
+	}
+	return ids, nil
+}
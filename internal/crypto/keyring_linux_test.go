@@ -0,0 +1,80 @@
+//go:build linux
+
+package crypto
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/config"
+)
+
+// useKeyring switches the session cache to the kernel keyring for one test,
+// skipping it where keyring syscalls are not allowed.
+func useKeyring(t *testing.T) {
+	t.Helper()
+	if err := keyringClear(config.KeyringSession, "dredge:probe"); err == errKeyringUnavailable {
+		t.Skip("kernel keyring not available")
+	}
+
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	old := SessionConfig
+	SessionConfig = config.Session{Cache: config.CacheKeyring, Keyring: config.KeyringSession}
+	t.Cleanup(func() {
+		_ = ClearSession()
+		SessionConfig = old
+	})
+}
+
+func TestKeyringCache_RoundTrip(t *testing.T) {
+	useKeyring(t)
+	testKey := testSessionKey()
+
+	if err := CacheKey(testKey); err != nil {
+		t.Fatalf("CacheKey failed: %v", err)
+	}
+
+	retrieved, err := GetCachedKey()
+	if err != nil {
+		t.Fatalf("GetCachedKey failed: %v", err)
+	}
+	if !bytes.Equal(retrieved, testKey) {
+		t.Errorf("GetCachedKey = %x, want %x", retrieved, testKey)
+	}
+
+	// The key must not land in the session directory
+	if _, err := os.Stat(filepath.Join(vaultKeyDir(), sessionCacheFile)); !os.IsNotExist(err) {
+		t.Error("keyring backend should not write a session key file")
+	}
+
+	if err := ClearSession(); err != nil {
+		t.Fatalf("ClearSession failed: %v", err)
+	}
+	if retrieved, _ := GetCachedKey(); retrieved != nil {
+		t.Error("GetCachedKey should return nil after ClearSession")
+	}
+}
+
+func TestKeyringCache_KernelTimeout(t *testing.T) {
+	useKeyring(t)
+	desc := keyringDescription()
+
+	if err := keyringPut(config.KeyringSession, desc, testSessionKey(), time.Second); err != nil {
+		t.Fatalf("keyringPut failed: %v", err)
+	}
+	if key, _ := keyringGet(config.KeyringSession, desc); key == nil {
+		t.Fatal("key should be readable before its timeout")
+	}
+
+	time.Sleep(1500 * time.Millisecond)
+	key, err := keyringGet(config.KeyringSession, desc)
+	if err != nil {
+		t.Fatalf("keyringGet after timeout failed: %v", err)
+	}
+	if key != nil {
+		t.Error("kernel should have expired the key")
+	}
+}
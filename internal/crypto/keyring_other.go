@@ -0,0 +1,23 @@
+//go:build !linux
+
+package crypto
+
+import "time"
+
+// The kernel keyring is Linux-only; elsewhere the keyring backend uses the session file.
+
+func keyringGet(ring, desc string) ([]byte, error) {
+	return nil, errKeyringUnavailable
+}
+
+func keyringPut(ring, desc string, payload []byte, ttl time.Duration) error {
+	return errKeyringUnavailable
+}
+
+func keyringClear(ring, desc string) error {
+	return errKeyringUnavailable
+}
+
+func keyringClearAll() (int, error) {
+	return 0, nil
+}
@@ -0,0 +1,244 @@
+package crypto
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"time"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/secmem"
+)
+
+// ============================================================================
+// Key Slots
+// ============================================================================
+//
+// .dredge-key holds one or more key slots (LUKS-style). Every slot wraps the same
+// random vault data key under a different secret, so each person or device can have
+// its own password and any one of them unlocks the vault.
+
+// Slot types
+const (
+	SlotPassword  = "password" // Argon2id over a password
+	SlotRecovery  = "recovery" // HKDF over a random recovery key (see recovery.go)
+	SlotRecipient = "x25519"   // X25519 public key of a teammate (see recipients.go)
+)
+
+// DefaultSlotLabel names the slot created with the vault (and older single-password vaults).
+const DefaultSlotLabel = "default"
+
+var slotLabelPattern = regexp.MustCompile(`^[a-zA-Z0-9._-]{1,32}$`)
+
+var errWrongPassword = errors.New("wrong password")
+
+// KeySlot is one way of unwrapping the vault data key.
+type KeySlot struct {
+	Label      string    `json:"label"`
+	Type       string    `json:"type"`
+	KDF        string    `json:"kdf"`
+	Params     KDFParams `json:"params,omitzero"` // password slots only
+	Salt       []byte    `json:"salt,omitempty"`
+	Keyfile    bool      `json:"keyfile,omitempty"`     // password slots: keyfile digest mixed into the KDF input
+	Recipient  string    `json:"recipient,omitempty"`   // x25519 slots: "dredge1..." public key
+	Ephemeral  []byte    `json:"ephemeral,omitempty"`   // x25519 slots: ephemeral public key
+	WrappedKey []byte    `json:"wrapped_key,omitempty"` // data key encrypted with the slot key
+	Created    time.Time `json:"created,omitzero"`
+
+	// Verify is VerificationContent encrypted with the password key (vaults from before the data key)
+	Verify []byte `json:"-"`
+}
+
+// ValidateSlotLabel checks that a label is short and safe to print.
+func ValidateSlotLabel(label string) error {
+	if !slotLabelPattern.MatchString(label) {
+		return fmt.Errorf("slot label must be 1-32 characters of letters, digits, '.', '_' or '-' (got %q)", label)
+	}
+	return nil
+}
+
+// validate checks a slot parsed from disk.
+func (s *KeySlot) validate() error {
+	if err := ValidateSlotLabel(s.Label); err != nil {
+		return err
+	}
+	switch s.Type {
+	case SlotPassword:
+		if s.KDF != kdfNameArgon2id {
+			return fmt.Errorf("unsupported kdf %q", s.KDF)
+		}
+		if err := s.Params.Validate(); err != nil {
+			return fmt.Errorf("invalid kdf parameters: %w", err)
+		}
+	case SlotRecovery:
+		if s.KDF != kdfNameHKDFSHA256 {
+			return fmt.Errorf("unsupported kdf %q", s.KDF)
+		}
+	case SlotRecipient:
+		if s.KDF != kdfNameX25519 {
+			return fmt.Errorf("unsupported kdf %q", s.KDF)
+		}
+		if _, err := ParseRecipient(s.Recipient); err != nil {
+			return err
+		}
+		if len(s.Ephemeral) != 32 {
+			return fmt.Errorf("corrupted (bad ephemeral key)")
+		}
+	default:
+		return fmt.Errorf("unsupported slot type %q (upgrade dredge)", s.Type)
+	}
+	if s.Keyfile && s.Type != SlotPassword {
+		return fmt.Errorf("only password slots can require a keyfile")
+	}
+	if s.Type != SlotRecipient && len(s.Salt) != SaltSize {
+		return fmt.Errorf("corrupted (bad salt)")
+	}
+	blob := s.WrappedKey
+	if s.Verify != nil {
+		blob = s.Verify
+	}
+	if len(blob) < NonceSize+16 {
+		return fmt.Errorf("corrupted (bad wrapped key)")
+	}
+	return nil
+}
+
+// unlock derives the slot key from password (and keyfile, for keyfile slots) and returns
+// the vault master key. Returns errWrongPassword when the password does not open this slot.
+func (s *KeySlot) unlock(password, keyfile []byte) ([]byte, error) {
+	if !s.Keyfile {
+		keyfile = nil
+	}
+	input := keyfileInput(password, keyfile)
+	key := deriveKey(input.Bytes(), s.Salt, s.Params)
+	input.Destroy()
+
+	// Pre-data-key vault: the derived key is the master key
+	if s.Verify != nil {
+		decrypted, err := Decrypt(s.Verify, key)
+		if err != nil {
+			secmem.Wipe(key)
+			return nil, errWrongPassword
+		}
+		if string(decrypted) != VerificationContent {
+			secmem.Wipe(key)
+			return nil, fmt.Errorf("verification file corrupted (unexpected content)")
+		}
+		return key, nil
+	}
+
+	dataKey, err := DecryptWithAD(s.WrappedKey, key, dataKeyAD)
+	secmem.Wipe(key)
+	if err != nil {
+		return nil, errWrongPassword
+	}
+	if len(dataKey) != KeySize {
+		return nil, fmt.Errorf("verification file corrupted (bad data key length)")
+	}
+	return dataKey, nil
+}
+
+// GenerateDataKey returns a new random 256-bit vault data key.
+func GenerateDataKey() ([]byte, error) {
+	key := make([]byte, KeySize)
+	if _, err := io.ReadFull(rand.Reader, key); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	return key, nil
+}
+
+// NewPasswordSlot wraps dataKey with a key derived from password (fresh salt).
+func NewPasswordSlot(label string, dataKey []byte, password string, params KDFParams) (*KeySlot, error) {
+	return NewPasswordSlotWithKeyfile(label, dataKey, password, nil, params)
+}
+
+// NewPasswordSlotWithKeyfile is NewPasswordSlot that also requires the keyfile with
+// the given digest (see ReadKeyfile). A nil keyfile makes a plain password slot.
+func NewPasswordSlotWithKeyfile(label string, dataKey []byte, password string, keyfile []byte, params KDFParams) (*KeySlot, error) {
+	if password == "" {
+		return nil, fmt.Errorf("password cannot be empty")
+	}
+	if err := ValidateSlotLabel(label); err != nil {
+		return nil, err
+	}
+	if len(dataKey) != KeySize {
+		return nil, fmt.Errorf("data key must be %d bytes, got %d", KeySize, len(dataKey))
+	}
+	if err := params.Validate(); err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, SaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	pw := []byte(password)
+	input := keyfileInput(pw, keyfile)
+	secmem.Wipe(pw)
+	key := deriveKey(input.Bytes(), salt, params)
+	input.Destroy()
+
+	wrapped, err := EncryptWithAD(dataKey, key, dataKeyAD)
+	secmem.Wipe(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	return &KeySlot{
+		Label:      label,
+		Type:       SlotPassword,
+		KDF:        kdfNameArgon2id,
+		Params:     params,
+		Salt:       salt,
+		Keyfile:    keyfile != nil,
+		WrappedKey: wrapped,
+		Created:    time.Now().UTC().Truncate(time.Second),
+	}, nil
+}
+
+// FindSlot returns the index of the slot with label, or -1.
+func (vf *VerifyFile) FindSlot(label string) int {
+	for i := range vf.Slots {
+		if vf.Slots[i].Label == label {
+			return i
+		}
+	}
+	return -1
+}
+
+// AddSlot appends a slot; labels must be unique.
+func (vf *VerifyFile) AddSlot(slot KeySlot) error {
+	if !vf.HasDataKey() {
+		return fmt.Errorf("vault has no data key yet (run 'dredge passwd' to migrate it)")
+	}
+	if vf.FindSlot(slot.Label) >= 0 {
+		return fmt.Errorf("a key slot labelled %q already exists", slot.Label)
+	}
+	vf.Slots = append(vf.Slots, slot)
+	return nil
+}
+
+// RemoveSlot deletes the slot with label. The last slot usable for everyday unlocking
+// (password or recipient) cannot be removed; a recovery key alone is not enough.
+func (vf *VerifyFile) RemoveSlot(label string) error {
+	idx := vf.FindSlot(label)
+	if idx < 0 {
+		return fmt.Errorf("no key slot labelled %q", label)
+	}
+
+	everyday := func(s KeySlot) bool { return s.Type == SlotPassword || s.Type == SlotRecipient }
+	remaining := 0
+	for _, s := range vf.Slots {
+		if everyday(s) {
+			remaining++
+		}
+	}
+	if everyday(vf.Slots[idx]) && remaining == 1 {
+		return fmt.Errorf("cannot remove the last password or recipient slot")
+	}
+
+	vf.Slots = append(vf.Slots[:idx], vf.Slots[idx+1:]...)
+	return nil
+}
@@ -0,0 +1,158 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+)
+
+func TestKeySlots_EachPasswordUnlocksSameKey(t *testing.T) {
+	fileBytes, dataKey, err := NewVerificationFileBytes("laptop-password", fastKDFParams)
+	if err != nil {
+		t.Fatalf("NewVerificationFileBytes failed: %v", err)
+	}
+	vf, err := ParseVerifyFile(fileBytes)
+	if err != nil {
+		t.Fatalf("ParseVerifyFile failed: %v", err)
+	}
+
+	slot, err := NewPasswordSlot("safe", dataKey, "long offline recovery passphrase", fastKDFParams)
+	if err != nil {
+		t.Fatalf("NewPasswordSlot failed: %v", err)
+	}
+	if err := vf.AddSlot(*slot); err != nil {
+		t.Fatalf("AddSlot failed: %v", err)
+	}
+
+	data, err := vf.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes failed: %v", err)
+	}
+	vf, err = ParseVerifyFile(data)
+	if err != nil {
+		t.Fatalf("ParseVerifyFile(two slots) failed: %v", err)
+	}
+
+	for i, password := range []string{"laptop-password", "long offline recovery passphrase"} {
+		key, idx, err := vf.UnlockSlot(password)
+		if err != nil {
+			t.Fatalf("UnlockSlot(%q) failed: %v", password, err)
+		}
+		if idx != i {
+			t.Errorf("UnlockSlot(%q) slot = %d, want %d", password, idx, i)
+		}
+		if !bytes.Equal(key, dataKey) {
+			t.Errorf("slot %d unwrapped a different key", i)
+		}
+	}
+
+	if _, err := vf.Unlock("neither"); err == nil {
+		t.Error("Unlock should fail for a password matching no slot")
+	}
+}
+
+func TestKeySlots_AddDuplicateLabel(t *testing.T) {
+	fileBytes, dataKey, err := NewVerificationFileBytes("hunter2", fastKDFParams)
+	if err != nil {
+		t.Fatalf("NewVerificationFileBytes failed: %v", err)
+	}
+	vf, _ := ParseVerifyFile(fileBytes)
+
+	slot, err := NewPasswordSlot(DefaultSlotLabel, dataKey, "other", fastKDFParams)
+	if err != nil {
+		t.Fatalf("NewPasswordSlot failed: %v", err)
+	}
+	if err := vf.AddSlot(*slot); err == nil {
+		t.Error("AddSlot should reject a duplicate label")
+	}
+}
+
+func TestKeySlots_RemoveLastPasswordSlot(t *testing.T) {
+	fileBytes, dataKey, err := NewVerificationFileBytes("hunter2", fastKDFParams)
+	if err != nil {
+		t.Fatalf("NewVerificationFileBytes failed: %v", err)
+	}
+	vf, _ := ParseVerifyFile(fileBytes)
+
+	if err := vf.RemoveSlot(DefaultSlotLabel); err == nil {
+		t.Fatal("RemoveSlot should refuse to remove the only password slot")
+	}
+
+	slot, _ := NewPasswordSlot("second", dataKey, "other", fastKDFParams)
+	if err := vf.AddSlot(*slot); err != nil {
+		t.Fatalf("AddSlot failed: %v", err)
+	}
+	if err := vf.RemoveSlot(DefaultSlotLabel); err != nil {
+		t.Fatalf("RemoveSlot failed: %v", err)
+	}
+	if _, err := vf.Unlock("hunter2"); err == nil {
+		t.Error("removed slot's password should no longer unlock")
+	}
+	if _, err := vf.Unlock("other"); err != nil {
+		t.Errorf("remaining slot should still unlock: %v", err)
+	}
+}
+
+func TestParseVerifyFile_Version3SingleKey(t *testing.T) {
+	dataKey, _ := GenerateDataKey()
+	slot, err := NewPasswordSlot(DefaultSlotLabel, dataKey, "hunter2", fastKDFParams)
+	if err != nil {
+		t.Fatalf("NewPasswordSlot failed: %v", err)
+	}
+
+	// Version 3 stored a single wrapped key at the top level
+	data, _ := json.Marshal(map[string]any{
+		"version": 3, "kdf": kdfNameArgon2id, "params": slot.Params, "salt": slot.Salt, "wrapped_key": slot.WrappedKey,
+	})
+
+	vf, err := ParseVerifyFile(data)
+	if err != nil {
+		t.Fatalf("ParseVerifyFile(v3) failed: %v", err)
+	}
+	if len(vf.Slots) != 1 || vf.Slots[0].Label != DefaultSlotLabel {
+		t.Fatalf("v3 file should become one %q slot, got %+v", DefaultSlotLabel, vf.Slots)
+	}
+	key, err := vf.Unlock("hunter2")
+	if err != nil {
+		t.Fatalf("Unlock(v3) failed: %v", err)
+	}
+	if !bytes.Equal(key, dataKey) {
+		t.Error("Unlock(v3) returned a different data key")
+	}
+}
+
+func TestValidateSlotLabel(t *testing.T) {
+	for _, label := range []string{"laptop", "safe-2024", "work.mac"} {
+		if err := ValidateSlotLabel(label); err != nil {
+			t.Errorf("ValidateSlotLabel(%q) unexpected error: %v", label, err)
+		}
+	}
+	for _, label := range []string{"", "has space", "new\nline", "waytoolonglabelthatgoesonandonandon"} {
+		if err := ValidateSlotLabel(label); err == nil {
+			t.Errorf("ValidateSlotLabel(%q) should fail", label)
+		}
+	}
+}
+
+func TestUnlockSlotAt_OnlyTriesThatSlot(t *testing.T) {
+	fileBytes, dataKey, err := NewVerificationFileBytes("first", fastKDFParams)
+	if err != nil {
+		t.Fatalf("NewVerificationFileBytes failed: %v", err)
+	}
+	vf, _ := ParseVerifyFile(fileBytes)
+	slot, _ := NewPasswordSlot("second", dataKey, "second", fastKDFParams)
+	if err := vf.AddSlot(*slot); err != nil {
+		t.Fatalf("AddSlot failed: %v", err)
+	}
+
+	key, err := vf.UnlockSlotAt(1, "second", nil)
+	if err != nil || !bytes.Equal(key, dataKey) {
+		t.Errorf("UnlockSlotAt(1, second) = %v", err)
+	}
+	if _, err := vf.UnlockSlotAt(1, "first", nil); err == nil {
+		t.Error("UnlockSlotAt(1) accepted the password of slot 0")
+	}
+	if _, err := vf.UnlockSlotAt(2, "second", nil); err == nil {
+		t.Error("UnlockSlotAt accepted an index out of range")
+	}
+}
@@ -0,0 +1,33 @@
+package crypto
+
+import (
+	"crypto/hkdf"
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// ============================================================================
+// Manifest MAC
+// ============================================================================
+//
+// The vault manifest is not secret, only authenticated: HMAC-SHA256 under a subkey
+// derived from the vault key with HKDF, so the MAC key is never used for encryption.
+
+const manifestMACInfo = "dredge vault manifest mac v1"
+
+// ManifestMAC returns the MAC of data under the vault key.
+func ManifestMAC(key, data []byte) ([]byte, error) {
+	macKey, err := hkdf.Key(sha256.New, key, nil, manifestMACInfo, KeySize)
+	if err != nil {
+		return nil, err
+	}
+	h := hmac.New(sha256.New, macKey)
+	h.Write(data)
+	return h.Sum(nil), nil
+}
+
+// VerifyManifestMAC reports whether mac is the MAC of data under the vault key.
+func VerifyManifestMAC(key, data, mac []byte) bool {
+	want, err := ManifestMAC(key, data)
+	return err == nil && hmac.Equal(mac, want)
+}
@@ -0,0 +1,101 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"strings"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/wordlist"
+)
+
+// ============================================================================
+// Mnemonic Encoding (BIP-39)
+// ============================================================================
+//
+// Entropy is written as words from the BIP-39 English list: the entropy bits are
+// followed by the first len/32 bits of its SHA-256, and every 11 bits pick a word.
+// 256 bits of entropy become 24 words; the checksum catches most typos.
+
+// EncodeMnemonic returns the BIP-39 mnemonic for entropy (16-32 bytes, multiple of 4).
+func EncodeMnemonic(entropy []byte) (string, error) {
+	if len(entropy) < 16 || len(entropy) > 32 || len(entropy)%4 != 0 {
+		return "", fmt.Errorf("mnemonic entropy must be 16-32 bytes in steps of 4, got %d", len(entropy))
+	}
+
+	checksumBits := len(entropy) * 8 / 32
+	sum := sha256.Sum256(entropy)
+	bits := append(append([]byte{}, entropy...), sum[0])
+
+	wordCount := (len(entropy)*8 + checksumBits) / 11
+	words := make([]string, wordCount)
+	for i := range words {
+		words[i] = wordlist.English[readBits(bits, i*11, 11)]
+	}
+	return strings.Join(words, " "), nil
+}
+
+// DecodeMnemonic parses a BIP-39 mnemonic back to its entropy and verifies the checksum.
+// Words are case-insensitive and may be shortened to their first four letters.
+func DecodeMnemonic(phrase string) ([]byte, error) {
+	words := strings.Fields(phrase)
+	if len(words) < 12 || len(words) > 24 || len(words)%3 != 0 {
+		return nil, fmt.Errorf("mnemonic must be 12-24 words in steps of 3, got %d", len(words))
+	}
+
+	totalBits := len(words) * 11
+	checksumBits := totalBits / 33
+	entropyBytes := (totalBits - checksumBits) / 8
+
+	bits := make([]byte, (totalBits+7)/8)
+	for i, w := range words {
+		idx := lookupWord(w)
+		if idx < 0 {
+			return nil, fmt.Errorf("word %d (%q) is not in the recovery word list", i+1, w)
+		}
+		writeBits(bits, i*11, 11, idx)
+	}
+
+	entropy := bits[:entropyBytes]
+	sum := sha256.Sum256(entropy)
+	if readBits(bits, entropyBytes*8, checksumBits) != readBits(sum[:], 0, checksumBits) {
+		return nil, fmt.Errorf("mnemonic checksum mismatch (check for a mistyped or swapped word)")
+	}
+
+	return append([]byte{}, entropy...), nil
+}
+
+// lookupWord resolves a full word or a unique four-letter prefix to its list index.
+func lookupWord(word string) int {
+	word = strings.ToLower(word)
+	if idx := wordlist.Index(word); idx >= 0 {
+		return idx
+	}
+	if len(word) == 4 {
+		// BIP-39 words are unique in their first four letters
+		for i, w := range wordlist.English {
+			if strings.HasPrefix(w, word) {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// readBits returns n bits (n <= 16) of data starting at bit offset off, MSB first.
+func readBits(data []byte, off, n int) int {
+	v := 0
+	for i := 0; i < n; i++ {
+		bit := (data[(off+i)/8] >> (7 - uint((off+i)%8))) & 1
+		v = v<<1 | int(bit)
+	}
+	return v
+}
+
+// writeBits stores the low n bits of v into data starting at bit offset off, MSB first.
+func writeBits(data []byte, off, n, v int) {
+	for i := 0; i < n; i++ {
+		if (v>>(n-1-i))&1 == 1 {
+			data[(off+i)/8] |= 1 << (7 - uint((off+i)%8))
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package crypto
+
+import (
+	"errors"
+	"io"
+	"math/bits"
+)
+
+// ============================================================================
+// Padding
+// ============================================================================
+//
+// Ciphertext length gives away plaintext length, so a padded payload is first rounded
+// up to a size bucket: plaintext || 0x80 || 0x00... (ISO/IEC 7816-4). Buckets follow
+// Padmé (from the PURB paper): at least MinPaddedSize, and above that the size keeps only
+// its top few bits, so padding costs at most ~12% and a size leaks O(log log n) bits.
+// FlagPadded in the (authenticated) header tells the reader to strip it.
+
+// MinPaddedSize is the smallest bucket: every item up to this size looks the same.
+const MinPaddedSize = 512
+
+const padMarker = 0x80
+
+var errBadPadding = errors.New("invalid padding (tampered data?)")
+
+// IsPadded reports whether the plaintext was padded to a size bucket before encryption.
+func (h Header) IsPadded() bool {
+	return h.Flags&FlagPadded != 0
+}
+
+// PaddedSize returns the bucket a padded plaintext of n bytes (marker included) fills.
+func PaddedSize(n int64) int64 {
+	if n <= MinPaddedSize {
+		return MinPaddedSize
+	}
+	e := bits.Len64(uint64(n)) - 1 // floor(log2 n)
+	s := bits.Len64(uint64(e))     // floor(log2 e) + 1
+	mask := int64(1)<<(e-s) - 1
+	return (n + mask) &^ mask
+}
+
+// pad appends the marker and zeros up to the bucket for plaintext.
+func pad(plaintext []byte) []byte {
+	size := PaddedSize(int64(len(plaintext)) + 1)
+	padded := make([]byte, size)
+	copy(padded, plaintext)
+	padded[len(plaintext)] = padMarker
+	return padded
+}
+
+// unpad strips the zeros and the marker added by pad.
+func unpad(padded []byte) ([]byte, error) {
+	i := len(padded) - 1
+	for i >= 0 && padded[i] == 0 {
+		i--
+	}
+	if i < 0 || padded[i] != padMarker {
+		return nil, errBadPadding
+	}
+	return padded[:i], nil
+}
+
+// writePadding writes the marker and zeros that take total plaintext bytes to their bucket.
+func writePadding(w io.Writer, total int64) error {
+	if _, err := w.Write([]byte{padMarker}); err != nil {
+		return err
+	}
+	zeros := make([]byte, StreamChunkSize)
+	for remaining := PaddedSize(total+1) - total - 1; remaining > 0; {
+		n := min(remaining, int64(len(zeros)))
+		if _, err := w.Write(zeros[:n]); err != nil {
+			return err
+		}
+		remaining -= n
+	}
+	return nil
+}
+
+// unpadReader strips padding from the end of a stream without buffering it: the
+// trailing run of a marker and zeros is only counted, and released as plaintext if
+// more data turns up after it.
+type unpadReader struct {
+	src io.Reader
+	buf []byte
+
+	// Held back: maybe the start of the padding
+	heldMarker bool
+	heldZeros  int64
+
+	// Ready to return, in this order
+	outMarker bool
+	outZeros  int64
+	out       []byte
+
+	err error
+}
+
+func newUnpadReader(src io.Reader) *unpadReader {
+	return &unpadReader{src: src, buf: make([]byte, StreamChunkSize)}
+}
+
+func (r *unpadReader) Read(p []byte) (int, error) {
+	for !r.outMarker && r.outZeros == 0 && len(r.out) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		r.fill()
+	}
+
+	switch {
+	case r.outMarker:
+		if len(p) == 0 {
+			return 0, nil
+		}
+		p[0] = padMarker
+		r.outMarker = false
+		return 1, nil
+	case r.outZeros > 0:
+		n := int(min(int64(len(p)), r.outZeros))
+		clear(p[:n])
+		r.outZeros -= int64(n)
+		return n, nil
+	default:
+		n := copy(p, r.out)
+		r.out = r.out[n:]
+		return n, nil
+	}
+}
+
+// fill reads the next block from src. Everything up to its last non-zero byte is
+// plaintext (releasing what was held before it); from there on it is held back.
+func (r *unpadReader) fill() {
+	n, err := r.src.Read(r.buf)
+	data := r.buf[:n]
+
+	last := len(data) - 1
+	for last >= 0 && data[last] == 0 {
+		last--
+	}
+
+	if last < 0 {
+		r.heldZeros += int64(n)
+	} else {
+		r.outMarker, r.outZeros = r.heldMarker, r.heldZeros
+		if data[last] == padMarker {
+			r.out = data[:last]
+			r.heldMarker = true
+		} else {
+			r.out = data[:last+1]
+			r.heldMarker = false
+		}
+		r.heldZeros = int64(len(data) - last - 1)
+	}
+
+	switch {
+	case err == io.EOF:
+		// What is still held must be exactly the padding
+		if !r.heldMarker {
+			r.err = errBadPadding
+		} else {
+			r.err = io.EOF
+		}
+	case err != nil:
+		r.err = err
+	}
+}
@@ -0,0 +1,95 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"testing"
+)
+
+func TestPaddedSize(t *testing.T) {
+	tests := []struct {
+		n, want int64
+	}{
+		{0, MinPaddedSize},
+		{1, MinPaddedSize},
+		{MinPaddedSize, MinPaddedSize},
+		{MinPaddedSize + 1, 544}, // e=9, s=4: multiples of 32
+		{3000, 3072},             // e=11, s=4: multiples of 128
+		{1 << 20, 1 << 20},
+		{1<<20 + 1, 1<<20 + 1<<15}, // e=20, s=5: multiples of 32 KiB
+	}
+	for _, tt := range tests {
+		if got := PaddedSize(tt.n); got != tt.want {
+			t.Errorf("PaddedSize(%d) = %d, want %d", tt.n, got, tt.want)
+		}
+		// Padmé never costs more than ~12%
+		if got := PaddedSize(tt.n); tt.n > MinPaddedSize && float64(got-tt.n)/float64(tt.n) > 0.12 {
+			t.Errorf("PaddedSize(%d) = %d overhead above 12%%", tt.n, got)
+		}
+	}
+}
+
+func TestEncryptPadded_HidesLength(t *testing.T) {
+	key := make([]byte, KeySize)
+	ad := AssociatedData(KindItem, "abc")
+
+	short, _ := EncryptPaddedWithAD([]byte("sk-40-byte-api-key-0123456789abcdefghij"), key, ad)
+	long, _ := EncryptPaddedWithAD(bytes.Repeat([]byte("x"), 400), key, ad)
+	if len(short) != len(long) {
+		t.Errorf("ciphertexts of 40 and 400 bytes differ in length: %d vs %d", len(short), len(long))
+	}
+
+	// Plaintexts that themselves end like padding still round trip
+	for _, plaintext := range [][]byte{nil, {0x80}, {0x80, 0, 0}, {1, 0}, bytes.Repeat([]byte{0}, 600)} {
+		encrypted, err := EncryptPaddedWithAD(plaintext, key, ad)
+		if err != nil {
+			t.Fatalf("EncryptPaddedWithAD failed: %v", err)
+		}
+		if header, _ := ParseHeader(encrypted); !header.IsPadded() {
+			t.Errorf("header %+v lacks the padded flag", header)
+		}
+		decrypted, err := DecryptWithAD(encrypted, key, ad)
+		if err != nil || !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("round trip of %x = %x, %v", plaintext, decrypted, err)
+		}
+	}
+}
+
+func TestStreamPadded_RoundTrip(t *testing.T) {
+	key := make([]byte, KeySize)
+	ad := AssociatedData(KindBlob, "abc")
+
+	// Sizes whose padding ends mid-chunk, fills chunks, or spans several of them
+	sizes := []int{0, 1, 600, StreamChunkSize, 3*StreamChunkSize + 17, 9 * StreamChunkSize}
+	for _, size := range sizes {
+		plaintext := make([]byte, size)
+		_, _ = rand.Read(plaintext)
+		// A run of zeros before the end must not be mistaken for padding
+		if size > 100 {
+			clear(plaintext[size-100 : size-1])
+		}
+
+		var buf bytes.Buffer
+		n, err := EncryptStreamPadded(&buf, bytes.NewReader(plaintext), key, ad)
+		if err != nil || n != int64(size) {
+			t.Fatalf("size %d: EncryptStreamPadded = %d, %v", size, n, err)
+		}
+
+		decrypted, err := decryptStreamBytes(buf.Bytes(), key, ad)
+		if err != nil {
+			t.Fatalf("size %d: decrypt failed: %v", size, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("size %d: round trip mismatch (got %d bytes)", size, len(decrypted))
+		}
+	}
+}
+
+func TestUnpad_RejectsMissingMarker(t *testing.T) {
+	if _, err := unpad([]byte{1, 2, 0, 0}); err == nil {
+		t.Error("unpad accepted padding without a marker")
+	}
+	if _, err := unpad(make([]byte, 8)); err == nil {
+		t.Error("unpad accepted all zeros")
+	}
+}
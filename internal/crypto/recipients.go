@@ -0,0 +1,291 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// ============================================================================
+// X25519 Recipients
+// ============================================================================
+//
+// A recipient slot wraps the vault data key for a teammate's X25519 public key,
+// the way age does: an ephemeral key pair is generated per slot, the shared secret
+// goes through HKDF-SHA256, and the result wraps the data key. Only the holder of
+// the matching identity (private key) can unwrap it, so no password is shared.
+
+const (
+	// RecipientPrefix starts every encoded public key ("dredge1...").
+	RecipientPrefix = "dredge1"
+
+	// IdentityPrefix starts the secret key line of an identity file.
+	IdentityPrefix = "DREDGE-SECRET-KEY-1"
+
+	// IdentityEnvVar overrides the identity file path.
+	IdentityEnvVar = "DREDGE_IDENTITY"
+
+	kdfNameX25519    = "x25519-hkdf-sha256"
+	recipientHKDFInf = "dredge x25519 key slot v1"
+	keyChecksumSize  = 4
+)
+
+// IdentityPath is the identity file used to unlock recipient slots (set from main).
+// Empty means DREDGE_IDENTITY, then the default path if that file exists.
+var IdentityPath string
+
+var keyEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// encodeKey appends a short SHA-256 checksum to key and base32-encodes it, so typos
+// in a pasted key are caught instead of silently wrapping for the wrong recipient.
+func encodeKey(key []byte) string {
+	sum := sha256.Sum256(key)
+	return keyEncoding.EncodeToString(append(append([]byte{}, key...), sum[:keyChecksumSize]...))
+}
+
+// decodeKey reverses encodeKey and verifies the checksum.
+func decodeKey(s string) ([]byte, error) {
+	raw, err := keyEncoding.DecodeString(strings.ToUpper(s))
+	if err != nil || len(raw) != 32+keyChecksumSize {
+		return nil, fmt.Errorf("malformed key encoding")
+	}
+	key, check := raw[:32], raw[32:]
+	sum := sha256.Sum256(key)
+	if !bytes.Equal(check, sum[:keyChecksumSize]) {
+		return nil, fmt.Errorf("key checksum mismatch (typo?)")
+	}
+	return key, nil
+}
+
+// EncodeRecipient formats an X25519 public key as "dredge1..." (lowercase).
+func EncodeRecipient(pub *ecdh.PublicKey) string {
+	return RecipientPrefix + strings.ToLower(encodeKey(pub.Bytes()))
+}
+
+// ParseRecipient parses a "dredge1..." public key.
+func ParseRecipient(s string) (*ecdh.PublicKey, error) {
+	s = strings.TrimSpace(s)
+	if !strings.HasPrefix(strings.ToLower(s), RecipientPrefix) {
+		return nil, fmt.Errorf("recipient must start with %q", RecipientPrefix)
+	}
+	raw, err := decodeKey(s[len(RecipientPrefix):])
+	if err != nil {
+		return nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+	return ecdh.X25519().NewPublicKey(raw)
+}
+
+// GenerateIdentity creates a new X25519 identity.
+func GenerateIdentity() (*ecdh.PrivateKey, error) {
+	priv, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate identity: %w", err)
+	}
+	return priv, nil
+}
+
+// IdentityFileBytes renders an identity file: comment lines plus the secret key line.
+func IdentityFileBytes(priv *ecdh.PrivateKey) []byte {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# created: %s\n", time.Now().Format(time.RFC3339))
+	fmt.Fprintf(&b, "# public key: %s\n", EncodeRecipient(priv.PublicKey()))
+	fmt.Fprintf(&b, "%s%s\n", IdentityPrefix, encodeKey(priv.Bytes()))
+	return []byte(b.String())
+}
+
+// ParseIdentityFile reads the secret key line of an identity file ('#' lines are comments).
+func ParseIdentityFile(data []byte) (*ecdh.PrivateKey, error) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		if !strings.HasPrefix(line, IdentityPrefix) {
+			return nil, fmt.Errorf("unexpected line in identity file")
+		}
+		raw, err := decodeKey(line[len(IdentityPrefix):])
+		if err != nil {
+			return nil, fmt.Errorf("invalid identity: %w", err)
+		}
+		return ecdh.X25519().NewPrivateKey(raw)
+	}
+	return nil, fmt.Errorf("no secret key found in identity file")
+}
+
+// DefaultIdentityPath returns ~/.config/dredge/identity (XDG config dir).
+func DefaultIdentityPath() (string, error) {
+	configDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get config directory: %w", err)
+	}
+	return filepath.Join(configDir, "dredge", "identity"), nil
+}
+
+// LoadIdentity resolves the identity to unlock with: IdentityPath, then DREDGE_IDENTITY,
+// then the default path. Returns (nil, nil) when none is configured and the default is absent.
+func LoadIdentity() (*ecdh.PrivateKey, error) {
+	path, explicit := IdentityPath, true
+	if path == "" {
+		path = os.Getenv(IdentityEnvVar)
+	}
+	if path == "" {
+		explicit = false
+		var err error
+		if path, err = DefaultIdentityPath(); err != nil {
+			return nil, nil
+		}
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) && !explicit {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read identity file: %w", err)
+	}
+
+	priv, err := ParseIdentityFile(data)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return priv, nil
+}
+
+// deriveRecipientKey computes the slot wrapping key from an X25519 shared secret.
+// The salt binds both public keys, so a slot cannot be replayed for another recipient.
+func deriveRecipientKey(shared, ephemeral, recipient []byte) ([]byte, error) {
+	salt := append(append([]byte{}, ephemeral...), recipient...)
+	return hkdf.Key(sha256.New, shared, salt, recipientHKDFInf, KeySize)
+}
+
+// NewRecipientSlot wraps dataKey for an X25519 public key.
+func NewRecipientSlot(label string, dataKey []byte, recipient *ecdh.PublicKey) (*KeySlot, error) {
+	if err := ValidateSlotLabel(label); err != nil {
+		return nil, err
+	}
+	if len(dataKey) != KeySize {
+		return nil, fmt.Errorf("data key must be %d bytes, got %d", KeySize, len(dataKey))
+	}
+
+	eph, err := ecdh.X25519().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate ephemeral key: %w", err)
+	}
+	shared, err := eph.ECDH(recipient)
+	if err != nil {
+		return nil, fmt.Errorf("key agreement failed: %w", err)
+	}
+
+	key, err := deriveRecipientKey(shared, eph.PublicKey().Bytes(), recipient.Bytes())
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := EncryptWithAD(dataKey, key, dataKeyAD)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	return &KeySlot{
+		Label:      label,
+		Type:       SlotRecipient,
+		KDF:        kdfNameX25519,
+		Recipient:  EncodeRecipient(recipient),
+		Ephemeral:  eph.PublicKey().Bytes(),
+		WrappedKey: wrapped,
+		Created:    time.Now().UTC().Truncate(time.Second),
+	}, nil
+}
+
+// FindRecipient returns the index of the recipient slot for pub, or -1.
+func (vf *VerifyFile) FindRecipient(pub *ecdh.PublicKey) int {
+	encoded := EncodeRecipient(pub)
+	for i := range vf.Slots {
+		if vf.Slots[i].Type == SlotRecipient && vf.Slots[i].Recipient == encoded {
+			return i
+		}
+	}
+	return -1
+}
+
+// DefaultRecipientLabel labels a recipient slot added without --label: the prefix and
+// first 8 key characters, e.g. "dredge1abcdefgh".
+func DefaultRecipientLabel(pub *ecdh.PublicKey) string {
+	return EncodeRecipient(pub)[:len(RecipientPrefix)+8]
+}
+
+// FindRecipientSlot returns the index of the recipient slot named by arg: a slot label,
+// or else a "dredge1..." public key. Labels are tried first, since default labels look
+// like the start of a key.
+func (vf *VerifyFile) FindRecipientSlot(arg string) (int, error) {
+	idx := vf.FindSlot(arg)
+	if idx < 0 {
+		if !strings.HasPrefix(strings.ToLower(arg), RecipientPrefix) {
+			return -1, fmt.Errorf("no key slot labelled %q", arg)
+		}
+		recipient, err := ParseRecipient(arg)
+		if err != nil {
+			return -1, err
+		}
+		if idx = vf.FindRecipient(recipient); idx < 0 {
+			return -1, fmt.Errorf("recipient is not on this vault")
+		}
+	}
+	if vf.Slots[idx].Type != SlotRecipient {
+		return -1, fmt.Errorf("key slot '%s' is not a recipient (use 'dredge key remove')", arg)
+	}
+	return idx, nil
+}
+
+// UnlockIdentity unwraps the data key from the recipient slot matching priv.
+// Returns the vault master key and the index of the slot it opened.
+func (vf *VerifyFile) UnlockIdentity(priv *ecdh.PrivateKey) ([]byte, int, error) {
+	idx := vf.FindRecipient(priv.PublicKey())
+	if idx < 0 {
+		return nil, -1, fmt.Errorf("identity %s is not a recipient of this vault", EncodeRecipient(priv.PublicKey()))
+	}
+	slot := &vf.Slots[idx]
+
+	eph, err := ecdh.X25519().NewPublicKey(slot.Ephemeral)
+	if err != nil {
+		return nil, -1, fmt.Errorf("key slot %q corrupted (bad ephemeral key)", slot.Label)
+	}
+	shared, err := priv.ECDH(eph)
+	if err != nil {
+		return nil, -1, fmt.Errorf("key agreement failed: %w", err)
+	}
+
+	key, err := deriveRecipientKey(shared, slot.Ephemeral, priv.PublicKey().Bytes())
+	if err != nil {
+		return nil, -1, err
+	}
+
+	dataKey, err := DecryptWithAD(slot.WrappedKey, key, dataKeyAD)
+	if err != nil {
+		return nil, -1, fmt.Errorf("key slot %q could not be unwrapped with this identity", slot.Label)
+	}
+	if len(dataKey) != KeySize {
+		return nil, -1, fmt.Errorf("verification file corrupted (bad data key length)")
+	}
+	return dataKey, idx, nil
+}
+
+// HasRecipients reports whether any slot can be unlocked with an identity.
+func (vf *VerifyFile) HasRecipients() bool {
+	for i := range vf.Slots {
+		if vf.Slots[i].Type == SlotRecipient {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,172 @@
+package crypto
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRecipientEncoding_RoundTrip(t *testing.T) {
+	priv, err := GenerateIdentity()
+	if err != nil {
+		t.Fatalf("GenerateIdentity failed: %v", err)
+	}
+
+	encoded := EncodeRecipient(priv.PublicKey())
+	if !strings.HasPrefix(encoded, RecipientPrefix) {
+		t.Fatalf("recipient %q lacks prefix %q", encoded, RecipientPrefix)
+	}
+
+	pub, err := ParseRecipient(encoded)
+	if err != nil {
+		t.Fatalf("ParseRecipient failed: %v", err)
+	}
+	if !pub.Equal(priv.PublicKey()) {
+		t.Error("ParseRecipient returned a different key")
+	}
+
+	// Single-character typo must be caught by the checksum
+	typo := []byte(encoded)
+	last := len(typo) - 10
+	if typo[last] == 'a' {
+		typo[last] = 'b'
+	} else {
+		typo[last] = 'a'
+	}
+	if _, err := ParseRecipient(string(typo)); err == nil {
+		t.Error("ParseRecipient should reject a mistyped key")
+	}
+}
+
+func TestIdentityFile_RoundTrip(t *testing.T) {
+	priv, _ := GenerateIdentity()
+
+	parsed, err := ParseIdentityFile(IdentityFileBytes(priv))
+	if err != nil {
+		t.Fatalf("ParseIdentityFile failed: %v", err)
+	}
+	if !bytes.Equal(parsed.Bytes(), priv.Bytes()) {
+		t.Error("identity file round trip changed the key")
+	}
+
+	if _, err := ParseIdentityFile([]byte("# only comments\n")); err == nil {
+		t.Error("ParseIdentityFile should fail without a secret key line")
+	}
+}
+
+func TestRecipientSlot_UnlockWithIdentity(t *testing.T) {
+	fileBytes, dataKey, err := NewVerificationFileBytes("shared-password", fastKDFParams)
+	if err != nil {
+		t.Fatalf("NewVerificationFileBytes failed: %v", err)
+	}
+	vf, _ := ParseVerifyFile(fileBytes)
+
+	alice, _ := GenerateIdentity()
+	bob, _ := GenerateIdentity()
+
+	slot, err := NewRecipientSlot("alice", dataKey, alice.PublicKey())
+	if err != nil {
+		t.Fatalf("NewRecipientSlot failed: %v", err)
+	}
+	if err := vf.AddSlot(*slot); err != nil {
+		t.Fatalf("AddSlot failed: %v", err)
+	}
+
+	data, err := vf.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes failed: %v", err)
+	}
+	vf, err = ParseVerifyFile(data)
+	if err != nil {
+		t.Fatalf("ParseVerifyFile failed: %v", err)
+	}
+
+	key, idx, err := vf.UnlockIdentity(alice)
+	if err != nil {
+		t.Fatalf("UnlockIdentity(alice) failed: %v", err)
+	}
+	if idx != 1 || !bytes.Equal(key, dataKey) {
+		t.Errorf("UnlockIdentity(alice) = slot %d; want slot 1 and the data key", idx)
+	}
+
+	if _, _, err := vf.UnlockIdentity(bob); err == nil {
+		t.Error("UnlockIdentity should fail for a non-recipient")
+	}
+
+	// Slot rewritten to claim bob's key still cannot be opened by bob
+	vf.Slots[1].Recipient = EncodeRecipient(bob.PublicKey())
+	if _, _, err := vf.UnlockIdentity(bob); err == nil {
+		t.Error("UnlockIdentity should fail when the slot was wrapped for someone else")
+	}
+}
+
+func TestLoadIdentity(t *testing.T) {
+	tmpDir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", tmpDir)
+	t.Setenv(IdentityEnvVar, "")
+	defer func() { IdentityPath = "" }()
+
+	// Nothing configured and no default file: not an error
+	priv, err := LoadIdentity()
+	if err != nil || priv != nil {
+		t.Fatalf("LoadIdentity() = %v, %v; want nil, nil", priv, err)
+	}
+
+	// Explicit path that does not exist is an error
+	IdentityPath = filepath.Join(tmpDir, "missing")
+	if _, err := LoadIdentity(); err == nil {
+		t.Error("LoadIdentity should fail for a missing explicit identity")
+	}
+
+	want, _ := GenerateIdentity()
+	IdentityPath = filepath.Join(tmpDir, "id")
+	if err := os.WriteFile(IdentityPath, IdentityFileBytes(want), 0600); err != nil {
+		t.Fatal(err)
+	}
+	got, err := LoadIdentity()
+	if err != nil {
+		t.Fatalf("LoadIdentity failed: %v", err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Error("LoadIdentity returned a different key")
+	}
+}
+
+func TestFindRecipientSlot(t *testing.T) {
+	fileBytes, dataKey, err := NewVerificationFileBytes("shared-password", fastKDFParams)
+	if err != nil {
+		t.Fatalf("NewVerificationFileBytes failed: %v", err)
+	}
+	vf, _ := ParseVerifyFile(fileBytes)
+
+	alice, _ := GenerateIdentity()
+	label := DefaultRecipientLabel(alice.PublicKey())
+	slot, err := NewRecipientSlot(label, dataKey, alice.PublicKey())
+	if err != nil {
+		t.Fatalf("NewRecipientSlot failed: %v", err)
+	}
+	if err := vf.AddSlot(*slot); err != nil {
+		t.Fatalf("AddSlot failed: %v", err)
+	}
+
+	// The default label starts like a key but is found as a label
+	for _, arg := range []string{label, EncodeRecipient(alice.PublicKey())} {
+		idx, err := vf.FindRecipientSlot(arg)
+		if err != nil || idx != 1 {
+			t.Errorf("FindRecipientSlot(%q) = %d, %v; want slot 1", arg, idx, err)
+		}
+	}
+	if err := vf.RemoveSlot(label); err != nil {
+		t.Fatalf("RemoveSlot(%q) failed: %v", label, err)
+	}
+	if _, err := vf.FindRecipientSlot(label); err == nil {
+		t.Error("FindRecipientSlot should fail once the recipient is removed")
+	}
+
+	// Password slots are not removed through recipients
+	if _, err := vf.FindRecipientSlot(vf.Slots[0].Label); err == nil {
+		t.Error("FindRecipientSlot should refuse a password slot")
+	}
+}
@@ -0,0 +1,136 @@
+package crypto
+
+import (
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"time"
+)
+
+// ============================================================================
+// Recovery Key
+// ============================================================================
+//
+// A recovery key is 256 random bits shown once as a 24-word mnemonic. It is stored
+// as a key slot like any password, but since it is already high-entropy the slot key
+// comes from HKDF-SHA256 instead of Argon2id, so unlocking with it is instant.
+
+const (
+	// RecoveryKeySize is the recovery key entropy in bytes (24 mnemonic words).
+	RecoveryKeySize = 32
+
+	// DefaultRecoveryLabel names the slot created by 'dredge recovery create'.
+	DefaultRecoveryLabel = "recovery"
+
+	kdfNameHKDFSHA256 = "hkdf-sha256"
+	recoveryHKDFInfo  = "dredge recovery key slot v1"
+)
+
+// GenerateRecoveryKey returns new random recovery key entropy.
+func GenerateRecoveryKey() ([]byte, error) {
+	entropy := make([]byte, RecoveryKeySize)
+	if _, err := io.ReadFull(rand.Reader, entropy); err != nil {
+		return nil, fmt.Errorf("failed to generate recovery key: %w", err)
+	}
+	return entropy, nil
+}
+
+// deriveRecoveryKey turns recovery key entropy and the slot salt into the slot wrapping key.
+func deriveRecoveryKey(entropy, salt []byte) ([]byte, error) {
+	if len(entropy) != RecoveryKeySize {
+		return nil, fmt.Errorf("recovery key must be %d bytes, got %d", RecoveryKeySize, len(entropy))
+	}
+	return hkdf.Key(sha256.New, entropy, salt, recoveryHKDFInfo, KeySize)
+}
+
+// NewRecoverySlot wraps dataKey with a key derived from recovery key entropy.
+func NewRecoverySlot(label string, dataKey []byte, entropy []byte) (*KeySlot, error) {
+	if err := ValidateSlotLabel(label); err != nil {
+		return nil, err
+	}
+	if len(dataKey) != KeySize {
+		return nil, fmt.Errorf("data key must be %d bytes, got %d", KeySize, len(dataKey))
+	}
+
+	salt := make([]byte, SaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+
+	key, err := deriveRecoveryKey(entropy, salt)
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := EncryptWithAD(dataKey, key, dataKeyAD)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	return &KeySlot{
+		Label:      label,
+		Type:       SlotRecovery,
+		KDF:        kdfNameHKDFSHA256,
+		Salt:       salt,
+		WrappedKey: wrapped,
+		Created:    time.Now().UTC().Truncate(time.Second),
+	}, nil
+}
+
+// UnlockRecovery tries recovery key entropy against every recovery slot and returns
+// the vault master key and the index of the slot it opened.
+func (vf *VerifyFile) UnlockRecovery(entropy []byte) ([]byte, int, error) {
+	found := false
+	for i := range vf.Slots {
+		slot := &vf.Slots[i]
+		if slot.Type != SlotRecovery {
+			continue
+		}
+		found = true
+
+		dataKey, err := slot.unlockRecovery(entropy)
+		if err == errWrongPassword {
+			continue
+		}
+		if err != nil {
+			return nil, -1, err
+		}
+		return dataKey, i, nil
+	}
+
+	if !found {
+		return nil, -1, fmt.Errorf("vault has no recovery key (create one with 'dredge recovery create')")
+	}
+	return nil, -1, fmt.Errorf("recovery key does not match this vault")
+}
+
+// UnlockRecoveryAt tries recovery key entropy against the recovery slot at idx only.
+func (vf *VerifyFile) UnlockRecoveryAt(idx int, entropy []byte) ([]byte, error) {
+	if idx < 0 || idx >= len(vf.Slots) || vf.Slots[idx].Type != SlotRecovery {
+		return nil, fmt.Errorf("no recovery slot at index %d", idx)
+	}
+	key, err := vf.Slots[idx].unlockRecovery(entropy)
+	if err == errWrongPassword {
+		return nil, fmt.Errorf("recovery key does not match key slot %q", vf.Slots[idx].Label)
+	}
+	return key, err
+}
+
+// unlockRecovery unwraps the data key of a recovery slot (errWrongPassword if entropy
+// is not its recovery key).
+func (s *KeySlot) unlockRecovery(entropy []byte) ([]byte, error) {
+	key, err := deriveRecoveryKey(entropy, s.Salt)
+	if err != nil {
+		return nil, err
+	}
+	dataKey, err := DecryptWithAD(s.WrappedKey, key, dataKeyAD)
+	if err != nil {
+		return nil, errWrongPassword
+	}
+	if len(dataKey) != KeySize {
+		return nil, fmt.Errorf("verification file corrupted (bad data key length)")
+	}
+	return dataKey, nil
+}
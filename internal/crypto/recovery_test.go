@@ -0,0 +1,186 @@
+package crypto
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+// BIP-39 reference vectors (entropy → mnemonic)
+var mnemonicVectors = []struct {
+	entropy  string
+	mnemonic string
+}{
+	{
+		"00000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon about",
+	},
+	{
+		"7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f7f",
+		"legal winner thank year wave sausage worth useful legal winner thank yellow",
+	},
+	{
+		"0000000000000000000000000000000000000000000000000000000000000000",
+		"abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon abandon art",
+	},
+	{
+		"8080808080808080808080808080808080808080808080808080808080808080",
+		"letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic avoid letter advice cage absurd amount doctor acoustic bless",
+	},
+	{
+		"ffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffffff",
+		"zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo zoo vote",
+	},
+	{
+		"b63a9c59a6e641f288ebc103017f1da9f8290b3da6bdef7b",
+		"renew stay biology evidence goat welcome casual join adapt armor shuffle fault little machine walk stumble urge swap",
+	},
+	{
+		"2c85efc7f24ee4573d2b81a6ec66cee209b2dcbd09d8eddc51e0215b0b68e416",
+		"clutch control vehicle tonight unusual clog visa ice plunge glimpse recipe series open hour vintage deposit universe tip job dress radar refuse motion taste",
+	},
+}
+
+func TestMnemonic_Vectors(t *testing.T) {
+	for _, v := range mnemonicVectors {
+		entropy, _ := hex.DecodeString(v.entropy)
+
+		got, err := EncodeMnemonic(entropy)
+		if err != nil {
+			t.Fatalf("EncodeMnemonic(%s) failed: %v", v.entropy, err)
+		}
+		if got != v.mnemonic {
+			t.Errorf("EncodeMnemonic(%s)\nGot:  %s\nWant: %s", v.entropy, got, v.mnemonic)
+		}
+
+		decoded, err := DecodeMnemonic(v.mnemonic)
+		if err != nil {
+			t.Fatalf("DecodeMnemonic(%q) failed: %v", v.mnemonic, err)
+		}
+		if !bytes.Equal(decoded, entropy) {
+			t.Errorf("DecodeMnemonic round trip = %x, want %s", decoded, v.entropy)
+		}
+	}
+}
+
+func TestDecodeMnemonic_Prefixes(t *testing.T) {
+	v := mnemonicVectors[6]
+
+	var short []string
+	for _, w := range strings.Fields(v.mnemonic) {
+		if len(w) > 4 {
+			w = w[:4]
+		}
+		short = append(short, strings.ToUpper(w))
+	}
+
+	decoded, err := DecodeMnemonic(strings.Join(short, "  "))
+	if err != nil {
+		t.Fatalf("DecodeMnemonic with prefixes failed: %v", err)
+	}
+	if hex.EncodeToString(decoded) != v.entropy {
+		t.Errorf("decoded %x, want %s", decoded, v.entropy)
+	}
+}
+
+func TestDecodeMnemonic_Errors(t *testing.T) {
+	tests := []struct {
+		name   string
+		phrase string
+	}{
+		{"bad checksum", strings.Repeat("abandon ", 24)},
+		{"unknown word", strings.Replace(mnemonicVectors[2].mnemonic, "art", "qwerty", 1)},
+		{"wrong length", "abandon abandon abandon"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := DecodeMnemonic(tt.phrase); err == nil {
+				t.Errorf("DecodeMnemonic(%q) should fail", tt.phrase)
+			}
+		})
+	}
+}
+
+func TestDeriveRecoveryKey_Vector(t *testing.T) {
+	entropy := make([]byte, RecoveryKeySize)
+	for i := range entropy {
+		entropy[i] = byte(i)
+	}
+	salt := bytes.Repeat([]byte{0xaa}, SaltSize)
+
+	key, err := deriveRecoveryKey(entropy, salt)
+	if err != nil {
+		t.Fatalf("deriveRecoveryKey failed: %v", err)
+	}
+
+	want := "9eb793f4513047d7cb35f93cd049bf2edf1a7c7f8f7b86dc927d49e0e87e6f33"
+	if hex.EncodeToString(key) != want {
+		t.Errorf("deriveRecoveryKey = %x, want %s", key, want)
+	}
+}
+
+func TestRecoverySlot_UnlocksDataKey(t *testing.T) {
+	fileBytes, dataKey, err := NewVerificationFileBytes("forgotten", fastKDFParams)
+	if err != nil {
+		t.Fatalf("NewVerificationFileBytes failed: %v", err)
+	}
+	vf, _ := ParseVerifyFile(fileBytes)
+
+	if _, _, err := vf.UnlockRecovery(make([]byte, RecoveryKeySize)); err == nil {
+		t.Error("UnlockRecovery should fail on a vault without recovery slots")
+	}
+
+	entropy, _ := hex.DecodeString(mnemonicVectors[6].entropy)
+	slot, err := NewRecoverySlot(DefaultRecoveryLabel, dataKey, entropy)
+	if err != nil {
+		t.Fatalf("NewRecoverySlot failed: %v", err)
+	}
+	if err := vf.AddSlot(*slot); err != nil {
+		t.Fatalf("AddSlot failed: %v", err)
+	}
+
+	// Survives a serialization round trip
+	data, err := vf.Bytes()
+	if err != nil {
+		t.Fatalf("Bytes failed: %v", err)
+	}
+	vf, err = ParseVerifyFile(data)
+	if err != nil {
+		t.Fatalf("ParseVerifyFile failed: %v", err)
+	}
+
+	phrase, _ := EncodeMnemonic(entropy)
+	decoded, err := DecodeMnemonic(phrase)
+	if err != nil {
+		t.Fatalf("DecodeMnemonic failed: %v", err)
+	}
+	key, idx, err := vf.UnlockRecovery(decoded)
+	if err != nil {
+		t.Fatalf("UnlockRecovery failed: %v", err)
+	}
+	if idx != 1 || !bytes.Equal(key, dataKey) {
+		t.Errorf("UnlockRecovery = slot %d, key match %v; want slot 1 and the data key", idx, bytes.Equal(key, dataKey))
+	}
+
+	if key, err := vf.UnlockRecoveryAt(1, decoded); err != nil || !bytes.Equal(key, dataKey) {
+		t.Errorf("UnlockRecoveryAt(1) = %v, key match %v", err, bytes.Equal(key, dataKey))
+	}
+	if _, err := vf.UnlockRecoveryAt(0, decoded); err == nil {
+		t.Error("UnlockRecoveryAt should refuse a password slot")
+	}
+
+	wrong := bytes.Repeat([]byte{0x01}, RecoveryKeySize)
+	if _, _, err := vf.UnlockRecovery(wrong); err == nil {
+		t.Error("UnlockRecovery should fail with the wrong recovery key")
+	}
+	if _, err := vf.UnlockRecoveryAt(1, wrong); err == nil {
+		t.Error("UnlockRecoveryAt should fail with the wrong recovery key")
+	}
+
+	// A recovery phrase typed at the password prompt must not unlock
+	if _, err := vf.Unlock(phrase); err == nil {
+		t.Error("password unlock should ignore recovery slots")
+	}
+}
@@ -0,0 +1,441 @@
+package crypto
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/agent"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/config"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/secmem"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/session"
+)
+
+// ============================================================================
+// Session Management
+// ============================================================================
+//
+// After unlocking, the vault key is cached per terminal and per vault so following
+// commands don't prompt. A session locks after SessionConfig.IdleTimeout without use
+// (each use restarts it) and SessionConfig.MaxLifetime after unlocking, whichever
+// comes first; `dredge unlock --for` trades the idle timeout for a fixed lifetime.
+
+const sessionCacheFile = ".key" // 32-byte master key + expiry policy (see sessionRecord)
+
+// Session cache backends reported in SessionInfo.Backend
+const (
+	BackendAgent   = "agent"
+	BackendKeyring = "keyring"
+	BackendFile    = "file"
+)
+
+// SessionConfig selects where session keys are cached and how long they last (set from
+// main, from config.toml). With the default "auto" backend, keys live in `dredge agent`'s
+// memory while it runs and in the key file under the session directory otherwise. The
+// "keyring" backend uses the Linux kernel keyring, and the file when keyring syscalls
+// are unavailable.
+var SessionConfig = config.Default().Session
+
+var errKeyringUnavailable = errors.New("kernel keyring is not available")
+
+// keyringPrefix starts the description of every session key dredge puts in a keyring.
+const keyringPrefix = "dredge:"
+
+// SessionInfo describes the cached session of this terminal for the active vault.
+type SessionInfo struct {
+	Active   bool
+	Backend  string    // BackendAgent, BackendKeyring or BackendFile
+	Expires  time.Time // when the session locks unless used again; zero = never
+	Deadline time.Time // when the session locks however often it is used; zero = never
+}
+
+// sessionRecord is a cached key and its expiry policy, as kept by the file and keyring
+// backends: [32B key][8B idle seconds][8B deadline unix][8B last use unix]
+type sessionRecord struct {
+	key      []byte
+	idle     time.Duration // 0 = no idle timeout
+	deadline time.Time     // zero = no absolute limit
+	lastUse  time.Time
+}
+
+const sessionRecordSize = KeySize + 24
+
+// newSessionRecord starts a session for key with the configured timeouts.
+func newSessionRecord(key []byte) *sessionRecord {
+	r := &sessionRecord{key: key, lastUse: time.Now()}
+	if NoLock {
+		return r
+	}
+	r.idle = SessionConfig.IdleTimeout
+	if SessionConfig.MaxLifetime > 0 {
+		r.deadline = r.lastUse.Add(SessionConfig.MaxLifetime)
+	}
+	return r
+}
+
+func (r *sessionRecord) marshal() []byte {
+	buf := make([]byte, sessionRecordSize)
+	copy(buf, r.key)
+	binary.BigEndian.PutUint64(buf[KeySize:], uint64(r.idle/time.Second))
+	if !r.deadline.IsZero() {
+		binary.BigEndian.PutUint64(buf[KeySize+8:], uint64(r.deadline.Unix()))
+	}
+	binary.BigEndian.PutUint64(buf[KeySize+16:], uint64(r.lastUse.Unix()))
+	return buf
+}
+
+// parseSessionRecord decodes a cached record. A bare 32-byte key (cached by older
+// versions) gets the configured idle timeout, counted from lastUse. Returns nil if
+// data is neither.
+func parseSessionRecord(data []byte, lastUse time.Time) *sessionRecord {
+	switch len(data) {
+	case KeySize:
+		return &sessionRecord{key: data, idle: SessionConfig.IdleTimeout, lastUse: lastUse}
+	case sessionRecordSize:
+		r := &sessionRecord{
+			key:     data[:KeySize],
+			idle:    time.Duration(binary.BigEndian.Uint64(data[KeySize:])) * time.Second,
+			lastUse: time.Unix(int64(binary.BigEndian.Uint64(data[KeySize+16:])), 0),
+		}
+		if sec := int64(binary.BigEndian.Uint64(data[KeySize+8:])); sec != 0 {
+			r.deadline = time.Unix(sec, 0)
+		}
+		return r
+	}
+	return nil
+}
+
+// expires returns when the session locks unless used again (zero = never).
+func (r *sessionRecord) expires() time.Time {
+	var t time.Time
+	if r.idle > 0 {
+		t = r.lastUse.Add(r.idle)
+	}
+	if !r.deadline.IsZero() && (t.IsZero() || r.deadline.Before(t)) {
+		t = r.deadline
+	}
+	return t
+}
+
+// expired reports whether the session has locked. --no-lock keeps file sessions alive;
+// the agent and the kernel enforce their timeouts themselves.
+func (r *sessionRecord) expired(now time.Time) bool {
+	expires := r.expires()
+	return !NoLock && !expires.IsZero() && now.After(expires)
+}
+
+func (r *sessionRecord) info(backend string) SessionInfo {
+	return SessionInfo{Active: true, Backend: backend, Expires: r.expires(), Deadline: r.deadline}
+}
+
+// vaultID identifies the active vault in session caches (hash of its path).
+func vaultID() string {
+	h := sha256.Sum256([]byte(session.GetVaultPath()))
+	return fmt.Sprintf("%x", h)[:8]
+}
+
+// vaultKeyDir returns the session subdirectory scoped to the active vault,
+// so switching vaults never reuses a cached key from a different vault.
+// Path: $XDG_RUNTIME_DIR/dredge/$PPID/<vaulthash>/
+func vaultKeyDir() string {
+	return filepath.Join(session.Dir(), vaultID())
+}
+
+// keyringDescription names this terminal's key for the active vault in the kernel
+// keyring, scoped like the key file: dredge:<ppid>:<vaulthash>
+func keyringDescription() string {
+	return fmt.Sprintf("%s%d:%s", keyringPrefix, os.Getppid(), vaultID())
+}
+
+// GetCachedKey retrieves the cached 32-byte master key from the configured backend,
+// in locked memory, and restarts the session's idle timeout.
+// Returns nil if there is no session or it has expired.
+func GetCachedKey() ([]byte, error) {
+	key, _, err := loadSession(true)
+	return lockKey(key), err
+}
+
+// GetSessionInfo reports the session of this terminal for the active vault without
+// counting as a use.
+func GetSessionInfo() (SessionInfo, error) {
+	key, info, err := loadSession(false)
+	secmem.Wipe(key)
+	return info, err
+}
+
+// CacheKey starts a session for the 32-byte master key in the configured backend:
+// the agent or the kernel keyring when available, the session file otherwise.
+func CacheKey(key []byte) error {
+	if len(key) != KeySize {
+		return fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	return storeSession(newSessionRecord(key))
+}
+
+// SetSessionLifetime replaces the idle timeout of the session for key with a fixed
+// lifetime d (dredge unlock --for). The session never outlives its current deadline
+// or MaxLifetime. Returns when it will lock.
+func SetSessionLifetime(key []byte, d time.Duration) (time.Time, error) {
+	if len(key) != KeySize {
+		return time.Time{}, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+
+	// Caches keep whole seconds
+	now := time.Now()
+	r := &sessionRecord{key: key, lastUse: now, deadline: now.Add(d).Truncate(time.Second)}
+
+	current, err := GetSessionInfo()
+	if err != nil {
+		return time.Time{}, err
+	}
+	limit := current.Deadline
+	if !current.Active && SessionConfig.MaxLifetime > 0 {
+		limit = now.Add(SessionConfig.MaxLifetime)
+	}
+	if !limit.IsZero() && limit.Before(r.deadline) {
+		r.deadline = limit
+	}
+
+	return r.deadline, storeSession(r)
+}
+
+// loadSession returns the cached key and its session info from the configured backend.
+// touch counts the lookup as a use, restarting the idle timeout.
+func loadSession(touch bool) ([]byte, SessionInfo, error) {
+	switch SessionConfig.Cache {
+	case config.CacheFile:
+		return loadFileSession(touch)
+
+	case config.CacheKeyring:
+		key, info, err := loadKeyringSession(touch)
+		if err != errKeyringUnavailable {
+			return key, info, err
+		}
+		debugKeyringFallback()
+		return loadFileSession(touch)
+	}
+
+	get := agent.Peek
+	if touch {
+		get = agent.Get
+	}
+	s, err := get(vaultID())
+	if err == agent.ErrNotRunning {
+		return loadFileSession(touch)
+	}
+	if err != nil || s == nil {
+		return nil, SessionInfo{}, err
+	}
+	if touch && len(s.Key) != KeySize {
+		return nil, SessionInfo{}, nil
+	}
+	return s.Key, SessionInfo{Active: true, Backend: BackendAgent, Expires: s.Expires, Deadline: s.Deadline}, nil
+}
+
+// storeSession caches r in the configured backend.
+func storeSession(r *sessionRecord) error {
+	switch SessionConfig.Cache {
+	case config.CacheFile:
+		return writeKeyFile(r)
+
+	case config.CacheKeyring:
+		err := putKeyringSession(r)
+		if err == nil {
+			_ = removeKeyFile()
+			return nil
+		}
+		if err != errKeyringUnavailable {
+			return err
+		}
+		debugKeyringFallback()
+		return writeKeyFile(r)
+	}
+
+	err := agent.Put(vaultID(), r.key, r.idle, r.deadline)
+	if err == nil {
+		// A key file from before the agent started would outlive it; drop it
+		_ = removeKeyFile()
+		return nil
+	}
+	if err != agent.ErrNotRunning {
+		return fmt.Errorf("failed to cache key in agent: %w", err)
+	}
+	return writeKeyFile(r)
+}
+
+// ClearSession drops this terminal's key for the active vault from every backend.
+func ClearSession() error {
+	if err := agent.Lock(vaultID()); err != nil && err != agent.ErrNotRunning {
+		return fmt.Errorf("failed to lock agent session: %w", err)
+	}
+	if err := keyringClear(SessionConfig.Keyring, keyringDescription()); err != nil && err != errKeyringUnavailable {
+		return err
+	}
+	return removeKeyFile()
+}
+
+// ClearAllSessions drops every cached key: all of the agent's, every dredge key in the
+// kernel keyrings, and the key files of every terminal and vault under the runtime
+// directory. Returns how many were dropped.
+func ClearAllSessions() (int, error) {
+	cleared, err := agent.LockAll()
+	if err != nil && err != agent.ErrNotRunning {
+		return 0, fmt.Errorf("failed to lock agent: %w", err)
+	}
+
+	n, err := keyringClearAll()
+	cleared += n
+	if err != nil {
+		return cleared, err
+	}
+
+	// $XDG_RUNTIME_DIR/dredge/<ppid>/<vaulthash>/.key
+	files, _ := filepath.Glob(filepath.Join(session.BaseDir(), "*", "*", sessionCacheFile))
+	for _, path := range files {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return cleared, fmt.Errorf("failed to clear session cache: %w", err)
+		}
+		cleared++
+	}
+	return cleared, nil
+}
+
+// HasActiveSession checks if a valid session key exists for current terminal.
+// Does not count as a use.
+func HasActiveSession() bool {
+	info, err := GetSessionInfo()
+	return err == nil && info.Active
+}
+
+// GetPPID returns the parent process ID (for debugging/testing).
+func GetPPID() string {
+	return strconv.Itoa(os.Getppid())
+}
+
+func debugKeyringFallback() {
+	if DebugMode {
+		fmt.Fprintln(os.Stderr, "[DEBUG] kernel keyring unavailable, using session file")
+	}
+}
+
+// ============================================================================
+// Keyring Backend
+// ============================================================================
+
+func loadKeyringSession(touch bool) ([]byte, SessionInfo, error) {
+	data, err := keyringGet(SessionConfig.Keyring, keyringDescription())
+	if err != nil || data == nil {
+		return nil, SessionInfo{}, err
+	}
+
+	now := time.Now()
+	r := parseSessionRecord(data, now)
+	if r == nil || r.expired(now) {
+		_ = keyringClear(SessionConfig.Keyring, keyringDescription())
+		return nil, SessionInfo{}, nil
+	}
+
+	if touch {
+		r.lastUse = now
+		if err := putKeyringSession(r); err != nil {
+			return nil, SessionInfo{}, err
+		}
+	}
+	return r.key, r.info(BackendKeyring), nil
+}
+
+// putKeyringSession stores r with a kernel timeout matching its expiry.
+func putKeyringSession(r *sessionRecord) error {
+	var ttl time.Duration
+	if expires := r.expires(); !expires.IsZero() {
+		ttl = max(time.Until(expires), time.Second)
+	}
+	payload := r.marshal()
+	defer secmem.Wipe(payload)
+	return keyringPut(SessionConfig.Keyring, keyringDescription(), payload, ttl)
+}
+
+// ============================================================================
+// File Backend
+// ============================================================================
+
+// loadFileSession reads this terminal's session key file for the active vault.
+// Returns a nil key if it is missing, expired or corrupt.
+func loadFileSession(touch bool) ([]byte, SessionInfo, error) {
+	cachePath := filepath.Join(vaultKeyDir(), sessionCacheFile)
+
+	info, err := os.Stat(cachePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, SessionInfo{}, nil
+		}
+		return nil, SessionInfo{}, fmt.Errorf("failed to stat session cache: %w", err)
+	}
+
+	data, err := os.ReadFile(cachePath)
+	if err != nil {
+		return nil, SessionInfo{}, fmt.Errorf("failed to read session cache: %w", err)
+	}
+
+	now := time.Now()
+	r := parseSessionRecord(data, info.ModTime())
+	if r == nil {
+		return nil, SessionInfo{}, nil // corrupt cache, treat as missing
+	}
+	if r.expired(now) {
+		_ = os.Remove(cachePath)
+		return nil, SessionInfo{}, nil
+	}
+
+	if touch {
+		r.lastUse = now
+		if err := writeKeyFile(r); err != nil {
+			return nil, SessionInfo{}, err
+		}
+	}
+	return r.key, r.info(BackendFile), nil
+}
+
+// writeKeyFile stores r in this terminal's session key file for the active vault.
+// Written to a temporary file and renamed, so a concurrent read never sees half of it.
+func writeKeyFile(r *sessionRecord) error {
+	dir := vaultKeyDir()
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return fmt.Errorf("failed to create session directory: %w", err)
+	}
+
+	tmp, err := os.CreateTemp(dir, sessionCacheFile+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to cache key: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	payload := r.marshal()
+	defer secmem.Wipe(payload)
+	if _, err := tmp.Write(payload); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to cache key: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to cache key: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(dir, sessionCacheFile)); err != nil {
+		return fmt.Errorf("failed to cache key: %w", err)
+	}
+	return nil
+}
+
+// removeKeyFile deletes this terminal's session key file for the active vault; silent if missing.
+func removeKeyFile() error {
+	cachePath := filepath.Join(vaultKeyDir(), sessionCacheFile)
+	err := os.Remove(cachePath)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear session cache: %w", err)
+	}
+	return nil
+}
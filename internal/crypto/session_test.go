@@ -3,7 +3,11 @@ package crypto
 import (
 	"bytes"
 	"os"
+	"path/filepath"
 	"testing"
+	"time"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/config"
 )
 
 // testSessionKey returns a valid 32-byte key for session tests.
@@ -146,3 +150,144 @@ func TestSessionCache_Permissions(t *testing.T) {
 	// Clean up
 	_ = ClearSession()
 }
+
+// useFileCache runs one test against the session file in a temporary runtime directory.
+func useFileCache(t *testing.T, idle, maxLifetime time.Duration) {
+	t.Helper()
+	t.Setenv("XDG_RUNTIME_DIR", t.TempDir())
+	old := SessionConfig
+	SessionConfig = config.Session{Cache: config.CacheFile, IdleTimeout: idle, MaxLifetime: maxLifetime}
+	t.Cleanup(func() { SessionConfig = old })
+}
+
+func TestSessionTimeouts(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name   string
+		record sessionRecord
+		active bool
+	}{
+		{"idle, recently used", sessionRecord{idle: 5 * time.Minute, lastUse: now.Add(-4 * time.Minute)}, true},
+		{"idle, unused too long", sessionRecord{idle: 5 * time.Minute, lastUse: now.Add(-6 * time.Minute)}, false},
+		{"past deadline while in use", sessionRecord{idle: time.Hour, lastUse: now, deadline: now.Add(-time.Second)}, false},
+		{"fixed lifetime, unused", sessionRecord{lastUse: now.Add(-time.Hour), deadline: now.Add(time.Hour)}, true},
+		{"no timeouts", sessionRecord{lastUse: now.Add(-24 * time.Hour)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			useFileCache(t, 5*time.Minute, 0)
+			tt.record.key = testSessionKey()
+			if err := writeKeyFile(&tt.record); err != nil {
+				t.Fatalf("writeKeyFile failed: %v", err)
+			}
+
+			key, err := GetCachedKey()
+			if err != nil {
+				t.Fatalf("GetCachedKey failed: %v", err)
+			}
+			if (key != nil) != tt.active {
+				t.Errorf("GetCachedKey returned key = %v, want active = %v", key != nil, tt.active)
+			}
+		})
+	}
+}
+
+func TestSessionTimeouts_UseRestartsIdle(t *testing.T) {
+	useFileCache(t, 5*time.Minute, 0)
+	record := sessionRecord{key: testSessionKey(), idle: 5 * time.Minute, lastUse: time.Now().Add(-4 * time.Minute)}
+	if err := writeKeyFile(&record); err != nil {
+		t.Fatalf("writeKeyFile failed: %v", err)
+	}
+
+	// Looking at the session is not a use
+	info, _ := GetSessionInfo()
+	if remaining := time.Until(info.Expires); remaining > 2*time.Minute {
+		t.Fatalf("GetSessionInfo restarted the idle timeout (%s left)", remaining)
+	}
+
+	if key, _ := GetCachedKey(); key == nil {
+		t.Fatal("GetCachedKey should return the key")
+	}
+	info, _ = GetSessionInfo()
+	if remaining := time.Until(info.Expires); remaining < 4*time.Minute {
+		t.Errorf("GetCachedKey should restart the idle timeout, %s left", remaining)
+	}
+}
+
+func TestCacheKey_MaxLifetime(t *testing.T) {
+	useFileCache(t, 5*time.Minute, time.Hour)
+	if err := CacheKey(testSessionKey()); err != nil {
+		t.Fatalf("CacheKey failed: %v", err)
+	}
+
+	info, _ := GetSessionInfo()
+	if !info.Active || info.Backend != BackendFile {
+		t.Fatalf("GetSessionInfo = %+v, want an active file session", info)
+	}
+	if remaining := time.Until(info.Deadline); remaining < 59*time.Minute || remaining > time.Hour {
+		t.Errorf("deadline in %s, want the one hour max_lifetime", remaining)
+	}
+	if remaining := time.Until(info.Expires); remaining > 5*time.Minute {
+		t.Errorf("expires in %s, want the five minute idle timeout", remaining)
+	}
+}
+
+func TestSetSessionLifetime(t *testing.T) {
+	useFileCache(t, 5*time.Minute, 0)
+	key := testSessionKey()
+	_ = CacheKey(key)
+
+	deadline, err := SetSessionLifetime(key, 2*time.Hour)
+	if err != nil {
+		t.Fatalf("SetSessionLifetime failed: %v", err)
+	}
+	if remaining := time.Until(deadline); remaining < 119*time.Minute {
+		t.Errorf("deadline in %s, want two hours", remaining)
+	}
+
+	// No idle timeout: it lasts until the deadline, used or not
+	info, _ := GetSessionInfo()
+	if !info.Expires.Equal(info.Deadline) {
+		t.Errorf("Expires = %v, want the deadline %v", info.Expires, info.Deadline)
+	}
+
+	// A fixed lifetime never extends the current one
+	shorter, _ := SetSessionLifetime(key, time.Hour)
+	if longer, _ := SetSessionLifetime(key, 3*time.Hour); !longer.Equal(shorter) {
+		t.Errorf("SetSessionLifetime extended the session to %v, want it kept at %v", longer, shorter)
+	}
+}
+
+func TestSetSessionLifetime_CappedByMaxLifetime(t *testing.T) {
+	useFileCache(t, 5*time.Minute, 30*time.Minute)
+
+	deadline, err := SetSessionLifetime(testSessionKey(), 2*time.Hour)
+	if err != nil {
+		t.Fatalf("SetSessionLifetime failed: %v", err)
+	}
+	if remaining := time.Until(deadline); remaining > 30*time.Minute {
+		t.Errorf("deadline in %s, want it capped at max_lifetime", remaining)
+	}
+}
+
+func TestGetCachedKey_BareKeyFile(t *testing.T) {
+	useFileCache(t, 5*time.Minute, 0)
+
+	// Session files from older versions hold only the key; their age is the mtime
+	path := filepath.Join(vaultKeyDir(), sessionCacheFile)
+	_ = os.MkdirAll(filepath.Dir(path), 0700)
+	if err := os.WriteFile(path, testSessionKey(), 0600); err != nil {
+		t.Fatalf("failed to write key file: %v", err)
+	}
+	if key, _ := GetCachedKey(); !bytes.Equal(key, testSessionKey()) {
+		t.Fatal("GetCachedKey should accept a fresh bare key file")
+	}
+
+	_ = os.WriteFile(path, testSessionKey(), 0600)
+	old := time.Now().Add(-10 * time.Minute)
+	_ = os.Chtimes(path, old, old)
+	if key, _ := GetCachedKey(); key != nil {
+		t.Error("GetCachedKey should expire a bare key file older than the idle timeout")
+	}
+}
@@ -0,0 +1,352 @@
+package crypto
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/cipher"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// ============================================================================
+// Streaming Encryption
+// ============================================================================
+//
+// Large storage blobs are encrypted as a chunked stream (after age's STREAM) instead
+// of one GCM call, so memory use stays flat and GCM's per-message limit never applies:
+//
+//	[8B header, FlagStream set][16B stream nonce][chunk 0]...[chunk N]
+//
+// Each chunk is StreamChunkSize bytes of plaintext sealed with AES-256-GCM under a
+// per-file key HKDF(key, stream nonce). The chunk nonce is an 11-byte big-endian
+// counter followed by a last-chunk byte, so chunks cannot be reordered, dropped or
+// appended, and truncating at a chunk boundary fails on the missing last chunk.
+// The header and associated data are authenticated with every chunk.
+
+const (
+	// StreamChunkSize is the plaintext size of every chunk but the last.
+	StreamChunkSize = 64 * 1024
+
+	streamNonceSize = 16
+	streamKeyInfo   = "dredge stream v1"
+	streamTagSize   = 16
+)
+
+var errStreamTruncated = errors.New("encrypted stream is truncated")
+
+// IsStream reports whether the payload is a chunked stream (see NewEncryptWriter).
+func (h Header) IsStream() bool {
+	return h.Flags&FlagStream != 0
+}
+
+// streamAEAD derives the per-file chunk cipher from key and the stream nonce.
+func streamAEAD(key, nonce []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("key must be %d bytes, got %d", KeySize, len(key))
+	}
+	streamKey, err := hkdf.Key(sha256.New, key, nonce, streamKeyInfo, KeySize)
+	if err != nil {
+		return nil, fmt.Errorf("failed to derive stream key: %w", err)
+	}
+	return newGCM(streamKey)
+}
+
+// chunkNonce returns the GCM nonce for chunk counter: [11B big-endian counter][1B last flag].
+func chunkNonce(counter uint64, last bool) []byte {
+	nonce := make([]byte, NonceSize)
+	binary.BigEndian.PutUint64(nonce[3:11], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// streamWriter buffers one chunk of plaintext and seals it once more data arrives,
+// so the final chunk is only known (and flagged) on Close.
+type streamWriter struct {
+	dst     io.Writer
+	aead    cipher.AEAD
+	aad     []byte
+	buf     []byte
+	counter uint64
+	closed  bool
+
+	padded bool  // pad to the size bucket on Close
+	total  int64 // plaintext bytes written
+}
+
+// NewEncryptWriter returns a writer that encrypts everything written to it into dst
+// as a chunked stream bound to ad (nil for none). Close must be called to write the
+// final chunk; it does not close dst.
+func NewEncryptWriter(dst io.Writer, key []byte, ad []byte) (io.WriteCloser, error) {
+	return newEncryptWriter(dst, key, ad, PayloadOptions{})
+}
+
+// NewPaddedEncryptWriter is NewEncryptWriter that pads the plaintext to its size bucket
+// on Close (see PaddedSize).
+func NewPaddedEncryptWriter(dst io.Writer, key []byte, ad []byte) (io.WriteCloser, error) {
+	return newEncryptWriter(dst, key, ad, PayloadOptions{Pad: true})
+}
+
+// newEncryptWriter starts an encrypt stream. A writer can't see ahead, so opts.Compress
+// compresses unconditionally here; EncryptStreamWith decides whether it pays first.
+func newEncryptWriter(dst io.Writer, key []byte, ad []byte, opts PayloadOptions) (io.WriteCloser, error) {
+	nonce := make([]byte, streamNonceSize)
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate stream nonce: %w", err)
+	}
+	aead, err := streamAEAD(key, nonce)
+	if err != nil {
+		return nil, err
+	}
+
+	h := defaultHeader()
+	h.Flags |= FlagStream | opts.flags()
+	if opts.Compress {
+		h.Flags |= FlagCompressed
+	}
+	if ad != nil {
+		h.Flags |= FlagBoundAD
+	}
+	header := h.Bytes()
+
+	if _, err := dst.Write(append(header, nonce...)); err != nil {
+		return nil, fmt.Errorf("failed to write stream header: %w", err)
+	}
+
+	w := &streamWriter{
+		dst:    dst,
+		aead:   aead,
+		aad:    additionalData(header, ad),
+		buf:    make([]byte, 0, StreamChunkSize),
+		padded: opts.Pad,
+	}
+	if opts.Compress {
+		return newCompressWriter(w), nil
+	}
+	return w, nil
+}
+
+func (w *streamWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed encrypt stream")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		// A full buffer is only sealed once more data arrives, so it is not the last chunk
+		if len(w.buf) == StreamChunkSize {
+			if err := w.seal(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):StreamChunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	w.total += int64(written)
+	return written, nil
+}
+
+// Close pads the plaintext if requested and seals the buffered data as the final chunk.
+func (w *streamWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	if w.padded {
+		if err := writePadding(w, w.total); err != nil {
+			return err
+		}
+	}
+	w.closed = true
+	return w.seal(true)
+}
+
+func (w *streamWriter) seal(last bool) error {
+	if w.counter == 1<<64-1 {
+		return errors.New("encrypt stream too long")
+	}
+	sealed := w.aead.Seal(nil, chunkNonce(w.counter, last), w.buf, w.aad)
+	if _, err := w.dst.Write(sealed); err != nil {
+		return fmt.Errorf("failed to write encrypted chunk: %w", err)
+	}
+	w.counter++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// streamReader authenticates one chunk at a time; no plaintext is returned from a
+// chunk before its tag has been verified.
+type streamReader struct {
+	src     *bufio.Reader
+	aead    cipher.AEAD
+	aad     []byte
+	chunk   []byte // ciphertext buffer
+	plain   []byte // verified plaintext not yet returned
+	counter uint64
+	done    bool
+	err     error
+}
+
+// NewDecryptReader returns a reader over the plaintext of a payload bound to ad.
+// Chunked streams are decrypted incrementally. Single-call envelopes and legacy
+// headerless payloads (blobs written before streaming) are read whole and opened
+// with DecryptWithAD, so existing vaults keep working.
+func NewDecryptReader(src io.Reader, key []byte, ad []byte) (io.Reader, error) {
+	return newDecryptReader(src, key, ad, false)
+}
+
+// NewBoundDecryptReader is NewDecryptReader for payloads that must be bound to ad
+// (see DecryptBound).
+func NewBoundDecryptReader(src io.Reader, key []byte, ad []byte) (io.Reader, error) {
+	return newDecryptReader(src, key, ad, true)
+}
+
+func newDecryptReader(src io.Reader, key []byte, ad []byte, bound bool) (io.Reader, error) {
+	br := bufio.NewReaderSize(src, StreamChunkSize+streamTagSize)
+
+	prefix, _ := br.Peek(HeaderSize)
+	header, ok := ParseHeader(prefix)
+	if bound && (!ok || !header.IsBound() || ad == nil) {
+		return nil, errNotBound
+	}
+	if !ok || !header.IsStream() {
+		encrypted, err := io.ReadAll(br)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read encrypted data: %w", err)
+		}
+		decrypt := DecryptWithAD
+		if bound {
+			decrypt = DecryptBound
+		}
+		plaintext, err := decrypt(encrypted, key, ad)
+		if err != nil {
+			return nil, err
+		}
+		return bytes.NewReader(plaintext), nil
+	}
+
+	if err := header.validate(); err != nil {
+		return nil, err
+	}
+	if header.IsBound() && ad == nil {
+		return nil, fmt.Errorf("payload is bound to an item ID and cannot be opened without it")
+	}
+	if !header.IsBound() {
+		ad = nil
+	}
+
+	start := make([]byte, HeaderSize+streamNonceSize)
+	if _, err := io.ReadFull(br, start); err != nil {
+		return nil, errStreamTruncated
+	}
+	aead, err := streamAEAD(key, start[HeaderSize:])
+	if err != nil {
+		return nil, err
+	}
+
+	var r io.Reader = &streamReader{
+		src:   br,
+		aead:  aead,
+		aad:   additionalData(start[:HeaderSize], ad),
+		chunk: make([]byte, StreamChunkSize+streamTagSize),
+	}
+	if header.IsPadded() {
+		r = newUnpadReader(r)
+	}
+	if header.IsCompressed() {
+		r = newInflateReader(r)
+	}
+	return r, nil
+}
+
+func (r *streamReader) Read(p []byte) (int, error) {
+	for len(r.plain) == 0 {
+		if r.err != nil {
+			return 0, r.err
+		}
+		if r.done {
+			return 0, io.EOF
+		}
+		r.err = r.next()
+	}
+
+	n := copy(p, r.plain)
+	r.plain = r.plain[n:]
+	return n, nil
+}
+
+// next reads and opens the following chunk. A chunk is the last one when the input
+// ends with it, which must match the flag it was sealed with.
+func (r *streamReader) next() error {
+	n, err := io.ReadFull(r.src, r.chunk)
+	last := false
+	switch {
+	case err == io.EOF:
+		// The previous chunk was not sealed as last, so data is missing
+		return errStreamTruncated
+	case err == io.ErrUnexpectedEOF:
+		last = true
+	case err != nil:
+		return fmt.Errorf("failed to read encrypted chunk: %w", err)
+	default:
+		if _, peekErr := r.src.Peek(1); peekErr == io.EOF {
+			last = true
+		}
+	}
+
+	plain, err := r.aead.Open(r.chunk[:0], chunkNonce(r.counter, last), r.chunk[:n], r.aad)
+	if err != nil {
+		if last {
+			return fmt.Errorf("decryption failed (wrong key, tampered or truncated data, or file moved from another ID): %w", err)
+		}
+		return fmt.Errorf("decryption failed (wrong key, tampered data, or file moved from another ID): %w", err)
+	}
+	// Only an empty stream may end with an empty chunk
+	if last && len(plain) == 0 && r.counter > 0 {
+		return errStreamTruncated
+	}
+
+	r.counter++
+	r.done = last
+	r.plain = plain
+	return nil
+}
+
+// EncryptStream copies src into dst as a chunked stream bound to ad.
+// Returns the number of plaintext bytes encrypted.
+func EncryptStream(dst io.Writer, src io.Reader, key []byte, ad []byte) (int64, error) {
+	return EncryptStreamWith(dst, src, key, ad, PayloadOptions{})
+}
+
+// EncryptStreamPadded is EncryptStream with the plaintext padded to its size bucket.
+func EncryptStreamPadded(dst io.Writer, src io.Reader, key []byte, ad []byte) (int64, error) {
+	return EncryptStreamWith(dst, src, key, ad, PayloadOptions{Pad: true})
+}
+
+// EncryptStreamWith is EncryptStream with the plaintext compressed and/or padded first.
+// Whether compression pays is judged on the first chunk of src.
+func EncryptStreamWith(dst io.Writer, src io.Reader, key []byte, ad []byte, opts PayloadOptions) (int64, error) {
+	if opts.Compress {
+		br := bufio.NewReaderSize(src, StreamChunkSize)
+		sample, _ := br.Peek(StreamChunkSize) // short at EOF; read errors surface in the copy below
+		opts.Compress = sampleCompresses(sample)
+		src = br
+	}
+
+	w, err := newEncryptWriter(dst, key, ad, opts)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(w, src)
+	if err != nil {
+		return n, err
+	}
+	return n, w.Close()
+}
@@ -0,0 +1,125 @@
+package crypto
+
+import (
+	"bytes"
+	"crypto/rand"
+	"io"
+	"testing"
+)
+
+// encryptStreamBytes encrypts plaintext as a stream, writing it in odd-sized pieces.
+func encryptStreamBytes(t *testing.T, plaintext, key, ad []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	w, err := NewEncryptWriter(&buf, key, ad)
+	if err != nil {
+		t.Fatalf("NewEncryptWriter failed: %v", err)
+	}
+	for p := plaintext; len(p) > 0; {
+		n := min(len(p), 1000)
+		if _, err := w.Write(p[:n]); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+		p = p[n:]
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	return buf.Bytes()
+}
+
+func decryptStreamBytes(encrypted, key, ad []byte) ([]byte, error) {
+	r, err := NewDecryptReader(bytes.NewReader(encrypted), key, ad)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestStream_RoundTrip(t *testing.T) {
+	key := make([]byte, KeySize)
+	ad := AssociatedData(KindBlob, "abc")
+
+	sizes := []int{0, 1, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, 3*StreamChunkSize + 17}
+	for _, size := range sizes {
+		plaintext := make([]byte, size)
+		_, _ = rand.Read(plaintext)
+
+		encrypted := encryptStreamBytes(t, plaintext, key, ad)
+
+		header, ok := ParseHeader(encrypted)
+		if !ok || !header.IsStream() || !header.IsBound() {
+			t.Fatalf("size %d: stream header = %+v, want stream and bound flags", size, header)
+		}
+
+		decrypted, err := decryptStreamBytes(encrypted, key, ad)
+		if err != nil {
+			t.Fatalf("size %d: decrypt failed: %v", size, err)
+		}
+		if !bytes.Equal(decrypted, plaintext) {
+			t.Errorf("size %d: round trip mismatch", size)
+		}
+	}
+}
+
+func TestStream_Tampering(t *testing.T) {
+	key := make([]byte, KeySize)
+	ad := AssociatedData(KindBlob, "abc")
+	plaintext := make([]byte, 2*StreamChunkSize+100)
+	_, _ = rand.Read(plaintext)
+
+	encrypted := encryptStreamBytes(t, plaintext, key, ad)
+	chunk := StreamChunkSize + streamTagSize
+	start := HeaderSize + streamNonceSize
+
+	tests := []struct {
+		name string
+		data []byte
+	}{
+		{"truncated at chunk boundary", encrypted[:start+2*chunk]},
+		{"last chunk dropped partially", encrypted[:len(encrypted)-10]},
+		{"chunks swapped", append(append(append([]byte{}, encrypted[:start]...),
+			encrypted[start+chunk:start+2*chunk]...),
+			append(append([]byte{}, encrypted[start:start+chunk]...), encrypted[start+2*chunk:]...)...)},
+		{"flipped byte", func() []byte {
+			b := append([]byte{}, encrypted...)
+			b[start+chunk+5] ^= 1
+			return b
+		}()},
+		{"trailing data", append(append([]byte{}, encrypted...), 0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := decryptStreamBytes(tt.data, key, ad); err == nil {
+				t.Error("decrypt should fail")
+			}
+		})
+	}
+
+	if _, err := decryptStreamBytes(encrypted, key, AssociatedData(KindBlob, "xyz")); err == nil {
+		t.Error("decrypt should fail for a blob moved to another ID")
+	}
+	if _, err := Decrypt(encrypted, key); err == nil {
+		t.Error("Decrypt should refuse a chunked stream")
+	}
+}
+
+func TestDecryptReader_SingleMessageBlob(t *testing.T) {
+	key := make([]byte, KeySize)
+	ad := AssociatedData(KindBlob, "abc")
+
+	// Blobs written before streaming are one GCM message
+	encrypted, err := EncryptWithAD([]byte("old blob"), key, ad)
+	if err != nil {
+		t.Fatalf("EncryptWithAD failed: %v", err)
+	}
+
+	decrypted, err := decryptStreamBytes(encrypted, key, ad)
+	if err != nil {
+		t.Fatalf("NewDecryptReader failed on a single-message blob: %v", err)
+	}
+	if string(decrypted) != "old blob" {
+		t.Errorf("got %q, want %q", decrypted, "old blob")
+	}
+}
@@ -10,6 +10,7 @@ import (
 
 	"github.com/DeprecatedLuar/dredge-cargo/internal/session"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/ui"
 )
 
 const (
@@ -18,81 +19,105 @@ const (
 	defaultTempFileSuffix = ".md"
 )
 
-// OpenForNewItem opens editor with initial title/tags, returns new Item
+// OpenForNewItem opens editor with initial title/tags (and fields/content, from an item
+// template), returns new Item
 // If title is empty, opens with blank template for user to fill in
-func OpenForNewItem(title string, tags []string) (*storage.Item, error) {
+func OpenForNewItem(title string, tags []string, fields []storage.Field, content string) (*storage.Item, error) {
 	// Create template (may be empty for "dredge add" with no args)
-	templateContent := createTemplate(title, tags, "")
+	templateContent := createTemplate(title, tags, fields, content)
 
-	// Open editor and get edited content
-	editedContent, err := openEditor(templateContent, defaultTempFileSuffix)
+	// Open editor and parse the result back to values
+	parsed, err := editTemplate(templateContent, defaultTempFileSuffix, storage.TypeText)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse template back to values
-	parsedTitle, parsedContent, parsedTags, err := parseTemplate(editedContent)
-	if err != nil {
-		return nil, err
-	}
-
-	// Validate that user provided a title
-	if parsedTitle == "" {
-		return nil, fmt.Errorf("title cannot be empty")
-	}
-
 	// Create new item with current timestamp
-	item := storage.NewTextItem(parsedTitle, parsedContent, parsedTags)
+	item := storage.NewTextItem(parsed.title, parsed.content, parsed.tags)
+	item.Content.Fields = parsed.fields
 	return item, nil
 }
 
 // OpenForExisting opens editor with existing item, returns updated Item
 func OpenForExisting(item *storage.Item) (*storage.Item, error) {
 	// Create template from existing item
-	templateContent := createTemplate(item.Title, item.Tags, item.Content.Text)
+	templateContent := createTemplate(item.Title, item.Tags, item.Content.Fields, item.Content.Text)
 
 	suffix := defaultTempFileSuffix
 	if item.Filename != "" {
 		suffix = filepath.Ext(item.Filename)
 	}
 
-	// Open editor and get edited content
-	editedContent, err := openEditor(templateContent, suffix)
+	// Open editor and parse the result back to values
+	parsed, err := editTemplate(templateContent, suffix, item.Type)
 	if err != nil {
 		return nil, err
 	}
 
-	// Parse template back to values
-	parsedTitle, parsedContent, parsedTags, err := parseTemplate(editedContent)
-	if err != nil {
-		return nil, err
-	}
-
-	if parsedTitle == "" {
-		return nil, fmt.Errorf("title cannot be empty")
-	}
-
 	// Create updated item, preserving metadata
 	updated := &storage.Item{
-		Title:    parsedTitle,
-		Tags:     parsedTags,
+		Title:    parsed.title,
+		Tags:     parsed.tags,
 		Type:     item.Type,
 		Created:  item.Created,
 		Modified: time.Now(),
 		Filename: item.Filename,
 		Content: storage.ItemContent{
-			Text: parsedContent,
+			Text:   parsed.content,
+			Fields: parsed.fields,
 		},
 	}
 
 	return updated, nil
 }
 
+// parsedTemplate is what the user saved in the editor.
+type parsedTemplate struct {
+	title, content string
+	tags           []string
+	fields         []storage.Field
+}
+
+// editTemplate opens the template in the editor and parses what was saved. If that
+// can't be used (a bad field line, no title...), it says why and offers to reopen the
+// editor on the saved text, so the edit isn't lost. An empty buffer cancels.
+func editTemplate(templateContent, suffix string, itemType storage.ItemType) (*parsedTemplate, error) {
+	for {
+		editedContent, err := openEditor(templateContent, suffix)
+		if err != nil {
+			return nil, err
+		}
+
+		var p parsedTemplate
+		p.title, p.content, p.tags, p.fields, err = parseTemplate(editedContent)
+		if err == nil && p.title == "" {
+			err = fmt.Errorf("title cannot be empty")
+		}
+		if err == nil && len(p.fields) > 0 && itemType != storage.TypeText {
+			err = fmt.Errorf("only text items can have fields")
+		}
+		if err == nil {
+			return &p, nil
+		}
+		if strings.TrimSpace(editedContent) == "" {
+			return nil, err
+		}
+
+		// Keep the user's changes: offer to fix them rather than dropping them
+		answer, promptErr := ui.PromptLine(fmt.Sprintf("Error: %v\nReopen the editor to fix it? [Y/n] ", err))
+		if promptErr != nil || strings.HasPrefix(strings.ToLower(answer), "n") {
+			return nil, err
+		}
+		templateContent = editedContent
+	}
+}
+
 // createTemplate creates the simple template format:
 // Line 1: title #tag1 #tag2
-// Line 2: blank
-// Lines 3+: content
-func createTemplate(title string, tags []string, content string) string {
+// Next lines: fields, one per line as "name: value" or "name (type): value" (optional)
+// Then: blank
+// Rest: content
+func createTemplate(title string, tags []string, fields []storage.Field, content string) string {
 	var sb strings.Builder
 
 	// Line 1: Title and tags
@@ -105,7 +130,13 @@ func createTemplate(title string, tags []string, content string) string {
 	}
 	sb.WriteString("\n")
 
-	// Line 2: Blank separator
+	// Fields, if any
+	for _, f := range fields {
+		sb.WriteString(formatField(f))
+		sb.WriteString("\n")
+	}
+
+	// Blank separator
 	sb.WriteString("\n")
 
 	// Lines 3+: Content
@@ -116,11 +147,13 @@ func createTemplate(title string, tags []string, content string) string {
 
 // parseTemplate parses the template format back into components
 // Tags must be trailing: "title #tag1 #tag2" (not "title #tag word")
-// Handles minimal input: just a title line is valid (content optional)
-func parseTemplate(content string) (title, contentText string, tags []string, err error) {
+// Handles minimal input: just a title line is valid (fields and content optional)
+// Content normally starts after the blank line ending the fields; if a line that isn't
+// a field comes first, the content starts there instead.
+func parseTemplate(content string) (title, contentText string, tags []string, fields []storage.Field, err error) {
 	// Check for empty content
 	if strings.TrimSpace(content) == "" {
-		return "", "", nil, fmt.Errorf("empty template")
+		return "", "", nil, nil, fmt.Errorf("empty template")
 	}
 
 	lines := strings.Split(content, "\n")
@@ -129,13 +162,78 @@ func parseTemplate(content string) (title, contentText string, tags []string, er
 	firstLine := lines[0]
 	title, tags = parseTitleAndTags(firstLine)
 
-	// If there are at least 3 lines, lines 3+ are content
-	// Line 2 is expected to be blank separator (but we're lenient)
-	if len(lines) >= 3 {
-		contentText = strings.Join(lines[2:], "\n")
+	// Field lines follow the title, up to a blank line or the first line that isn't one
+	n := 1
+	for ; n < len(lines); n++ {
+		if strings.TrimSpace(lines[n]) == "" {
+			n++ // skip the blank separator
+			break
+		}
+		field, ok, err := parseField(lines[n])
+		if err != nil {
+			return "", "", nil, nil, fmt.Errorf("line %d: %w", n+1, err)
+		}
+		if !ok {
+			break
+		}
+		fields = append(fields, field)
+	}
+	if err := storage.ValidateFields(fields); err != nil {
+		return "", "", nil, nil, err
+	}
+
+	// The rest is content
+	if n < len(lines) {
+		contentText = strings.Join(lines[n:], "\n")
+	}
+
+	return title, contentText, tags, fields, nil
+}
+
+// formatField writes a field as a template line; the type is only spelled out when
+// it isn't text.
+func formatField(f storage.Field) string {
+	if f.Type == storage.FieldText {
+		return f.Name + ": " + f.Value
+	}
+	return fmt.Sprintf("%s (%s): %s", f.Name, f.Type, f.Value)
+}
+
+// parseField parses a field line: "name: value" or "name (type): value". ok is false
+// for lines that aren't shaped like one (no ": " after a valid name, e.g. prose or a
+// URL), which are content. A field line with an unknown type is an error, so a typo
+// doesn't move a secret into the content.
+func parseField(line string) (field storage.Field, ok bool, err error) {
+	line = strings.TrimRight(line, "\r")
+	head, value, found := strings.Cut(line, ":")
+	if !found || (value != "" && value[0] != ' ') {
+		return storage.Field{}, false, nil
+	}
+
+	field = storage.Field{
+		Name:  strings.TrimSpace(head),
+		Type:  storage.FieldText,
+		Value: strings.TrimPrefix(value, " "),
+	}
+	typeName := ""
+	if name, typ, hasType := strings.Cut(head, "("); hasType {
+		typ = strings.TrimSpace(typ)
+		if !strings.HasSuffix(typ, ")") {
+			return storage.Field{}, false, nil
+		}
+		field.Name = strings.TrimSpace(name)
+		typeName = strings.TrimSpace(strings.TrimSuffix(typ, ")"))
+	}
+	if !storage.ValidFieldName(field.Name) {
+		return storage.Field{}, false, nil
 	}
 
-	return title, contentText, tags, nil
+	if typeName != "" {
+		if field.Type, err = storage.ParseFieldType(typeName); err != nil {
+			return storage.Field{}, false, err
+		}
+	}
+	return field, true, nil
 }
 
 // parseTitleAndTags extracts title and tags from first line
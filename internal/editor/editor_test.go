@@ -1,7 +1,10 @@
 package editor
 
 import (
+	"slices"
 	"testing"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
 )
 
 func TestParseTemplate(t *testing.T) {
@@ -57,7 +60,7 @@ func TestParseTemplate(t *testing.T) {
 
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
-			gotTitle, gotContent, gotTags, err := parseTemplate(tt.input)
+			gotTitle, gotContent, gotTags, _, err := parseTemplate(tt.input)
 
 			if (err != nil) != tt.wantErr {
 				t.Errorf("parseTemplate() error = %v, wantErr %v", err, tt.wantErr)
@@ -168,3 +171,66 @@ func TestParseTitleAndTags(t *testing.T) {
 		})
 	}
 }
+
+func TestParseTemplate_Fields(t *testing.T) {
+	fields := []storage.Field{
+		{Name: "username", Type: storage.FieldText, Value: "luar"},
+		{Name: "password", Type: storage.FieldSecret, Value: " p@ss: word "},
+		{Name: "url", Type: storage.FieldText, Value: ""},
+	}
+	template := createTemplate("GitHub", []string{"web"}, fields, "recovery codes below\n\n1234")
+	if want := "GitHub #web\nusername: luar\npassword (secret):  p@ss: word \nurl: \n\nrecovery codes below\n\n1234"; template != want {
+		t.Fatalf("createTemplate() = %q, want %q", template, want)
+	}
+
+	title, content, tags, gotFields, err := parseTemplate(template)
+	if err != nil {
+		t.Fatalf("parseTemplate() error = %v", err)
+	}
+	if title != "GitHub" || content != "recovery codes below\n\n1234" || !slices.Equal(tags, []string{"web"}) {
+		t.Errorf("parseTemplate() = %q, %q, %v", title, content, tags)
+	}
+	if !slices.Equal(gotFields, fields) {
+		t.Errorf("parseTemplate() fields = %+v, want %+v", gotFields, fields)
+	}
+
+	// Without fields the template is unchanged
+	if got := createTemplate("title", nil, nil, "body"); got != "title\n\nbody" {
+		t.Errorf("createTemplate() without fields = %q", got)
+	}
+
+	for _, bad := range []string{
+		"title\npin (number): 1234",
+		"title\nuser: a\nUser: b",
+		"title\ncode (otp): https://example.com",
+	} {
+		if _, _, _, _, err := parseTemplate(bad); err == nil {
+			t.Errorf("parseTemplate(%q) should fail", bad)
+		}
+	}
+}
+
+func TestParseTemplate_ContentWithoutSeparator(t *testing.T) {
+	// Lines that aren't fields start the content, even without the blank line
+	tests := []struct {
+		input       string
+		wantFields  []storage.Field
+		wantContent string
+	}{
+		{"title\nhttps://example.com\nmore", nil, "https://example.com\nmore"},
+		{"title\nnot a field\n\nbody", nil, "not a field\n\nbody"},
+		{"title\nuser name: x", nil, "user name: x"},
+		{"title\nmeet at 10:30", nil, "meet at 10:30"},
+		{"title\nuser: luar\nsee https://example.com", []storage.Field{{Name: "user", Type: storage.FieldText, Value: "luar"}}, "see https://example.com"},
+	}
+	for _, tt := range tests {
+		_, content, _, fields, err := parseTemplate(tt.input)
+		if err != nil {
+			t.Errorf("parseTemplate(%q) error = %v", tt.input, err)
+			continue
+		}
+		if content != tt.wantContent || !slices.Equal(fields, tt.wantFields) {
+			t.Errorf("parseTemplate(%q) = %+v, %q; want %+v, %q", tt.input, fields, content, tt.wantFields, tt.wantContent)
+		}
+	}
+}
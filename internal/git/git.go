@@ -13,7 +13,14 @@ import (
 const (
 	GitIgnoreContent = `spawned/
 links.json
+.dredge-index
 `
+
+	// The vault manifest is re-signed after every pull rather than merged: rebasing
+	// keeps the remote's copy and dredge adds the local changes back on top
+	GitAttributesContent = `.dredge-manifest merge=dredge-manifest
+`
+	manifestMergeDriver = "merge.dredge-manifest.driver"
 )
 
 // Init initializes a git repository for dredge and optionally connects a remote.
@@ -61,6 +68,9 @@ func Init(dredgeDir, remote string) error {
 	if err := ensureGitIgnore(gitignorePath, GitIgnoreContent); err != nil {
 		return err
 	}
+	if err := ensureManifestMerge(dredgeDir); err != nil {
+		return err
+	}
 
 	// Add remote if provided
 	if normalizedRemote != "" {
@@ -95,6 +105,10 @@ func Push(dredgeDir string) error {
 		return fmt.Errorf("no git remote configured - run 'dredge remote <url>' to add a remote")
 	}
 
+	if err := ensureManifestMerge(dredgeDir); err != nil {
+		return err
+	}
+
 	// Always stage tracked files first
 	if err := addTrackedFiles(dredgeDir); err != nil {
 		return err
@@ -131,6 +145,11 @@ func Pull(dredgeDir string) error {
 		return fmt.Errorf("failed to get current branch: %w", err)
 	}
 
+	// Vaults created before the manifest, or cloned, lack the merge driver
+	if err := ensureManifestMerge(dredgeDir); err != nil {
+		return err
+	}
+
 	// Pull with rebase
 	output, err := runGitCommand(dredgeDir, "pull", "--rebase", "origin", branch)
 	if err != nil {
@@ -198,7 +217,7 @@ func commitInitial(dir string) error {
 	return nil
 }
 
-// addTrackedFiles adds items/ and .dredge-key to git staging
+// addTrackedFiles adds items/, storage/, .dredge-key and .dredge-config.toml to git staging
 func addTrackedFiles(dir string) error {
 	// Add .gitignore if this is initial setup
 	gitignorePath := filepath.Join(dir, ".gitignore")
@@ -229,6 +248,23 @@ func addTrackedFiles(dir string) error {
 		}
 	}
 
+	// Add the vault config if it exists (shared timeouts and padding)
+	configFile := filepath.Join(dir, ".dredge-config.toml")
+	if _, err := os.Stat(configFile); err == nil {
+		if _, err := runGitCommand(dir, "add", ".dredge-config.toml"); err != nil {
+			return fmt.Errorf("failed to add .dredge-config.toml: %w", err)
+		}
+	}
+
+	// Add the vault manifest and its merge attributes if they exist
+	for _, name := range []string{".dredge-manifest", ".gitattributes"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			if _, err := runGitCommand(dir, "add", name); err != nil {
+				return fmt.Errorf("failed to add %s: %w", name, err)
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -333,6 +369,48 @@ func CountUnpushedChanges(dredgeDir string) int {
 	return count
 }
 
+// UpstreamFile returns the content of name as the remote branch has it (after a
+// fetch or pull). ok is false if the remote branch or the file doesn't exist.
+func UpstreamFile(dredgeDir, name string) ([]byte, bool) {
+	branch, err := getCurrentBranch(dredgeDir)
+	if err != nil {
+		return nil, false
+	}
+	cmd := exec.Command("git", "show", "origin/"+branch+":"+name)
+	cmd.Dir = dredgeDir
+	output, err := cmd.Output()
+	if err != nil {
+		return nil, false
+	}
+	return output, true
+}
+
+// LocalChanges returns the files (relative to dredgeDir) that differ from the remote
+// branch: unpushed commits, uncommitted edits and untracked files.
+func LocalChanges(dredgeDir string) ([]string, error) {
+	branch, err := getCurrentBranch(dredgeDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get current branch: %w", err)
+	}
+
+	diff, err := runGitCommand(dredgeDir, "diff", "--name-only", "origin/"+branch)
+	if err != nil {
+		return nil, fmt.Errorf("failed to diff against origin/%s: %s", branch, strings.TrimSpace(diff))
+	}
+	untracked, err := runGitCommand(dredgeDir, "ls-files", "--others", "--exclude-standard")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list untracked files: %s", strings.TrimSpace(untracked))
+	}
+
+	var files []string
+	for _, line := range strings.Split(diff+"\n"+untracked, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			files = append(files, line)
+		}
+	}
+	return files, nil
+}
+
 // isGitRepo checks if directory is a git repository
 func isGitRepo(dir string) bool {
 	gitDir := filepath.Join(dir, ".git")
@@ -506,7 +584,7 @@ func ensureGitIgnore(path string, content string) error {
 	// If missing, create as-is.
 	if _, err := os.Stat(path); os.IsNotExist(err) {
 		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
-			return fmt.Errorf("failed to create .gitignore: %w", err)
+			return fmt.Errorf("failed to create %s: %w", filepath.Base(path), err)
 		}
 		return nil
 	}
@@ -514,7 +592,7 @@ func ensureGitIgnore(path string, content string) error {
 	// If present, append any missing lines.
 	data, err := os.ReadFile(path)
 	if err != nil {
-		return fmt.Errorf("failed to read .gitignore: %w", err)
+		return fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
 	}
 	existing := string(data)
 
@@ -536,11 +614,24 @@ func ensureGitIgnore(path string, content string) error {
 	toAppend := "\n" + strings.Join(missing, "\n") + "\n"
 	f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0644)
 	if err != nil {
-		return fmt.Errorf("failed to open .gitignore: %w", err)
+		return fmt.Errorf("failed to open %s: %w", filepath.Base(path), err)
 	}
 	defer f.Close()
 	if _, err := f.WriteString(toAppend); err != nil {
-		return fmt.Errorf("failed to update .gitignore: %w", err)
+		return fmt.Errorf("failed to update %s: %w", filepath.Base(path), err)
+	}
+	return nil
+}
+
+// ensureManifestMerge writes .gitattributes and configures the merge driver for the
+// vault manifest. The driver config lives in .git/config, so every clone needs it.
+func ensureManifestMerge(dir string) error {
+	if err := ensureGitIgnore(filepath.Join(dir, ".gitattributes"), GitAttributesContent); err != nil {
+		return err
+	}
+	// "true" leaves the file as it is, which during a rebase is the remote's copy
+	if _, err := runGitCommand(dir, "config", manifestMergeDriver, "true"); err != nil {
+		return fmt.Errorf("failed to configure manifest merge driver: %w", err)
 	}
 	return nil
 }
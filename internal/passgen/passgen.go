@@ -0,0 +1,178 @@
+// Package passgen generates random passwords and word passphrases for new secrets.
+package passgen
+
+import (
+	"crypto/rand"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/wordlist"
+)
+
+// Character classes
+const (
+	lower   = "abcdefghijklmnopqrstuvwxyz"
+	upper   = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	digits  = "0123456789"
+	symbols = "!#$%&()*+,-./:;<=>?@[]^_{}~" // no quotes, backslash, backtick or space: safe to paste into a shell or config
+
+	// ambiguous characters are easy to misread when typing a password from a screen
+	ambiguous = "0O1lI"
+)
+
+const (
+	MinLength = 4
+	MaxLength = 1024
+	MinWords  = 3
+	MaxWords  = 64
+)
+
+// Policy says what to generate.
+type Policy struct {
+	Length int `toml:"length"` // characters of a password
+
+	// Classes used; a password has at least one of each
+	Lower   bool `toml:"lower"`
+	Upper   bool `toml:"upper"`
+	Digits  bool `toml:"digits"`
+	Symbols bool `toml:"symbols"`
+
+	ExcludeAmbiguous bool `toml:"exclude_ambiguous"` // leave out 0 O 1 l I
+
+	Passphrase bool   `toml:"passphrase"` // generate words instead of characters
+	Words      int    `toml:"words"`      // words of a passphrase
+	Separator  string `toml:"separator"`  // between words
+}
+
+// Default is used when nothing else is asked for (set from main, from the vault's config).
+var Default = Policy{
+	Length:  24,
+	Lower:   true,
+	Upper:   true,
+	Digits:  true,
+	Symbols: true,
+
+	Words:     7,
+	Separator: "-",
+}
+
+// Validate checks that the policy can generate something.
+func (p Policy) Validate() error {
+	if p.Passphrase {
+		if p.Words < MinWords || p.Words > MaxWords {
+			return fmt.Errorf("passphrases have %d to %d words, got %d", MinWords, MaxWords, p.Words)
+		}
+		if strings.ContainsAny(p.Separator, "\r\n") {
+			return fmt.Errorf("separator cannot contain a line break")
+		}
+		return nil
+	}
+	if p.Length < MinLength || p.Length > MaxLength {
+		return fmt.Errorf("passwords have %d to %d characters, got %d", MinLength, MaxLength, p.Length)
+	}
+	classes := p.classes()
+	if len(classes) == 0 {
+		return fmt.Errorf("no character classes left to generate from")
+	}
+	if p.Length < len(classes) {
+		return fmt.Errorf("a %d-character password cannot hold all %d character classes", p.Length, len(classes))
+	}
+	return nil
+}
+
+// Generate returns a new password or passphrase following the policy.
+func (p Policy) Generate() (string, error) {
+	if err := p.Validate(); err != nil {
+		return "", err
+	}
+	if p.Passphrase {
+		return p.passphrase()
+	}
+	return p.password()
+}
+
+// Bits is the entropy of what the policy generates (ignoring the at-least-one-of-each
+// rule, which costs a fraction of a bit).
+func (p Policy) Bits() float64 {
+	if p.Passphrase {
+		return float64(p.Words) * math.Log2(wordlist.Size)
+	}
+	return float64(p.Length) * math.Log2(float64(len(strings.Join(p.classes(), ""))))
+}
+
+// classes returns the character sets the policy uses.
+func (p Policy) classes() []string {
+	var classes []string
+	for _, c := range []struct {
+		use bool
+		set string
+	}{{p.Lower, lower}, {p.Upper, upper}, {p.Digits, digits}, {p.Symbols, symbols}} {
+		if !c.use {
+			continue
+		}
+		set := c.set
+		if p.ExcludeAmbiguous {
+			set = strings.Map(func(r rune) rune {
+				if strings.ContainsRune(ambiguous, r) {
+					return -1
+				}
+				return r
+			}, set)
+		}
+		classes = append(classes, set)
+	}
+	return classes
+}
+
+// password draws characters uniformly from all classes, and draws again until every
+// class appears (so the result is uniform among passwords that have them all).
+func (p Policy) password() (string, error) {
+	classes := p.classes()
+	alphabet := strings.Join(classes, "")
+	buf := make([]byte, p.Length)
+	for {
+		for i := range buf {
+			n, err := randIndex(len(alphabet))
+			if err != nil {
+				return "", err
+			}
+			buf[i] = alphabet[n]
+		}
+		if hasAll(buf, classes) {
+			return string(buf), nil
+		}
+	}
+}
+
+func hasAll(buf []byte, classes []string) bool {
+	for _, set := range classes {
+		if !strings.ContainsAny(string(buf), set) {
+			return false
+		}
+	}
+	return true
+}
+
+// passphrase picks words uniformly from the embedded list (11 bits each).
+func (p Policy) passphrase() (string, error) {
+	words := make([]string, p.Words)
+	for i := range words {
+		n, err := randIndex(wordlist.Size)
+		if err != nil {
+			return "", err
+		}
+		words[i] = wordlist.English[n]
+	}
+	return strings.Join(words, p.Separator), nil
+}
+
+// randIndex returns a uniform random number in [0, n).
+func randIndex(n int) (int, error) {
+	v, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, fmt.Errorf("failed to generate random number: %w", err)
+	}
+	return int(v.Int64()), nil
+}
@@ -0,0 +1,91 @@
+package passgen
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/wordlist"
+)
+
+func TestGenerate_Password(t *testing.T) {
+	p := Default
+	p.Length = 12
+	p.ExcludeAmbiguous = true
+
+	seen := make(map[string]bool)
+	for i := 0; i < 200; i++ {
+		pw, err := p.Generate()
+		if err != nil {
+			t.Fatalf("Generate failed: %v", err)
+		}
+		if len(pw) != 12 {
+			t.Fatalf("len(%q) = %d, want 12", pw, len(pw))
+		}
+		for _, set := range []string{lower, upper, digits, symbols} {
+			if !strings.ContainsAny(pw, set) {
+				t.Fatalf("%q is missing a character from %q", pw, set)
+			}
+		}
+		if strings.ContainsAny(pw, ambiguous) {
+			t.Fatalf("%q has ambiguous characters", pw)
+		}
+		seen[pw] = true
+	}
+	if len(seen) < 200 {
+		t.Errorf("only %d distinct passwords out of 200", len(seen))
+	}
+}
+
+func TestGenerate_Classes(t *testing.T) {
+	pin := Policy{Length: 6, Digits: true}
+	pw, err := pin.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	if strings.Trim(pw, digits) != "" || len(pw) != 6 {
+		t.Errorf("digits-only policy generated %q", pw)
+	}
+	if bits := pin.Bits(); bits < 19.9 || bits > 20 {
+		t.Errorf("Bits() = %.2f, want ~19.93", bits)
+	}
+}
+
+func TestGenerate_Passphrase(t *testing.T) {
+	p := Default
+	p.Passphrase = true
+	p.Words = 5
+	p.Separator = " "
+
+	phrase, err := p.Generate()
+	if err != nil {
+		t.Fatalf("Generate failed: %v", err)
+	}
+	words := strings.Split(phrase, " ")
+	if len(words) != 5 {
+		t.Fatalf("%q has %d words, want 5", phrase, len(words))
+	}
+	for _, w := range words {
+		if wordlist.Index(w) < 0 {
+			t.Errorf("%q is not a list word", w)
+		}
+	}
+	if p.Bits() != 55 {
+		t.Errorf("Bits() = %v, want 55", p.Bits())
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, bad := range []Policy{
+		{Length: 3, Lower: true},
+		{Length: 20},
+		{Length: 4, Lower: true, Upper: true, Digits: true, Symbols: true, ExcludeAmbiguous: true, Passphrase: true, Words: 2},
+		{Passphrase: true, Words: 6, Separator: "\n"},
+	} {
+		if err := bad.Validate(); err == nil {
+			t.Errorf("Validate(%+v) should fail", bad)
+		}
+	}
+	if err := Default.Validate(); err != nil {
+		t.Errorf("Default.Validate() = %v", err)
+	}
+}
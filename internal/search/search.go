@@ -11,6 +11,7 @@ const (
 	// Scoring weights (exact matches)
 	titleMatchScore   = 100
 	tagMatchScore     = 10
+	fieldMatchScore   = 5
 	contentMatchScore = 1
 
 	// Fuzzy match weights (lower than exact)
@@ -32,17 +33,19 @@ type Result struct {
 	Score int
 }
 
+// IndexResult is a search result from the metadata index
+type IndexResult struct {
+	ID    string
+	Entry *storage.IndexEntry
+	Score int
+}
+
 // Search performs a simple ranked search across items
 // Query is split into terms (space-separated)
 // All terms must match (AND logic)
-// Scoring: title=100, tags=10, content=1
+// Scoring: title=100, tags=10, field names=5, content=1
 func Search(items map[string]*storage.Item, query string) []Result {
-	query = strings.TrimSpace(query)
-	if query == "" {
-		return []Result{}
-	}
-
-	terms := strings.Fields(strings.ToLower(query))
+	terms := queryTerms(query)
 	if len(terms) == 0 {
 		return []Result{}
 	}
@@ -60,33 +63,81 @@ func Search(items map[string]*storage.Item, query string) []Result {
 		}
 	}
 
-	// Sort by score descending (bubble sort is fine for <1000 items)
+	sortByScore(results, func(r Result) int { return r.Score })
+	return results
+}
+
+// SearchIndex is Search over the metadata index. Content matches against the entry's
+// tokens, which gives the same results since terms never contain whitespace. For
+// entries whose tokens were truncated, readContent (if not nil) supplies the item's
+// searchable text instead.
+func SearchIndex(entries map[string]*storage.IndexEntry, query string, readContent func(id string) (string, error)) []IndexResult {
+	terms := queryTerms(query)
+	if len(terms) == 0 {
+		return []IndexResult{}
+	}
+
+	var results []IndexResult
+
+	for id, entry := range entries {
+		content := strings.Join(entry.Tokens, "\n")
+		if entry.Truncated && readContent != nil {
+			if text, err := readContent(id); err == nil {
+				content = text
+			}
+		}
+		score, matched := scoreFields(entry.Title, entry.Tags, entry.Fields, content, terms)
+		if matched {
+			results = append(results, IndexResult{
+				ID:    id,
+				Entry: entry,
+				Score: score,
+			})
+		}
+	}
+
+	sortByScore(results, func(r IndexResult) int { return r.Score })
+	return results
+}
+
+// queryTerms splits a query into lowercased terms
+func queryTerms(query string) []string {
+	return strings.Fields(strings.ToLower(strings.TrimSpace(query)))
+}
+
+// sortByScore sorts results by score descending (bubble sort is fine for <1000 items)
+func sortByScore[T any](results []T, score func(T) int) {
 	for i := 0; i < len(results); i++ {
 		for j := i + 1; j < len(results); j++ {
-			if results[j].Score > results[i].Score {
+			if score(results[j]) > score(results[i]) {
 				results[i], results[j] = results[j], results[i]
 			}
 		}
 	}
-
-	return results
 }
 
 // scoreItem scores an item against search terms
-// Returns (score, matched) where matched=true if >50% of exponential weight matched
-// Exponential weighting: longer words dominate (github²=36 >> key²=9)
 func scoreItem(item *storage.Item, terms []string) (int, bool) {
-	title := strings.ToLower(item.Title)
-
 	// Only search content for text items (skip binary base64 data)
 	var content string
+	var fields []string
 	if item.Type == storage.TypeText {
-		content = strings.ToLower(item.Content.Text)
+		content = storage.SearchableText(item)
+		fields = item.FieldNames()
 	}
+	return scoreFields(item.Title, item.Tags, fields, content, terms)
+}
+
+// scoreFields scores a title, tags, field names and content against search terms
+// Returns (score, matched) where matched=true if >50% of exponential weight matched
+// Exponential weighting: longer words dominate (github²=36 >> key²=9)
+func scoreFields(title string, itemTags, fieldNames []string, content string, terms []string) (int, bool) {
+	title = strings.ToLower(title)
+	content = strings.ToLower(content)
 
 	// Lowercase all tags once
-	tags := make([]string, len(item.Tags))
-	for i, tag := range item.Tags {
+	tags := make([]string, len(itemTags))
+	for i, tag := range itemTags {
 		tags[i] = strings.ToLower(tag)
 	}
 
@@ -113,6 +164,13 @@ func scoreItem(item *storage.Item, terms []string) (int, bool) {
 			}
 		}
 
+		for _, name := range fieldNames {
+			if strings.Contains(strings.ToLower(name), term) {
+				termScore += fieldMatchScore * termWeight
+				break // Count field match once per term
+			}
+		}
+
 		if strings.Contains(content, term) {
 			termScore += contentMatchScore * termWeight
 		}
@@ -1,6 +1,8 @@
 package search
 
 import (
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
@@ -300,3 +302,87 @@ func TestSearch_ExactBeforeFuzzy(t *testing.T) {
 			results[0].Score, results[1].Score)
 	}
 }
+
+func TestSearchIndex_MatchesSearch(t *testing.T) {
+	items := map[string]*storage.Item{
+		"proton-email": storage.NewTextItem("ProtonMail Login", "user@proton.me\npassword: secure123", []string{"email"}),
+		"gmail-api":    storage.NewTextItem("Gmail API Key", "AIza...secret", []string{"email", "api"}),
+	}
+	entries := map[string]*storage.IndexEntry{
+		"proton-email": {Title: "ProtonMail Login", Tags: []string{"email"}, Type: storage.TypeText, Tokens: []string{"user@proton.me", "password:", "secure123"}},
+		"gmail-api":    {Title: "Gmail API Key", Tags: []string{"email", "api"}, Type: storage.TypeText, Tokens: []string{"aiza...secret"}},
+	}
+
+	for _, query := range []string{"proton", "email proton", "secret", "cure", "word: secure", "github"} {
+		want := Search(items, query)
+		got := SearchIndex(entries, query, nil)
+		if len(got) != len(want) {
+			t.Errorf("SearchIndex(%q) returned %d results, Search returned %d", query, len(got), len(want))
+			continue
+		}
+		for i := range want {
+			if got[i].ID != want[i].ID || got[i].Score != want[i].Score {
+				t.Errorf("SearchIndex(%q)[%d] = %s/%d, want %s/%d", query, i, got[i].ID, got[i].Score, want[i].ID, want[i].Score)
+			}
+		}
+	}
+}
+
+func TestSearch_FieldNames(t *testing.T) {
+	login := storage.NewTextItem("ProtonMail", "", nil)
+	login.Content.Fields = []storage.Field{
+		{Name: "username", Type: storage.FieldText, Value: "luar@proton.me"},
+		{Name: "password", Type: storage.FieldSecret, Value: "hunter2"},
+	}
+	items := map[string]*storage.Item{
+		"login": login,
+		"notes": storage.NewTextItem("Notes", "nothing here", nil),
+	}
+
+	results := Search(items, "password")
+	if len(results) != 1 || results[0].ID != "login" {
+		t.Fatalf("Search(password) = %+v, want the item with that field", results)
+	}
+	if want := fieldMatchScore * 8 * 8; results[0].Score != want {
+		t.Errorf("score = %d, want %d", results[0].Score, want)
+	}
+
+	// Plain values are content, secret ones are not searchable
+	if results := Search(items, "proton.me"); len(results) != 1 {
+		t.Errorf("Search(proton.me) returned %d results, want 1", len(results))
+	}
+	if results := Search(items, "hunter2"); len(results) != 0 {
+		t.Errorf("Search(hunter2) should not match a secret value")
+	}
+
+	entries := map[string]*storage.IndexEntry{
+		"login": {Title: "ProtonMail", Type: storage.TypeText, Fields: []string{"username", "password"}, Tokens: []string{"luar@proton.me"}},
+		"notes": {Title: "Notes", Type: storage.TypeText, Tokens: []string{"nothing", "here"}},
+	}
+	for _, query := range []string{"password", "user", "proton.me", "hunter2"} {
+		want, got := Search(items, query), SearchIndex(entries, query, nil)
+		if len(got) != len(want) || (len(got) > 0 && got[0].Score != want[0].Score) {
+			t.Errorf("SearchIndex(%q) differs from Search", query)
+		}
+	}
+}
+
+func TestSearchIndex_TruncatedEntry(t *testing.T) {
+	text := strings.Repeat("filler ", 10) + "needle"
+	entries := map[string]*storage.IndexEntry{
+		"big": {Title: "Big notes", Type: storage.TypeText, Tokens: []string{"filler"}, Truncated: true},
+	}
+	if results := SearchIndex(entries, "needle", nil); len(results) != 0 {
+		t.Fatalf("without the item only its indexed words match, got %+v", results)
+	}
+
+	var read []string
+	readContent := func(id string) (string, error) {
+		read = append(read, id)
+		return text, nil
+	}
+	results := SearchIndex(entries, "needle", readContent)
+	if len(results) != 1 || results[0].ID != "big" || !slices.Equal(read, []string{"big"}) {
+		t.Errorf("SearchIndex(needle) = %+v (read %v), want big found by reading it", results, read)
+	}
+}
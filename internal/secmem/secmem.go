@@ -0,0 +1,118 @@
+// Package secmem keeps keys and passwords out of swap and core dumps: buffers are
+// allocated outside the Go heap, locked into RAM and wiped when released.
+package secmem
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// ============================================================================
+// Secure Buffers
+// ============================================================================
+//
+// Each Buffer is its own anonymous mapping, locked with mlock so the kernel never
+// writes it to swap, and excluded from core dumps where the platform allows it.
+// Locked memory is limited by RLIMIT_MEMLOCK (often 64 KiB for unprivileged users, 8 MiB
+// on newer systems). When the limit is reached, buffers are still allocated and
+// wiped, just not locked: dredge keeps working with weaker guarantees, and says so
+// with --debug. Core dumps are disabled for the whole process by DisableCoreDumps,
+// which also covers what never goes into a Buffer (decrypted items, Go strings). The
+// limit is inherited, so the editor and git that dredge runs don't dump core either.
+
+// DebugMode reports buffers that could not be locked on stderr (set from main).
+var DebugMode bool
+
+// Buffer is memory for secrets. The zero value and nil are empty buffers.
+type Buffer struct {
+	data   []byte // the first n bytes of mem
+	mem    []byte // whole pages; nil when data is on the Go heap
+	locked bool
+}
+
+var warnOnce sync.Once
+
+// New returns a zeroed buffer of size bytes, locked into memory when possible.
+func New(size int) *Buffer {
+	if size <= 0 {
+		return &Buffer{}
+	}
+	mem, err := alloc(size)
+	if err != nil {
+		// No mapping at all: the heap still gets wiped on Destroy
+		debugf("secure memory unavailable (%v), using the heap", err)
+		return &Buffer{data: make([]byte, size)}
+	}
+
+	b := &Buffer{data: mem[:size], mem: mem}
+	if err := lock(mem); err != nil {
+		warnOnce.Do(func() {
+			debugf("failed to lock memory (%v); secrets may be swapped (raise RLIMIT_MEMLOCK, see 'ulimit -l')", err)
+		})
+	} else {
+		b.locked = true
+	}
+	return b
+}
+
+// From copies src into a new buffer and wipes src.
+func From(src []byte) *Buffer {
+	b := New(len(src))
+	copy(b.Bytes(), src)
+	Wipe(src)
+	return b
+}
+
+// Bytes returns the buffer's memory. It is only valid until Destroy.
+func (b *Buffer) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	return b.data
+}
+
+// Len returns the size of the buffer.
+func (b *Buffer) Len() int {
+	return len(b.Bytes())
+}
+
+// Locked reports whether the buffer is locked into memory.
+func (b *Buffer) Locked() bool {
+	return b != nil && b.locked
+}
+
+// Destroy wipes the buffer and releases its memory. Safe to call more than once.
+func (b *Buffer) Destroy() {
+	if b == nil {
+		return
+	}
+	Wipe(b.data)
+	if b.mem != nil {
+		if b.locked {
+			_ = unlock(b.mem)
+		}
+		_ = free(b.mem)
+	}
+	*b = Buffer{}
+}
+
+// Wipe zeroes b. Use it on copies of secrets that can't live in a Buffer.
+func Wipe(b []byte) {
+	clear(b)
+}
+
+// DisableCoreDumps stops the process from writing core dumps, which would contain
+// every key and decrypted item in memory. Called once from main.
+func DisableCoreDumps() error {
+	if err := disableCoreDumps(); err != nil {
+		return fmt.Errorf("failed to disable core dumps: %w", err)
+	}
+	return nil
+}
+
+func debugf(format string, args ...any) {
+	if DebugMode {
+		fmt.Fprintf(os.Stderr, "[DEBUG] "+format+"\n", args...)
+	}
+}
@@ -0,0 +1,11 @@
+//go:build darwin
+
+package secmem
+
+// macOS has no per-mapping dump exclusion; RLIMIT_CORE alone disables core dumps.
+
+func excludeFromDump(mem []byte) {}
+
+func setNotDumpable() error {
+	return nil
+}
@@ -0,0 +1,17 @@
+//go:build linux
+
+package secmem
+
+import "golang.org/x/sys/unix"
+
+// excludeFromDump keeps mem out of core dumps even if they are enabled again
+// (a core_pattern pipe, for one, ignores RLIMIT_CORE). Best effort.
+func excludeFromDump(mem []byte) {
+	_ = unix.Madvise(mem, unix.MADV_DONTDUMP)
+}
+
+// setNotDumpable also stops other processes of the same user from attaching with
+// ptrace or reading /proc/<pid>/mem.
+func setNotDumpable() error {
+	return unix.Prctl(unix.PR_SET_DUMPABLE, 0, 0, 0, 0)
+}
@@ -0,0 +1,79 @@
+package secmem
+
+import (
+	"bytes"
+	"testing"
+
+	"golang.org/x/sys/unix"
+)
+
+func TestFrom_CopiesAndWipesSource(t *testing.T) {
+	src := []byte("correct horse battery staple")
+	want := bytes.Clone(src)
+
+	b := From(src)
+	defer b.Destroy()
+
+	if !bytes.Equal(b.Bytes(), want) {
+		t.Errorf("Bytes() = %q, want %q", b.Bytes(), want)
+	}
+	if !bytes.Equal(src, make([]byte, len(src))) {
+		t.Error("From should wipe the source")
+	}
+}
+
+func TestDestroy_Wipes(t *testing.T) {
+	b := From([]byte("secret"))
+	data := b.Bytes()
+	mapped := b.mem != nil
+
+	b.Destroy()
+	if b.Len() != 0 || b.Locked() {
+		t.Error("destroyed buffer should be empty and unlocked")
+	}
+	b.Destroy() // twice is fine
+
+	// Only the heap fallback keeps the slice valid after Destroy; check it was wiped
+	if !mapped && !bytes.Equal(data, make([]byte, len(data))) {
+		t.Error("Destroy should wipe the buffer")
+	}
+}
+
+func TestEmpty(t *testing.T) {
+	var nilBuf *Buffer
+	nilBuf.Destroy()
+	if nilBuf.Bytes() != nil || nilBuf.Len() != 0 || nilBuf.Locked() {
+		t.Error("nil buffer should be empty")
+	}
+
+	b := New(0)
+	defer b.Destroy()
+	if b.Len() != 0 {
+		t.Errorf("New(0).Len() = %d", b.Len())
+	}
+}
+
+// Without locked memory left, buffers still work, just unlocked.
+func TestNew_MemlockExhausted(t *testing.T) {
+	var old unix.Rlimit
+	if err := unix.Getrlimit(unix.RLIMIT_MEMLOCK, &old); err != nil {
+		t.Skip("RLIMIT_MEMLOCK unavailable:", err)
+	}
+	if err := unix.Setrlimit(unix.RLIMIT_MEMLOCK, &unix.Rlimit{Cur: 0, Max: old.Max}); err != nil {
+		t.Skip("cannot lower RLIMIT_MEMLOCK:", err)
+	}
+	defer unix.Setrlimit(unix.RLIMIT_MEMLOCK, &old)
+
+	b := New(32)
+	defer b.Destroy()
+	if b.Locked() {
+		t.Skip("memory locked despite the limit (CAP_IPC_LOCK)")
+	}
+	if b.Len() != 32 {
+		t.Fatalf("Len() = %d, want 32", b.Len())
+	}
+	copy(b.Bytes(), "still usable")
+	if !bytes.HasPrefix(b.Bytes(), []byte("still usable")) {
+		t.Error("unlocked buffer should still hold data")
+	}
+}
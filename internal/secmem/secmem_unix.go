@@ -0,0 +1,42 @@
+//go:build unix
+
+package secmem
+
+import (
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// alloc maps whole pages for size bytes, outside the Go heap, whose freed memory is
+// reused without being zeroed.
+func alloc(size int) ([]byte, error) {
+	page := os.Getpagesize()
+	n := (size + page - 1) / page * page
+	mem, err := unix.Mmap(-1, 0, n, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_ANON|unix.MAP_PRIVATE)
+	if err != nil {
+		return nil, err
+	}
+	excludeFromDump(mem)
+	return mem, nil
+}
+
+func free(mem []byte) error {
+	return unix.Munmap(mem)
+}
+
+// lock fails with ENOMEM or EPERM once RLIMIT_MEMLOCK is used up.
+func lock(mem []byte) error {
+	return unix.Mlock(mem)
+}
+
+func unlock(mem []byte) error {
+	return unix.Munlock(mem)
+}
+
+func disableCoreDumps() error {
+	if err := unix.Setrlimit(unix.RLIMIT_CORE, &unix.Rlimit{Cur: 0, Max: 0}); err != nil {
+		return err
+	}
+	return setNotDumpable()
+}
@@ -0,0 +1,158 @@
+package selfheal
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+)
+
+// vaultDirs pairs each encrypted directory with the associated data kind of its files
+// and whether they are stored as chunked streams.
+var vaultDirs = []struct {
+	kind         string
+	getDir       func() (string, error)
+	stream       bool
+	manifestPath func(id string) string
+}{
+	{crypto.KindItem, storage.GetItemsDir, false, storage.ManifestItemPath},
+	{crypto.KindBlob, storage.GetStorageDir, true, storage.ManifestBlobPath},
+}
+
+// UpgradeLegacyEnvelopes re-encrypts items and storage blobs that still use the
+// legacy headerless ciphertext format, or an envelope not yet bound to its item ID.
+// Storage blobs written as a single GCM message are converted to chunked streams.
+// Each file is rewritten in place via tmp + rename. Returns the number of files upgraded.
+// Manifest entries follow only files the manifest vouched for before the upgrade; any
+// other mismatch is left for fsck to report. Once every file is bound, the vault is
+// recorded as bound and unbound files are refused from then on (see storage.IsVaultBound):
+// an unbound file in a bound vault was put there by someone else and is not rebound.
+// A file that fails to upgrade doesn't stop the others; the failures are reported together.
+func UpgradeLegacyEnvelopes(key []byte) (int, error) {
+	upgraded := 0
+	complete := true
+	var vouched, failed []string
+	bound := storage.IsVaultBound(key)
+
+	for _, vd := range vaultDirs {
+		dir, err := vd.getDir()
+		if err != nil {
+			return upgraded, err
+		}
+
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return upgraded, fmt.Errorf("failed to read %s: %w", dir, err)
+		}
+
+		for _, entry := range entries {
+			if entry.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+
+			stale, unbound, err := needsUpgrade(path, vd.stream)
+			if err != nil {
+				complete = false
+				continue
+			}
+			if !stale {
+				continue
+			}
+			if unbound && bound {
+				failed = append(failed, fmt.Sprintf("%s: unbound file in a bound vault (run 'dredge fsck')", entry.Name()))
+				continue
+			}
+
+			manifestPath := vd.manifestPath(entry.Name())
+			tracked := storage.ManifestVouchesFor(key, manifestPath)
+			if err := upgradeFile(path, key, crypto.AssociatedData(vd.kind, entry.Name()), vd.stream); err != nil {
+				complete = false
+				failed = append(failed, fmt.Sprintf("%s: %v", entry.Name(), err))
+				continue
+			}
+			if tracked {
+				vouched = append(vouched, manifestPath)
+			}
+			upgraded++
+		}
+	}
+
+	if len(vouched) > 0 {
+		if err := storage.UpdateVaultManifest(key, vouched...); err != nil {
+			return upgraded, fmt.Errorf("failed to update vault manifest: %w", err)
+		}
+	}
+	if complete {
+		if err := storage.MarkVaultBound(key); err != nil {
+			return upgraded, err
+		}
+	}
+	if len(failed) > 0 {
+		return upgraded, fmt.Errorf("failed to upgrade %d files (%s)", len(failed), strings.Join(failed, "; "))
+	}
+	return upgraded, nil
+}
+
+// needsUpgrade reads only the leading bytes of path and reports whether the file is
+// headerless, its envelope does not bind the item ID, or it should be a stream and is
+// not; unbound is set for the first two.
+func needsUpgrade(path string, stream bool) (stale, unbound bool, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, false, err
+	}
+	defer f.Close()
+
+	prefix := make([]byte, crypto.HeaderSize)
+	n, err := io.ReadFull(f, prefix)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false, false, err
+	}
+	header, ok := crypto.ParseHeader(prefix[:n])
+	unbound = !ok || !header.IsBound()
+	return unbound || header.IsStream() != stream, unbound, nil
+}
+
+// upgradeFile decrypts a legacy or unbound file and writes it back in the current
+// envelope format (a chunked stream when stream is set), bound to ad.
+func upgradeFile(path string, key []byte, ad []byte, stream bool) error {
+	encrypted, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	plaintext, err := storage.OpenPayload(encrypted, key, ad)
+	if err != nil {
+		return err
+	}
+
+	var upgraded []byte
+	if stream {
+		var buf bytes.Buffer
+		if _, err := storage.SealStream(&buf, bytes.NewReader(plaintext), key, ad); err != nil {
+			return err
+		}
+		upgraded = buf.Bytes()
+	} else if upgraded, err = storage.SealPayload(plaintext, key, ad); err != nil {
+		return err
+	}
+
+	tmpPath := path + ".tmp"
+	if err := os.WriteFile(tmpPath, upgraded, 0600); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
@@ -1,6 +1,12 @@
 package selfheal
 
-import "github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+import (
+	"fmt"
+	"os"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+)
 
 // Run performs silent health checks and cleanup once per session
 func Run() {
@@ -22,3 +28,16 @@ func Run() {
 		_ = storage.RemoveSpawnedFile(id)
 	}
 }
+
+// RunUnlocked performs health checks that need the master key.
+// Called once right after the vault is unlocked by password.
+func RunUnlocked(key []byte) {
+	// Upgrade legacy headerless ciphertexts to the versioned envelope format
+	upgraded, err := UpgradeLegacyEnvelopes(key)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: envelope upgrade incomplete: %v\n", err)
+	}
+	if upgraded > 0 {
+		fmt.Fprintf(os.Stderr, "Upgraded %d vault files to envelope format v%d\n", upgraded, crypto.EnvelopeVersion)
+	}
+}
@@ -26,10 +26,15 @@ func GetVaultPath() string {
 	return vaultPath
 }
 
+// BaseDir returns the per-user runtime directory holding every session directory
+// and the agent socket. Resolved per-platform by runtimeDir() (see session_*.go).
+func BaseDir() string {
+	return filepath.Join(runtimeDir(), "dredge")
+}
+
 // Dir returns the session-specific directory path.
-// The base runtime directory is resolved per-platform by runtimeDir() (see session_*.go).
 func Dir() string {
-	return filepath.Join(runtimeDir(), "dredge", fmt.Sprintf("%d", os.Getppid()))
+	return filepath.Join(BaseDir(), fmt.Sprintf("%d", os.Getppid()))
 }
 
 func ensureDir() error {
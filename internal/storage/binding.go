@@ -0,0 +1,118 @@
+package storage
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+)
+
+// ============================================================================
+// Bound vaults
+// ============================================================================
+//
+// Items and blobs are bound to their kind and ID, so a file moved to another ID fails
+// to decrypt. Files written by older versions are unbound (or headerless) and still
+// open, until selfheal rewrites them bound on unlock. Once every file is bound that is
+// recorded in the vault manifest, under its MAC, and from then on unbound files are
+// refused: otherwise whoever can push to the remote could put an old unbound ciphertext
+// of one item (from git history) at another ID. A fresh clone reads it from the
+// manifest; each machine also keeps its own record outside the repo, like the manifest
+// high-water mark, so a remote that drops the manifest doesn't undo it.
+
+const boundDirName = "bound"
+
+// boundCache remembers IsVaultBound for one vault, so reading many items doesn't
+// re-read the manifest each time. Writing the manifest forgets it.
+var boundCache struct {
+	dir   string
+	bound bool
+}
+
+// IsVaultBound reports whether every item and blob of the active vault is bound to its
+// ID: this machine recorded it, or the manifest (authentic under key) says so.
+func IsVaultBound(key []byte) bool {
+	dredgeDir, err := GetDredgeDir()
+	if err != nil {
+		return false
+	}
+	if boundCache.dir == dredgeDir {
+		return boundCache.bound
+	}
+
+	bound := false
+	if path, err := getVaultStatePath(boundDirName); err == nil {
+		if _, err := os.Stat(path); err == nil {
+			bound = true
+		}
+	}
+	if !bound {
+		m, err := readVaultManifest()
+		bound = err == nil && m != nil && m.Bound && m.authentic(key)
+	}
+	boundCache.dir, boundCache.bound = dredgeDir, bound
+	return bound
+}
+
+// forgetVaultBound drops the cached IsVaultBound answer.
+func forgetVaultBound() {
+	boundCache.dir = ""
+}
+
+// MarkVaultBound records that every item and blob of the active vault is bound, on
+// this machine and in the vault manifest.
+func MarkVaultBound(key []byte) error {
+	defer forgetVaultBound()
+
+	path, err := getVaultStatePath(boundDirName)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), dirPermissions); err != nil {
+		return fmt.Errorf("failed to record bound vault: %w", err)
+	}
+	if err := os.WriteFile(path, nil, itemFilePermissions); err != nil {
+		return fmt.Errorf("failed to record bound vault: %w", err)
+	}
+	if err := markManifestBound(key); err != nil {
+		return fmt.Errorf("failed to record bound vault in the manifest: %w", err)
+	}
+	return nil
+}
+
+// isBoundFile reports whether the file at path has an envelope bound to its ID.
+func isBoundFile(path string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+
+	prefix := make([]byte, crypto.HeaderSize)
+	n, err := io.ReadFull(f, prefix)
+	if err != nil && err != io.ErrUnexpectedEOF {
+		return false, err
+	}
+	header, ok := crypto.ParseHeader(prefix[:n])
+	return ok && header.IsBound(), nil
+}
+
+// OpenPayload decrypts an item (or single-message blob) payload bound to ad. Unbound
+// payloads are only accepted while the vault is not yet all bound.
+func OpenPayload(encrypted []byte, key []byte, ad []byte) ([]byte, error) {
+	if IsVaultBound(key) {
+		return crypto.DecryptBound(encrypted, key, ad)
+	}
+	return crypto.DecryptWithAD(encrypted, key, ad)
+}
+
+// OpenStream returns a reader over the plaintext of a blob bound to ad, with the same
+// rule as OpenPayload.
+func OpenStream(src io.Reader, key []byte, ad []byte) (io.Reader, error) {
+	if IsVaultBound(key) {
+		return crypto.NewBoundDecryptReader(src, key, ad)
+	}
+	return crypto.NewDecryptReader(src, key, ad)
+}
@@ -0,0 +1,116 @@
+package storage
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/totp"
+)
+
+// ============================================================================
+// Item fields
+// ============================================================================
+//
+// Text items can carry named fields (username, password, url...) next to their free
+// text, which stays the item's notes. Fields are part of the content: they are encrypted
+// with the item, kept in its history, and sealed with the text when the item is
+// double-locked. Names are matched case-insensitively and are unique per item.
+
+// FieldType says how a field's value is treated.
+type FieldType string
+
+const (
+	FieldText   FieldType = "text"   // shown as is
+	FieldSecret FieldType = "secret" // masked in view unless asked for
+	FieldOTP    FieldType = "otp"    // a TOTP seed (otpauth:// URI or base32), masked like a secret
+)
+
+// Field is a named value of a text item.
+type Field struct {
+	Name  string    `toml:"name"`
+	Type  FieldType `toml:"type"`
+	Value string    `toml:"value"`
+}
+
+// IsSecret reports whether the field's value is masked by default.
+func (f *Field) IsSecret() bool {
+	return f.Type == FieldSecret || f.Type == FieldOTP
+}
+
+// ParseFieldType parses a field type name.
+func ParseFieldType(s string) (FieldType, error) {
+	switch t := FieldType(strings.ToLower(s)); t {
+	case FieldText, FieldSecret, FieldOTP:
+		return t, nil
+	}
+	return "", fmt.Errorf("unknown field type %q (use text, secret or otp)", s)
+}
+
+// ValidFieldName reports whether name can name a field: letters, digits, '_', '-' and
+// '.', so it reads as one word on the command line and in the editor template.
+func ValidFieldName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+		case r == '_' || r == '-' || r == '.':
+		default:
+			return false
+		}
+	}
+	return true
+}
+
+// Field returns the item's field named name (any case), or nil.
+func (i *Item) Field(name string) *Field {
+	return FindField(i.Content.Fields, name)
+}
+
+// FindField returns the field named name (any case) in fields, or nil.
+func FindField(fields []Field, name string) *Field {
+	for k := range fields {
+		if strings.EqualFold(fields[k].Name, name) {
+			return &fields[k]
+		}
+	}
+	return nil
+}
+
+// FieldNames lists the names of the item's fields, in order.
+func (i *Item) FieldNames() []string {
+	names := make([]string, len(i.Content.Fields))
+	for k, f := range i.Content.Fields {
+		names[k] = f.Name
+	}
+	return names
+}
+
+// ValidateFields checks a list of fields: valid names and types, no name twice,
+// one-line values, and usable seeds in otp fields (or none yet).
+func ValidateFields(fields []Field) error {
+	seen := make(map[string]bool, len(fields))
+	for _, f := range fields {
+		if !ValidFieldName(f.Name) {
+			return fmt.Errorf("invalid field name %q", f.Name)
+		}
+		if _, err := ParseFieldType(string(f.Type)); err != nil {
+			return fmt.Errorf("field %s: %w", f.Name, err)
+		}
+		if strings.ContainsAny(f.Value, "\r\n") {
+			return fmt.Errorf("field %s: value spans several lines (keep long text in the notes)", f.Name)
+		}
+		if f.Type == FieldOTP && f.Value != "" {
+			if _, err := totp.Parse(f.Value); err != nil {
+				return fmt.Errorf("field %s: %w", f.Name, err)
+			}
+		}
+		lower := strings.ToLower(f.Name)
+		if seen[lower] {
+			return fmt.Errorf("field %s appears twice", f.Name)
+		}
+		seen[lower] = true
+	}
+	return nil
+}
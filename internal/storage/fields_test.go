@@ -0,0 +1,100 @@
+package storage
+
+import (
+	"slices"
+	"testing"
+)
+
+var testFields = []Field{
+	{Name: "username", Type: FieldText, Value: "luar"},
+	{Name: "password", Type: FieldSecret, Value: "hunter2"},
+}
+
+func TestItemFields_RoundTrip(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	item := NewTextItem("GitHub", "recovery codes", []string{"web"})
+	item.Content.Fields = slices.Clone(testFields)
+	if err := CreateItem("aaa", item, testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+
+	got, err := ReadItemStrict("aaa", testKey)
+	if err != nil {
+		t.Fatalf("ReadItemStrict failed: %v", err)
+	}
+	if !slices.Equal(got.Content.Fields, testFields) {
+		t.Errorf("fields = %+v, want %+v", got.Content.Fields, testFields)
+	}
+	if f := got.Field("PASSWORD"); f == nil || f.Value != "hunter2" || !f.IsSecret() {
+		t.Errorf("Field(PASSWORD) = %+v", f)
+	}
+	if got.Field("url") != nil {
+		t.Error("Field(url) should be nil")
+	}
+
+	// Changing only a field is a new revision
+	withHistoryLimit(t, 5)
+	got.Content.Fields[1].Value = "correct horse"
+	if err := UpdateItem("aaa", got, testKey); err != nil {
+		t.Fatalf("UpdateItem failed: %v", err)
+	}
+	got, _ = ReadItem("aaa", testKey)
+	if len(got.History) != 1 || got.History[0].Fields[1].Value != "hunter2" {
+		t.Errorf("history = %+v, want the old password kept", got.History)
+	}
+}
+
+func TestLockItem_SealsFields(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	item := NewTextItem("GitHub", "", nil)
+	item.Content.Fields = slices.Clone(testFields)
+	if err := CreateItem("aaa", item, testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	item, _ = ReadItem("aaa", testKey)
+	if err := LockItem("aaa", item, "item-password", testKey); err != nil {
+		t.Fatalf("LockItem failed: %v", err)
+	}
+
+	locked, err := ReadItemStrict("aaa", testKey)
+	if err != nil {
+		t.Fatalf("ReadItemStrict failed: %v", err)
+	}
+	if len(locked.Content.Fields) != 0 || locked.Lock.SealedFields == "" {
+		t.Fatalf("locked item = %+v, want fields sealed", locked)
+	}
+	if _, err := OpenContent(locked, "item-password"); err != nil {
+		t.Fatalf("OpenContent failed: %v", err)
+	}
+	if !slices.Equal(locked.Content.Fields, testFields) {
+		t.Errorf("opened fields = %+v, want %+v", locked.Content.Fields, testFields)
+	}
+}
+
+func TestValidateFields(t *testing.T) {
+	valid := append(slices.Clone(testFields),
+		Field{Name: "otp", Type: FieldOTP, Value: "otpauth://totp/GitHub:luar?secret=JBSWY3DPEHPK3PXP"},
+		Field{Name: "backup-otp", Type: FieldOTP}, // not filled in yet
+	)
+	if err := ValidateFields(valid); err != nil {
+		t.Errorf("ValidateFields(valid) = %v", err)
+	}
+	if !valid[2].IsSecret() {
+		t.Error("otp fields should be masked like secrets")
+	}
+	for _, bad := range [][]Field{
+		{{Name: "user name", Type: FieldText}},
+		{{Name: "pin", Type: "number"}},
+		{{Name: "key", Type: FieldSecret, Value: "line1\nline2"}},
+		{{Name: "user", Type: FieldText}, {Name: "USER", Type: FieldText}},
+		{{Name: "otp", Type: FieldOTP, Value: "not a seed!"}},
+	} {
+		if err := ValidateFields(bad); err == nil {
+			t.Errorf("ValidateFields(%+v) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,169 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/secmem"
+)
+
+// ============================================================================
+// Item history
+// ============================================================================
+//
+// UpdateItem keeps the states a text item had before, inside the item itself: they are
+// encrypted with it and follow it through mv, trash, rewrite, passwd and rekey without
+// any bookkeeping of their own. Revisions are numbered in order and never renumbered, so
+// a number seen in `dredge history` stays valid until it ages out. Binary items keep no
+// history; their old blobs are only in git.
+//
+// When an item becomes double-locked its history is dropped: the old revisions would
+// keep its content readable with the vault key alone. Revisions of a locked item are
+// sealed like the item (under the item password of their time).
+
+// HistoryLimit is how many earlier revisions UpdateItem keeps per item (set from main,
+// from the vault's config). 0 keeps none; older revisions are dropped on the next change.
+var HistoryLimit int
+
+// Revision is an earlier state of a text item.
+type Revision struct {
+	Rev      int       `toml:"rev"`
+	Modified time.Time `toml:"modified"` // when this state was written
+	Title    string    `toml:"title"`
+	Tags     []string  `toml:"tags,omitempty"`
+	Text     string    `toml:"text,omitempty"`
+	Fields   []Field   `toml:"fields,omitempty"`
+	Lock     *ItemLock `toml:"lock,omitempty"`
+}
+
+// IsLocked reports whether the revision's content is double-locked.
+func (r *Revision) IsLocked() bool {
+	return r.Lock != nil
+}
+
+// Revision returns the revision numbered rev.
+func (i *Item) Revision(rev int) (*Revision, error) {
+	for k := range i.History {
+		if i.History[k].Rev == rev {
+			return &i.History[k], nil
+		}
+	}
+	return nil, fmt.Errorf("no revision %d", rev)
+}
+
+// Restore sets the item's title, tags, text and fields to those of r. Like any change it
+// is saved with UpdateItem, which keeps the current state as a revision first.
+func (i *Item) Restore(r *Revision) {
+	i.Title = r.Title
+	i.Tags = slices.Clone(r.Tags)
+	i.Content.Text = r.Text
+	i.Content.Fields = slices.Clone(r.Fields)
+	i.Lock = r.Lock
+}
+
+// revisionOf captures the item's current state as revision rev.
+func revisionOf(item *Item, rev int) Revision {
+	return Revision{
+		Rev:      rev,
+		Modified: item.Modified,
+		Title:    item.Title,
+		Tags:     slices.Clone(item.Tags),
+		Text:     item.Content.Text,
+		Fields:   slices.Clone(item.Content.Fields),
+		Lock:     item.Lock,
+	}
+}
+
+// SameContent reports whether two items have the same title, tags, text and fields.
+// Double-locked items must be opened to compare their content: every seal differs.
+func SameContent(a, b *Item) bool {
+	return a.Title == b.Title && slices.Equal(a.Tags, b.Tags) && a.Content.Text == b.Content.Text &&
+		slices.Equal(a.Content.Fields, b.Content.Fields)
+}
+
+// sameState reports whether saving item over old would change anything a revision keeps.
+func sameState(item, old *Item) bool {
+	if !SameContent(item, old) || item.IsLocked() != old.IsLocked() {
+		return false
+	}
+	return !item.IsLocked() || (item.Lock.Sealed == old.Lock.Sealed && item.Lock.SealedFields == old.Lock.SealedFields)
+}
+
+// recordRevision sets the history of item, about to replace old on disk: old's history,
+// plus old's state if item changes it, trimmed to HistoryLimit.
+func recordRevision(item, old *Item) {
+	history := slices.Clone(old.History)
+	switch {
+	case item.Type != TypeText:
+		history = nil
+	case item.IsLocked() && !old.IsLocked():
+		history = nil
+	case !sameState(item, old):
+		next := 1
+		if n := len(history); n > 0 {
+			next = history[n-1].Rev + 1
+		}
+		history = append(history, revisionOf(old, next))
+	}
+
+	if over := len(history) - HistoryLimit; over > 0 {
+		history = history[over:]
+	}
+	if len(history) == 0 {
+		history = nil
+	}
+	item.History = history
+}
+
+// validateHistory checks the revisions of a decoded item.
+func (i *Item) validateHistory() error {
+	if len(i.History) > 0 && i.Type != TypeText {
+		return fmt.Errorf("%s item has revisions", i.Type)
+	}
+	last := 0
+	for _, r := range i.History {
+		if r.Rev <= last {
+			return fmt.Errorf("revision %d is out of order", r.Rev)
+		}
+		last = r.Rev
+		if r.IsLocked() && (r.Text != "" || len(r.Fields) > 0) {
+			return fmt.Errorf("double-locked revision %d has plaintext content", r.Rev)
+		}
+		if err := ValidateFields(r.Fields); err != nil {
+			return fmt.Errorf("revision %d: %w", r.Rev, err)
+		}
+	}
+	return nil
+}
+
+// readItemFile decrypts and decodes items/id as it is on disk, without syncing a linked
+// spawned file first (UpdateItem is what that sync calls).
+func readItemFile(id string, key []byte) (*Item, error) {
+	itemPath, err := GetItemPath(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item path: %w", err)
+	}
+	encryptedData, err := os.ReadFile(itemPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("item '%s' not found", id)
+		}
+		return nil, fmt.Errorf("failed to read item file: %w", err)
+	}
+
+	data, err := OpenPayload(encryptedData, key, crypto.AssociatedData(crypto.KindItem, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt item: %w", err)
+	}
+	defer secmem.Wipe(data)
+
+	var item Item
+	if err := toml.Unmarshal(data, &item); err != nil {
+		return nil, fmt.Errorf("failed to decode TOML: %w", err)
+	}
+	return &item, nil
+}
@@ -0,0 +1,168 @@
+package storage
+
+import (
+	"fmt"
+	"testing"
+)
+
+// withHistoryLimit sets HistoryLimit for one test.
+func withHistoryLimit(t *testing.T, n int) {
+	old := HistoryLimit
+	HistoryLimit = n
+	t.Cleanup(func() { HistoryLimit = old })
+}
+
+func TestUpdateItem_KeepsRevisions(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+	withHistoryLimit(t, 3)
+
+	if err := CreateItem("aaa", NewTextItem("DB", "v0", []string{"db"}), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	for i := 1; i <= 4; i++ {
+		item, _ := ReadItem("aaa", testKey)
+		item.Content.Text = fmt.Sprintf("v%d", i)
+		if err := UpdateItem("aaa", item, testKey); err != nil {
+			t.Fatalf("UpdateItem failed: %v", err)
+		}
+	}
+
+	// Saving without changes records nothing
+	item, _ := ReadItem("aaa", testKey)
+	if err := UpdateItem("aaa", item, testKey); err != nil {
+		t.Fatalf("UpdateItem failed: %v", err)
+	}
+
+	item, err := ReadItemStrict("aaa", testKey)
+	if err != nil {
+		t.Fatalf("ReadItemStrict failed: %v", err)
+	}
+	if len(item.History) != 3 {
+		t.Fatalf("kept %d revisions, want 3", len(item.History))
+	}
+	// v0 aged out; numbers are not reused
+	for k, want := range []string{"v1", "v2", "v3"} {
+		r := item.History[k]
+		if r.Rev != k+2 || r.Text != want {
+			t.Errorf("revision %d = #%d %q, want #%d %q", k, r.Rev, r.Text, k+2, want)
+		}
+	}
+
+	rev, err := item.Revision(2)
+	if err != nil {
+		t.Fatalf("Revision(2) failed: %v", err)
+	}
+	item.Restore(rev)
+	if err := UpdateItem("aaa", item, testKey); err != nil {
+		t.Fatalf("UpdateItem failed: %v", err)
+	}
+	item, _ = ReadItem("aaa", testKey)
+	if item.Content.Text != "v1" {
+		t.Errorf("restored content = %q, want v1", item.Content.Text)
+	}
+	if last := item.History[len(item.History)-1]; last.Rev != 5 || last.Text != "v4" {
+		t.Errorf("revert should keep the replaced state, got #%d %q", last.Rev, last.Text)
+	}
+	if _, err := item.Revision(1); err == nil {
+		t.Error("Revision(1) should have aged out")
+	}
+}
+
+func TestUpdateItem_NoHistory(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+	withHistoryLimit(t, 0)
+
+	if err := CreateItem("aaa", NewTextItem("DB", "v0", nil), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	item, _ := ReadItem("aaa", testKey)
+	item.Content.Text = "v1"
+	if err := UpdateItem("aaa", item, testKey); err != nil {
+		t.Fatalf("UpdateItem failed: %v", err)
+	}
+	item, _ = ReadItem("aaa", testKey)
+	if len(item.History) != 0 {
+		t.Errorf("history = 0 kept %d revisions", len(item.History))
+	}
+}
+
+func TestLockItem_DropsHistory(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+	withHistoryLimit(t, 10)
+
+	if err := CreateItem("aaa", NewTextItem("Root CA", "old key", nil), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	item, _ := ReadItem("aaa", testKey)
+	item.Content.Text = "new key"
+	if err := UpdateItem("aaa", item, testKey); err != nil {
+		t.Fatalf("UpdateItem failed: %v", err)
+	}
+
+	item, _ = ReadItem("aaa", testKey)
+	if err := LockItem("aaa", item, "item-password", testKey); err != nil {
+		t.Fatalf("LockItem failed: %v", err)
+	}
+	locked, err := ReadItemStrict("aaa", testKey)
+	if err != nil {
+		t.Fatalf("ReadItemStrict failed: %v", err)
+	}
+	if len(locked.History) != 0 {
+		t.Fatalf("locked item kept %d plaintext revisions", len(locked.History))
+	}
+
+	// Unlocking keeps the sealed state as a revision
+	if err := UnlockItem("aaa", locked, "item-password", testKey); err != nil {
+		t.Fatalf("UnlockItem failed: %v", err)
+	}
+	plain, err := ReadItemStrict("aaa", testKey)
+	if err != nil {
+		t.Fatalf("ReadItemStrict failed: %v", err)
+	}
+	if len(plain.History) != 1 || !plain.History[0].IsLocked() || plain.History[0].Text != "" {
+		t.Errorf("history after unlock = %+v, want one sealed revision", plain.History)
+	}
+}
+
+func TestSameContent_LockedItems(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	item := NewTextItem("Root CA", "key", []string{"pki"})
+	item.Content.Fields = []Field{{Name: "pin", Type: FieldSecret, Value: "1234"}}
+	if err := CreateItem("aaa", item, testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	item, _ = ReadItem("aaa", testKey)
+	if err := LockItem("aaa", item, "item-password", testKey); err != nil {
+		t.Fatalf("LockItem failed: %v", err)
+	}
+
+	// Two openings of the same sealed item have the same content...
+	a, _ := ReadItem("aaa", testKey)
+	b, _ := ReadItem("aaa", testKey)
+	for _, it := range []*Item{a, b} {
+		if _, err := OpenContent(it, "item-password"); err != nil {
+			t.Fatalf("OpenContent failed: %v", err)
+		}
+	}
+	if !SameContent(a, b) {
+		t.Error("SameContent should be true for the same opened item")
+	}
+
+	// ...though sealing them again never gives the same ciphertext
+	if _, err := SealContent(b, "item-password"); err != nil {
+		t.Fatalf("SealContent failed: %v", err)
+	}
+	if b.Lock.Sealed == a.Lock.Sealed {
+		t.Error("SealContent should use a fresh salt and nonce")
+	}
+
+	b.Content.Fields = []Field{{Name: "pin", Type: FieldSecret, Value: "4321"}}
+	if SameContent(a, b) {
+		t.Error("SameContent should see a changed field")
+	}
+}
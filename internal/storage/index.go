@@ -0,0 +1,309 @@
+package storage
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+)
+
+// ============================================================================
+// Metadata index
+// ============================================================================
+//
+// .dredge-index holds what list and search need from every item (title, tags, type,
+// timestamps, field names and the words of its text) in one encrypted file, so they decrypt once
+// instead of once per item. It is a local cache, never committed: each entry remembers
+// the size and mtime of items/<id> it was built from, and LoadIndex re-reads any item
+// whose file changed underneath it (git pull, another machine, a crashed write).
+
+const (
+	indexVersion = 3
+
+	// maxIndexTokenBytes caps the words kept per item, so one huge text item can't bloat
+	// the index. Entries past it are marked Truncated and search reads the item instead.
+	maxIndexTokenBytes = 64 << 10
+)
+
+// IndexEntry is the searchable metadata of one item.
+type IndexEntry struct {
+	Title    string    `json:"title"`
+	Tags     []string  `json:"tags,omitempty"`
+	Type     ItemType  `json:"type"`
+	Created  time.Time `json:"created"`
+	Modified time.Time `json:"modified"`
+
+	// Fields are the names of a text item's fields
+	Fields []string `json:"fields,omitempty"`
+
+	// Tokens are the distinct lowercased words of a text item's content (its text and the
+	// values of fields that are not secret). Search terms never contain whitespace, so a
+	// term is in the content exactly when it is in a token.
+	Tokens []string `json:"tokens,omitempty"`
+
+	// Truncated is set when Tokens holds only the first maxIndexTokenBytes of words, so
+	// content search has to read the item itself
+	Truncated bool `json:"truncated,omitempty"`
+
+	// Size and mtime (unix nanoseconds) of items/<id> when the entry was built
+	FileSize int64 `json:"file_size"`
+	FileMod  int64 `json:"file_mod"`
+}
+
+// Index maps item IDs to their metadata.
+type Index struct {
+	Version int                    `json:"version"`
+	Entries map[string]*IndexEntry `json:"entries"`
+
+	// Unreadable lists items that failed to decrypt while refreshing (not saved)
+	Unreadable []string `json:"-"`
+}
+
+// newIndexEntry builds the entry for item, stored at itemPath.
+func newIndexEntry(item *Item, itemPath string) (*IndexEntry, error) {
+	info, err := os.Stat(itemPath)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &IndexEntry{
+		Title:    item.Title,
+		Tags:     item.Tags,
+		Type:     item.Type,
+		Created:  item.Created,
+		Modified: item.Modified,
+		FileSize: info.Size(),
+		FileMod:  info.ModTime().UnixNano(),
+	}
+	if item.Type == TypeText {
+		entry.Fields = item.FieldNames()
+		entry.Tokens, entry.Truncated = contentTokens(SearchableText(item))
+	}
+	return entry, nil
+}
+
+// SearchableText is the content search looks at: the values of the item's fields that
+// are not secret, then its text.
+func SearchableText(item *Item) string {
+	var sb strings.Builder
+	for _, f := range item.Content.Fields {
+		if !f.IsSecret() {
+			sb.WriteString(f.Value)
+			sb.WriteString("\n")
+		}
+	}
+	sb.WriteString(item.Content.Text)
+	return sb.String()
+}
+
+// contentTokens returns the distinct lowercased words of text, in order of appearance,
+// up to maxIndexTokenBytes of them; truncated reports whether any were left out.
+func contentTokens(text string) (tokens []string, truncated bool) {
+	seen := make(map[string]bool)
+	size := 0
+	for _, word := range strings.Fields(strings.ToLower(text)) {
+		if seen[word] {
+			continue
+		}
+		if size += len(word); size > maxIndexTokenBytes {
+			return tokens, true
+		}
+		seen[word] = true
+		tokens = append(tokens, word)
+	}
+	return tokens, false
+}
+
+// matches reports whether the entry was built from the file described by info.
+func (e *IndexEntry) matches(info os.FileInfo) bool {
+	return e.FileSize == info.Size() && e.FileMod == info.ModTime().UnixNano()
+}
+
+// getIndexPath returns the path to .dredge-index
+func getIndexPath() (string, error) {
+	dredgeDir, err := GetDredgeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dredgeDir, indexFileName), nil
+}
+
+// LoadIndex returns the metadata of every item, decrypting only the items that changed
+// since the index was last saved. A missing or unreadable index is rebuilt from scratch.
+func LoadIndex(key []byte) (*Index, error) {
+	idx, err := readIndex(key)
+	if err != nil || idx == nil {
+		idx = &Index{Version: indexVersion, Entries: make(map[string]*IndexEntry)}
+	}
+
+	ids, err := ListItemIDs()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list items: %w", err)
+	}
+
+	changed := false
+	present := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		present[id] = true
+
+		itemPath, err := GetItemPath(id)
+		if err != nil {
+			return nil, err
+		}
+		info, err := os.Stat(itemPath)
+		if err != nil {
+			continue // Removed since ListItemIDs
+		}
+
+		// Linked items also go stale when their spawned file is edited; ReadItem syncs it
+		if entry, ok := idx.Entries[id]; ok && entry.matches(info) && !SpawnedFileChanged(id) {
+			continue
+		}
+
+		item, err := ReadItem(id, key)
+		if err != nil {
+			idx.Unreadable = append(idx.Unreadable, id)
+			if _, ok := idx.Entries[id]; ok {
+				delete(idx.Entries, id)
+				changed = true
+			}
+			continue
+		}
+		entry, err := newIndexEntry(item, itemPath)
+		if err != nil {
+			continue
+		}
+		idx.Entries[id] = entry
+		changed = true
+	}
+
+	for id := range idx.Entries {
+		if !present[id] {
+			delete(idx.Entries, id)
+			changed = true
+		}
+	}
+
+	if changed {
+		if err := writeIndex(idx, key); err != nil {
+			fmt.Fprintf(os.Stderr, "Warning: failed to save index: %v\n", err)
+		}
+	}
+	return idx, nil
+}
+
+// readIndex decrypts .dredge-index. Returns nil (no error) if there is none yet.
+func readIndex(key []byte) (*Index, error) {
+	indexPath, err := getIndexPath()
+	if err != nil {
+		return nil, err
+	}
+
+	encryptedData, err := os.ReadFile(indexPath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read index: %w", err)
+	}
+
+	data, err := crypto.DecryptWithAD(encryptedData, key, crypto.AssociatedData(crypto.KindIndex, ""))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt index: %w", err)
+	}
+
+	var idx Index
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("failed to parse index: %w", err)
+	}
+	if idx.Version != indexVersion || idx.Entries == nil {
+		return nil, fmt.Errorf("unsupported index version %d", idx.Version)
+	}
+	return &idx, nil
+}
+
+// writeIndex encrypts idx to .dredge-index (temp file + rename, so readers never see half of it)
+func writeIndex(idx *Index, key []byte) error {
+	indexPath, err := getIndexPath()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to encode index: %w", err)
+	}
+
+	encryptedData, err := crypto.EncryptWithAD(data, key, crypto.AssociatedData(crypto.KindIndex, ""))
+	if err != nil {
+		return fmt.Errorf("failed to encrypt index: %w", err)
+	}
+
+	tmpPath := indexPath + tmpFileExt
+	if err := os.WriteFile(tmpPath, encryptedData, itemFilePermissions); err != nil {
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	if err := os.Rename(tmpPath, indexPath); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write index: %w", err)
+	}
+	return nil
+}
+
+// updateIndex applies fn to the saved index, if there is one. The index is only a cache,
+// so failures are ignored: the next LoadIndex sees the stale entry and rebuilds it.
+func updateIndex(key []byte, fn func(entries map[string]*IndexEntry)) {
+	idx, err := readIndex(key)
+	if err != nil || idx == nil {
+		return
+	}
+	fn(idx.Entries)
+	_ = writeIndex(idx, key)
+}
+
+// indexPut records item (just written to items/<id>) in the index.
+func indexPut(id string, item *Item, key []byte) {
+	itemPath, err := GetItemPath(id)
+	if err != nil {
+		return
+	}
+	entry, err := newIndexEntry(item, itemPath)
+	if err != nil {
+		return
+	}
+	updateIndex(key, func(entries map[string]*IndexEntry) {
+		entries[id] = entry
+	})
+}
+
+// indexDelete drops id from the index.
+func indexDelete(id string, key []byte) {
+	updateIndex(key, func(entries map[string]*IndexEntry) {
+		delete(entries, id)
+	})
+}
+
+// indexMove moves oldID's entry to newID, whose file was just rewritten.
+func indexMove(oldID, newID string, key []byte) {
+	newPath, err := GetItemPath(newID)
+	if err != nil {
+		return
+	}
+	info, err := os.Stat(newPath)
+	if err != nil {
+		return
+	}
+	updateIndex(key, func(entries map[string]*IndexEntry) {
+		entry, ok := entries[oldID]
+		delete(entries, oldID)
+		if ok {
+			entry.FileSize = info.Size()
+			entry.FileMod = info.ModTime().UnixNano()
+			entries[newID] = entry
+		}
+	})
+}
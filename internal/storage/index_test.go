@@ -0,0 +1,153 @@
+package storage
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestLoadIndex_BuildsAndStaysInSync(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := CreateItem("aaa", NewTextItem("First", "Hello World hello", []string{"x"}), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+
+	// No index yet: built from scratch
+	idx, err := LoadIndex(testKey)
+	if err != nil {
+		t.Fatalf("LoadIndex failed: %v", err)
+	}
+	entry := idx.Entries["aaa"]
+	if entry == nil || entry.Title != "First" || !slices.Equal(entry.Tokens, []string{"hello", "world"}) {
+		t.Fatalf("entry = %+v, want title First and tokens [hello world]", entry)
+	}
+
+	// Writes go through to the saved index
+	if err := CreateItem("bbb", NewTextItem("Second", "two", nil), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	item, _ := ReadItem("aaa", testKey)
+	item.Title = "Renamed"
+	if err := UpdateItem("aaa", item, testKey); err != nil {
+		t.Fatalf("UpdateItem failed: %v", err)
+	}
+	if err := MoveItem("bbb", "ccc", testKey); err != nil {
+		t.Fatalf("MoveItem failed: %v", err)
+	}
+
+	saved, err := readIndex(testKey)
+	if err != nil || saved == nil {
+		t.Fatalf("readIndex = %v, %v", saved, err)
+	}
+	if len(saved.Entries) != 2 || saved.Entries["aaa"].Title != "Renamed" || saved.Entries["ccc"] == nil {
+		t.Errorf("saved index = %v, want aaa (Renamed) and ccc", saved.Entries)
+	}
+
+	if err := DeleteItem("ccc", testKey); err != nil {
+		t.Fatalf("DeleteItem failed: %v", err)
+	}
+	saved, _ = readIndex(testKey)
+	if _, ok := saved.Entries["ccc"]; ok {
+		t.Error("DeleteItem left its entry in the index")
+	}
+}
+
+func TestLoadIndex_RefreshesStaleEntries(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	for _, id := range []string{"aaa", "bbb"} {
+		if err := CreateItem(id, NewTextItem("Title "+id, "content", nil), testKey); err != nil {
+			t.Fatalf("CreateItem failed: %v", err)
+		}
+	}
+	if _, err := LoadIndex(testKey); err != nil {
+		t.Fatalf("LoadIndex failed: %v", err)
+	}
+
+	// Simulate a git pull: one item replaced and one removed behind the index's back
+	bbbPath, _ := GetItemPath("bbb")
+	os.Remove(bbbPath)
+	replacement, _ := ReadItem("aaa", testKey)
+	replacement.Title = "Pulled"
+	aaaPath, _ := GetItemPath("aaa")
+	before, _ := os.Stat(aaaPath)
+	writeItemBehindIndex(t, "aaa", replacement)
+	os.Chtimes(aaaPath, time.Now(), before.ModTime().Add(time.Second))
+
+	idx, err := LoadIndex(testKey)
+	if err != nil {
+		t.Fatalf("LoadIndex failed: %v", err)
+	}
+	if len(idx.Entries) != 1 || idx.Entries["aaa"].Title != "Pulled" {
+		t.Errorf("entries = %v, want only aaa titled Pulled", idx.Entries)
+	}
+}
+
+func TestLoadIndex_RebuildsUnreadableIndex(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := CreateItem("aaa", NewTextItem("Title", "content", nil), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	indexPath, _ := getIndexPath()
+	os.WriteFile(indexPath, []byte("not an index"), 0600)
+
+	idx, err := LoadIndex(testKey)
+	if err != nil {
+		t.Fatalf("LoadIndex failed: %v", err)
+	}
+	if idx.Entries["aaa"] == nil {
+		t.Error("LoadIndex did not rebuild a corrupted index")
+	}
+	if saved, err := readIndex(testKey); err != nil || saved == nil {
+		t.Errorf("rebuilt index was not saved: %v", err)
+	}
+}
+
+// writeItemBehindIndex replaces items/<id> the way git would, without touching the index.
+func writeItemBehindIndex(t *testing.T, id string, item *Item) {
+	t.Helper()
+	indexPath, _ := getIndexPath()
+	saved, _ := os.ReadFile(indexPath)
+	if err := UpdateItem(id, item, testKey); err != nil {
+		t.Fatalf("UpdateItem failed: %v", err)
+	}
+	os.WriteFile(indexPath, saved, 0600)
+}
+
+func TestLoadIndex_TruncatesHugeItems(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Distinct words of five bytes or more, past the cap in total
+	var sb strings.Builder
+	for i := 0; i < maxIndexTokenBytes/4; i++ {
+		fmt.Fprintf(&sb, "word%d ", i)
+	}
+	sb.WriteString("needle")
+	if err := CreateItem("big", NewTextItem("Big", sb.String(), nil), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	if err := CreateItem("small", NewTextItem("Small", "needle in a haystack", nil), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+
+	idx, err := LoadIndex(testKey)
+	if err != nil {
+		t.Fatalf("LoadIndex failed: %v", err)
+	}
+	big, small := idx.Entries["big"], idx.Entries["small"]
+	if !big.Truncated || slices.Contains(big.Tokens, "needle") {
+		t.Errorf("big entry: truncated = %v with %d tokens, want truncated before needle", big.Truncated, len(big.Tokens))
+	}
+	if small.Truncated || !slices.Contains(small.Tokens, "needle") {
+		t.Errorf("small entry = %+v, want all its words", small)
+	}
+}
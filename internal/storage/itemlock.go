@@ -0,0 +1,281 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/BurntSushi/toml"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/secmem"
+)
+
+// ============================================================================
+// Double-locked items
+// ============================================================================
+//
+// A locked item's content is sealed a second time under a key derived from its own
+// password, inside the normal item encryption. Title, tags and the other metadata stay
+// under the vault key only, so list and search still see them, but the content needs
+// the item password every time: an unlocked session is not enough. Binary items get
+// their blob wrapped the same way (an inner stream inside the outer one).
+//
+// The inner layer is bound to its kind, not the item ID: the outer layer already binds
+// the file to its ID, and this way mv does not need the item password.
+
+var (
+	lockedAD       = crypto.AssociatedData(crypto.KindLocked, "")
+	lockedFieldsAD = crypto.AssociatedData(crypto.KindLocked, "fields")
+)
+
+var errWrongItemPassword = errors.New("wrong item password")
+
+// ItemLock holds what is needed to open a double-locked item's content.
+type ItemLock struct {
+	KDFTime    uint32 `toml:"kdf_time"`
+	KDFMemory  uint32 `toml:"kdf_memory"` // KiB
+	KDFThreads uint8  `toml:"kdf_threads"`
+	Salt       string `toml:"salt"`   // base64
+	Sealed     string `toml:"sealed"` // base64: the text content (empty for binary items, then it only checks the password)
+
+	// SealedFields is the item's named fields, sealed like the text (base64 TOML; empty without fields)
+	SealedFields string `toml:"sealed_fields,omitempty"`
+}
+
+// lockedFields is how sealed fields are encoded.
+type lockedFields struct {
+	Fields []Field `toml:"fields"`
+}
+
+// IsLocked reports whether the item's content is double-locked.
+func (i *Item) IsLocked() bool {
+	return i.Lock != nil
+}
+
+func (l *ItemLock) params() crypto.KDFParams {
+	return crypto.KDFParams{Time: l.KDFTime, Memory: l.KDFMemory, Threads: l.KDFThreads}
+}
+
+// deriveKey derives the item key from password. The parameters come from the
+// (authenticated) item, and are validated so a bad one cannot exhaust memory.
+func (l *ItemLock) deriveKey(password string) ([]byte, error) {
+	params := l.params()
+	if err := params.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid item lock: %w", err)
+	}
+	salt, err := base64.StdEncoding.DecodeString(l.Salt)
+	if err != nil || len(salt) != crypto.SaltSize {
+		return nil, fmt.Errorf("invalid item lock: bad salt")
+	}
+	return crypto.DeriveKeyWithParams(password, salt, params), nil
+}
+
+// SealContent double-locks item's text content under password (fresh salt). The item
+// is only changed in memory; LockItem also wraps the blob and saves it.
+func SealContent(item *Item, password string) ([]byte, error) {
+	if password == "" {
+		return nil, fmt.Errorf("password cannot be empty")
+	}
+
+	salt := make([]byte, crypto.SaltSize)
+	if _, err := io.ReadFull(rand.Reader, salt); err != nil {
+		return nil, fmt.Errorf("failed to generate salt: %w", err)
+	}
+	params := crypto.DefaultKDFParams
+	itemKey := crypto.DeriveKeyWithParams(password, salt, params)
+
+	sealed, err := crypto.EncryptWithAD([]byte(item.Content.Text), itemKey, lockedAD)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt item content: %w", err)
+	}
+
+	lock := &ItemLock{
+		KDFTime:    params.Time,
+		KDFMemory:  params.Memory,
+		KDFThreads: params.Threads,
+		Salt:       base64.StdEncoding.EncodeToString(salt),
+		Sealed:     base64.StdEncoding.EncodeToString(sealed),
+	}
+	if len(item.Content.Fields) > 0 {
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(lockedFields{item.Content.Fields}); err != nil {
+			return nil, fmt.Errorf("failed to encode item fields: %w", err)
+		}
+		sealedFields, err := crypto.EncryptWithAD(buf.Bytes(), itemKey, lockedFieldsAD)
+		secmem.Wipe(buf.Bytes())
+		if err != nil {
+			return nil, fmt.Errorf("failed to encrypt item fields: %w", err)
+		}
+		lock.SealedFields = base64.StdEncoding.EncodeToString(sealedFields)
+	}
+
+	item.Lock = lock
+	item.Content.Text = ""
+	item.Content.Fields = nil
+	return itemKey, nil
+}
+
+// OpenContent checks password against a locked item and fills in its text content.
+// Returns the item key (needed to read a locked blob, see OpenLockedBlob).
+func OpenContent(item *Item, password string) ([]byte, error) {
+	if !item.IsLocked() {
+		return nil, fmt.Errorf("item is not double-locked")
+	}
+	itemKey, err := item.Lock.deriveKey(password)
+	if err != nil {
+		return nil, err
+	}
+	sealed, err := base64.StdEncoding.DecodeString(item.Lock.Sealed)
+	if err != nil {
+		return nil, fmt.Errorf("invalid item lock: bad content")
+	}
+	text, err := crypto.DecryptWithAD(sealed, itemKey, lockedAD)
+	if err != nil {
+		return nil, errWrongItemPassword
+	}
+	item.Content.Text = string(text)
+	secmem.Wipe(text)
+
+	if item.Lock.SealedFields != "" {
+		fields, err := openFields(item.Lock.SealedFields, itemKey)
+		if err != nil {
+			return nil, err
+		}
+		item.Content.Fields = fields
+	}
+	return itemKey, nil
+}
+
+// openFields decrypts and decodes sealed fields.
+func openFields(sealedFields string, itemKey []byte) ([]Field, error) {
+	sealed, err := base64.StdEncoding.DecodeString(sealedFields)
+	if err != nil {
+		return nil, fmt.Errorf("invalid item lock: bad fields")
+	}
+	data, err := crypto.DecryptWithAD(sealed, itemKey, lockedFieldsAD)
+	if err != nil {
+		return nil, fmt.Errorf("invalid item lock: failed to decrypt fields")
+	}
+	defer secmem.Wipe(data)
+
+	var decoded lockedFields
+	if err := toml.Unmarshal(data, &decoded); err != nil {
+		return nil, fmt.Errorf("invalid item lock: failed to decode fields: %w", err)
+	}
+	return decoded.Fields, nil
+}
+
+// OpenLockedBlob returns a reader over the content of a locked binary item's blob.
+func OpenLockedBlob(id string, key, itemKey []byte) (io.ReadCloser, error) {
+	blob, err := OpenStorageBlob(id, key)
+	if err != nil {
+		return nil, err
+	}
+	r, err := crypto.NewDecryptReader(blob, itemKey, lockedAD)
+	if err != nil {
+		blob.Close()
+		return nil, fmt.Errorf("failed to decrypt storage blob: %w", err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, blob}, nil
+}
+
+// LockItem double-locks items/id (and its blob) under password.
+func LockItem(id string, item *Item, password string, key []byte) error {
+	if item.IsLocked() {
+		return fmt.Errorf("item is already double-locked")
+	}
+	if IsLinked(id) {
+		return fmt.Errorf("item is linked (its plaintext is on disk) - run 'dredge unlink %s' first", id)
+	}
+
+	itemKey, err := SealContent(item, password)
+	if err != nil {
+		return err
+	}
+
+	if item.Type == TypeBinary {
+		err := rewrapBlob(id, key, func(dst io.Writer, src io.Reader) error {
+			_, err := crypto.EncryptStream(dst, src, itemKey, lockedAD)
+			return err
+		})
+		if err != nil {
+			return fmt.Errorf("failed to lock storage blob: %w", err)
+		}
+	}
+
+	if err := UpdateItem(id, item, key); err != nil {
+		if item.Type == TypeBinary {
+			_ = rewrapBlob(id, key, func(dst io.Writer, src io.Reader) error {
+				return openInner(dst, src, itemKey)
+			})
+		}
+		return err
+	}
+	return nil
+}
+
+// UnlockItem removes the double lock from items/id (and its blob) for good.
+func UnlockItem(id string, item *Item, password string, key []byte) error {
+	itemKey, err := OpenContent(item, password)
+	if err != nil {
+		return err
+	}
+
+	if item.Type == TypeBinary {
+		err := rewrapBlob(id, key, func(dst io.Writer, src io.Reader) error {
+			return openInner(dst, src, itemKey)
+		})
+		if err != nil {
+			return fmt.Errorf("failed to unlock storage blob: %w", err)
+		}
+	}
+
+	lock := item.Lock
+	item.Lock = nil
+	if err := UpdateItem(id, item, key); err != nil {
+		item.Lock = lock
+		if item.Type == TypeBinary {
+			_ = rewrapBlob(id, key, func(dst io.Writer, src io.Reader) error {
+				_, err := crypto.EncryptStream(dst, src, itemKey, lockedAD)
+				return err
+			})
+		}
+		return err
+	}
+	return nil
+}
+
+// openInner copies the plaintext of the inner stream src into dst.
+func openInner(dst io.Writer, src io.Reader, itemKey []byte) error {
+	r, err := crypto.NewDecryptReader(src, itemKey, lockedAD)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(dst, r)
+	return err
+}
+
+// rewrapBlob rewrites storage/id with its (outer) plaintext passed through transform.
+// The new blob goes to a temp file first, so the old one stays intact on failure.
+func rewrapBlob(id string, key []byte, transform func(dst io.Writer, src io.Reader) error) error {
+	blob, err := OpenStorageBlob(id, key)
+	if err != nil {
+		return err
+	}
+	defer blob.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(transform(pw, blob))
+	}()
+
+	_, err = WriteStorageBlobFrom(id, pr, key)
+	pr.CloseWithError(err)
+	return err
+}
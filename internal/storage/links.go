@@ -170,7 +170,7 @@ func syncItemIfNeeded(id string, key []byte) error {
 			if err != nil {
 				return err
 			}
-			decryptedData, err := crypto.Decrypt(encryptedData, key)
+			decryptedData, err := OpenPayload(encryptedData, key, crypto.AssociatedData(crypto.KindItem, id))
 			if err != nil {
 				return err
 			}
@@ -178,6 +178,10 @@ func syncItemIfNeeded(id string, key []byte) error {
 			if err := toml.Unmarshal(decryptedData, &item); err != nil {
 				return err
 			}
+			// A double-locked item's plaintext needs its password, which is never cached
+			if item.IsLocked() {
+				return fmt.Errorf("spawned file of double-locked item is missing (run 'dredge unlink %s' and link it again)", id)
+			}
 			if err := CreateSpawnedFile(id, item.Content.Text); err != nil {
 				return err
 			}
@@ -207,7 +211,7 @@ func syncItemIfNeeded(id string, key []byte) error {
 	// Raw read to avoid recursion (ReadItem calls syncItemIfNeeded)
 	itemPath, _ := GetItemPath(id)
 	encryptedData, _ := os.ReadFile(itemPath)
-	decryptedData, err := crypto.Decrypt(encryptedData, key)
+	decryptedData, err := OpenPayload(encryptedData, key, crypto.AssociatedData(crypto.KindItem, id))
 	if err != nil {
 		return err
 	}
@@ -217,10 +221,48 @@ func syncItemIfNeeded(id string, key []byte) error {
 		return err
 	}
 
+	// Edits to a double-locked item are resealed under its password by SaveLockedLinkEdits
+	if item.IsLocked() {
+		return nil
+	}
+
 	item.Content.Text = string(spawnedContent)
 	return UpdateItem(id, &item, key)
 }
 
+// SaveLockedLinkEdits saves edits made to the linked file of a double-locked item,
+// resealing them under password. They can't be synced on read like other items,
+// since the item password is never cached.
+func SaveLockedLinkEdits(id string, item *Item, password string, key []byte) error {
+	if !SpawnedFileChanged(id) {
+		return nil
+	}
+	if _, err := OpenContent(item, password); err != nil {
+		return err
+	}
+
+	spawnedPath, err := GetSpawnedPath(id)
+	if err != nil {
+		return err
+	}
+	spawnedContent, err := os.ReadFile(spawnedPath)
+	if err != nil {
+		return err
+	}
+
+	lock := item.Lock
+	item.Lock = nil
+	item.Content.Text = string(spawnedContent)
+	if _, err := SealContent(item, password); err != nil {
+		item.Lock = lock
+		return err
+	}
+	if err := UpdateItem(id, item, key); err != nil {
+		return err
+	}
+	return UpdateManifestHash(id)
+}
+
 // UpdateManifestHash recomputes and updates the hash for a linked item
 func UpdateManifestHash(id string) error {
 	manifest, err := LoadManifest()
@@ -244,6 +286,18 @@ func IsLinked(id string) bool {
 	return exists
 }
 
+// SpawnedFileChanged reports whether a linked item's spawned file was edited since
+// its last sync (the change is pulled into the vault on the next read)
+func SpawnedFileChanged(id string) bool {
+	manifest, _ := LoadManifest()
+	entry, exists := manifest[id]
+	if !exists {
+		return false
+	}
+	currentHash, err := hashSpawnedFile(id)
+	return err == nil && currentHash != entry.Hash
+}
+
 // GetLinkedPath returns the target path from manifest
 func GetLinkedPath(id string) (string, bool) {
 	manifest, _ := LoadManifest()
@@ -253,8 +307,9 @@ func GetLinkedPath(id string) (string, bool) {
 	return "", false
 }
 
-// Link creates a symlink from targetPath to .spawned/<id>
-func Link(id, targetPath string, force bool) error {
+// Link creates a symlink from targetPath to .spawned/<id> holding item's text.
+// A double-locked item must have been opened with OpenContent first.
+func Link(id string, item *Item, targetPath string, force bool) error {
 	manifest, err := LoadManifest()
 	if err != nil {
 		return err
@@ -264,16 +319,6 @@ func Link(id, targetPath string, force bool) error {
 		return fmt.Errorf("item %s already linked to %s", id, entry.Path)
 	}
 
-	key, err := crypto.GetKeyWithVerification()
-	if err != nil {
-		return err
-	}
-
-	item, err := ReadItem(id, key)
-	if err != nil {
-		return fmt.Errorf("failed to load item: %w", err)
-	}
-
 	if item.Type != TypeText {
 		return fmt.Errorf("cannot link binary items")
 	}
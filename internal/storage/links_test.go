@@ -359,3 +359,56 @@ func TestUpdateManifestHash_NotLinked(t *testing.T) {
 		t.Errorf("UpdateManifestHash() failed for non-linked item: %v", err)
 	}
 }
+
+func TestLink_LockedItemEdits(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := CreateItem("aaa", NewTextItem("Root CA", "old key", nil), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	item, _ := ReadItem("aaa", testKey)
+	if err := LockItem("aaa", item, "item-password", testKey); err != nil {
+		t.Fatalf("LockItem failed: %v", err)
+	}
+
+	item, _ = ReadItem("aaa", testKey)
+	if _, err := OpenContent(item, "item-password"); err != nil {
+		t.Fatalf("OpenContent failed: %v", err)
+	}
+	target := filepath.Join(t.TempDir(), "ca.pem")
+	if err := Link("aaa", item, target, false); err != nil {
+		t.Fatalf("Link failed: %v", err)
+	}
+	if data, _ := os.ReadFile(target); string(data) != "old key" {
+		t.Fatalf("linked file = %q, want the opened content", data)
+	}
+
+	// Edits are not synced on read: that would need the item password
+	if err := os.WriteFile(target, []byte("new key"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	item, _ = ReadItem("aaa", testKey)
+	if !item.IsLocked() || item.Content.Text != "" {
+		t.Fatalf("read synced plaintext into a double-locked item: %+v", item)
+	}
+
+	if err := SaveLockedLinkEdits("aaa", item, "wrong", testKey); err == nil {
+		t.Error("SaveLockedLinkEdits accepted a wrong password")
+	}
+	item, _ = ReadItem("aaa", testKey)
+	if err := SaveLockedLinkEdits("aaa", item, "item-password", testKey); err != nil {
+		t.Fatalf("SaveLockedLinkEdits failed: %v", err)
+	}
+	if SpawnedFileChanged("aaa") {
+		t.Error("linked file still counts as edited after saving")
+	}
+
+	saved, _ := ReadItem("aaa", testKey)
+	if !saved.IsLocked() || saved.Content.Text != "" {
+		t.Fatalf("saved item = %+v, want it still double-locked", saved)
+	}
+	if _, err := OpenContent(saved, "item-password"); err != nil || saved.Content.Text != "new key" {
+		t.Errorf("OpenContent = %q, %v, want the edits", saved.Content.Text, err)
+	}
+}
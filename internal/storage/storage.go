@@ -3,6 +3,7 @@ package storage
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -11,6 +12,7 @@ import (
 
 	"github.com/BurntSushi/toml"
 	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/secmem"
 )
 
 const (
@@ -32,8 +34,10 @@ const (
 	// File names
 	activeFileName    = "active"
 	linksFileName     = "links.json"
+	indexFileName     = ".dredge-index"
 	gitignoreFileName = ".gitignore"
 	itemFileExt       = ""
+	tmpFileExt        = ".tmp" // Written then renamed into place
 
 	// Permissions
 	dirPermissions       = 0700 // rwx------
@@ -41,7 +45,7 @@ const (
 	gitignorePermissions = 0644 // rw-r--r--
 
 	// Gitignore content
-	gitignoreContent = ".spawned/\nlinks.json\n"
+	gitignoreContent = ".spawned/\nlinks.json\n.dredge-index\n"
 )
 
 var (
@@ -49,6 +53,19 @@ var (
 	vaultOverride   string
 )
 
+// Padding pads items and blobs to size buckets when they are written (set from main,
+// from the vault's config). Reading handles both, so it can change at any time.
+var Padding bool
+
+// Compression compresses items and blobs before encryption where that makes them
+// smaller (set from main, from the vault's config). Like Padding, it can change at any time.
+var Compression bool
+
+// payloadOptions returns the write settings for items and blobs.
+func payloadOptions() crypto.PayloadOptions {
+	return crypto.PayloadOptions{Pad: Padding, Compress: Compression}
+}
+
 // SetVaultOverride sets a process-local vault directory override (empty string clears it).
 func SetVaultOverride(path string) {
 	vaultOverrideMu.Lock()
@@ -83,11 +100,20 @@ type Item struct {
 	Mode     *uint32 `toml:"mode,omitempty"`
 
 	Content ItemContent `toml:"content"`
+
+	// Lock is set on double-locked items, whose content is sealed inside it (see itemlock.go)
+	Lock *ItemLock `toml:"lock,omitempty"`
+
+	// History holds earlier states of text items, oldest first (see history.go)
+	History []Revision `toml:"history,omitempty"`
 }
 
 // ItemContent represents the content section of an item
 type ItemContent struct {
 	Text string `toml:"text"`
+
+	// Fields are named values of text items (see fields.go)
+	Fields []Field `toml:"fields,omitempty"`
 }
 
 // NewTextItem creates a new text item
@@ -222,34 +248,41 @@ func GetStoragePath(id string) (string, error) {
 
 // WriteStorageBlob encrypts and writes binary data to storage/id
 func WriteStorageBlob(id string, data []byte, key []byte) error {
+	_, err := WriteStorageBlobFrom(id, bytes.NewReader(data), key)
+	return err
+}
+
+// WriteStorageBlobFrom streams r into storage/id as a chunked ciphertext, so blobs of
+// any size are written in constant memory. The blob is written to a temp file and
+// renamed into place. Returns the number of plaintext bytes stored.
+func WriteStorageBlobFrom(id string, r io.Reader, key []byte) (int64, error) {
 	storageDir, err := GetStorageDir()
 	if err != nil {
-		return err
+		return 0, err
 	}
 	if err := os.MkdirAll(storageDir, dirPermissions); err != nil {
-		return fmt.Errorf("failed to create storage directory: %w", err)
-	}
-
-	encrypted, err := crypto.Encrypt(data, key)
-	if err != nil {
-		return fmt.Errorf("failed to encrypt storage blob: %w", err)
+		return 0, fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
 	blobPath := filepath.Join(storageDir, id)
-	if err := os.WriteFile(blobPath, encrypted, itemFilePermissions); err != nil {
-		return fmt.Errorf("failed to write storage blob: %w", err)
+	n, err := writeStreamFile(blobPath, r, key, crypto.AssociatedData(crypto.KindBlob, id))
+	if err != nil {
+		return n, fmt.Errorf("failed to write storage blob: %w", err)
 	}
-	return nil
+	updateVaultManifest(key, ManifestBlobPath(id))
+	return n, nil
 }
 
-// ReadStorageBlob decrypts and returns binary data from storage/id
-func ReadStorageBlob(id string, key []byte) ([]byte, error) {
+// OpenStorageBlob returns a reader over the decrypted content of storage/id.
+// Chunks are authenticated as they are read, so a tampered blob surfaces as a read
+// error part-way through; callers writing to disk should discard partial output.
+func OpenStorageBlob(id string, key []byte) (io.ReadCloser, error) {
 	blobPath, err := GetStoragePath(id)
 	if err != nil {
 		return nil, err
 	}
 
-	encrypted, err := os.ReadFile(blobPath)
+	f, err := os.Open(blobPath)
 	if err != nil {
 		if os.IsNotExist(err) {
 			return nil, fmt.Errorf("storage blob for '%s' not found", id)
@@ -257,15 +290,113 @@ func ReadStorageBlob(id string, key []byte) ([]byte, error) {
 		return nil, fmt.Errorf("failed to read storage blob: %w", err)
 	}
 
-	data, err := crypto.Decrypt(encrypted, key)
+	r, err := OpenStream(f, key, crypto.AssociatedData(crypto.KindBlob, id))
+	if err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to decrypt storage blob: %w", err)
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{r, f}, nil
+}
+
+// ReadStorageBlob decrypts and returns binary data from storage/id.
+// Loads the whole blob into memory; prefer OpenStorageBlob for large files.
+func ReadStorageBlob(id string, key []byte) ([]byte, error) {
+	r, err := OpenStorageBlob(id, key)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	data, err := io.ReadAll(r)
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt storage blob: %w", err)
 	}
 	return data, nil
 }
 
-// DeleteStorageBlob removes a binary blob from storage/; silent if missing
-func DeleteStorageBlob(id string) error {
+// SealPayload encrypts an item or single-message payload bound to ad, compressed and
+// padded as Compression and Padding say.
+func SealPayload(data []byte, key []byte, ad []byte) ([]byte, error) {
+	return crypto.EncryptPayload(data, key, ad, payloadOptions())
+}
+
+// SealStream encrypts src into dst as a chunked stream bound to ad, compressed and
+// padded as Compression and Padding say. Returns the number of plaintext bytes written.
+func SealStream(dst io.Writer, src io.Reader, key []byte, ad []byte) (int64, error) {
+	return crypto.EncryptStreamWith(dst, src, key, ad, payloadOptions())
+}
+
+// writeStreamFile encrypts r into path as a chunked stream bound to ad, via path.tmp + rename.
+func writeStreamFile(path string, r io.Reader, key []byte, ad []byte) (int64, error) {
+	tmpPath := path + tmpFileExt
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, itemFilePermissions)
+	if err != nil {
+		return 0, err
+	}
+
+	n, err := SealStream(f, r, key, ad)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+		return n, err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		_ = os.Remove(tmpPath)
+		return n, err
+	}
+	return n, nil
+}
+
+// StorageBlobSize decrypts storage/id end to end and returns its plaintext size.
+// Every chunk is authenticated, so a nil error means the whole blob is intact.
+func StorageBlobSize(id string, key []byte) (int64, error) {
+	r, err := OpenStorageBlob(id, key)
+	if err != nil {
+		return 0, err
+	}
+	defer r.Close()
+
+	n, err := io.Copy(io.Discard, r)
+	if err != nil {
+		return n, fmt.Errorf("failed to decrypt storage blob: %w", err)
+	}
+	return n, nil
+}
+
+// ListStorageIDs returns the IDs of all blobs in storage/ (temp files from interrupted
+// writes excluded).
+func ListStorageIDs() ([]string, error) {
+	storageDir, err := GetStorageDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(storageDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []string{}, nil
+		}
+		return nil, fmt.Errorf("failed to read storage directory: %w", err)
+	}
+
+	var ids []string
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasSuffix(entry.Name(), tmpFileExt) {
+			continue
+		}
+		ids = append(ids, entry.Name())
+	}
+	return ids, nil
+}
+
+// DeleteStorageBlob removes a binary blob from storage/; silent if missing (key updates the manifest)
+func DeleteStorageBlob(id string, key []byte) error {
 	blobPath, err := GetStoragePath(id)
 	if err != nil {
 		return err
@@ -273,6 +404,7 @@ func DeleteStorageBlob(id string) error {
 	if err := os.Remove(blobPath); err != nil && !os.IsNotExist(err) {
 		return fmt.Errorf("failed to delete storage blob: %w", err)
 	}
+	updateVaultManifest(key, ManifestBlobPath(id))
 	return nil
 }
 
@@ -361,9 +493,10 @@ func CreateItem(id string, item *Item, key []byte) error {
 	}
 
 	tomlData := buf.Bytes()
+	defer secmem.Wipe(tomlData)
 
 	// Encrypt the TOML data
-	encryptedData, err := crypto.Encrypt(tomlData, key)
+	encryptedData, err := SealPayload(tomlData, key, crypto.AssociatedData(crypto.KindItem, id))
 	if err != nil {
 		return fmt.Errorf("failed to encrypt item: %w", err)
 	}
@@ -372,6 +505,8 @@ func CreateItem(id string, item *Item, key []byte) error {
 		return fmt.Errorf("failed to write item file: %w", err)
 	}
 
+	indexPut(id, item, key)
+	updateVaultManifest(key, ManifestItemPath(id))
 	return nil
 }
 
@@ -398,10 +533,11 @@ func ReadItem(id string, key []byte) (*Item, error) {
 		return nil, fmt.Errorf("failed to read item file: %w", err)
 	}
 
-	data, err := crypto.Decrypt(encryptedData, key)
+	data, err := OpenPayload(encryptedData, key, crypto.AssociatedData(crypto.KindItem, id))
 	if err != nil {
 		return nil, fmt.Errorf("failed to decrypt item: %w", err)
 	}
+	defer secmem.Wipe(data)
 
 	var item Item
 	if err := toml.Unmarshal(data, &item); err != nil {
@@ -416,16 +552,97 @@ func ReadItem(id string, key []byte) (*Item, error) {
 	return &item, nil
 }
 
-// UpdateItem updates an existing item on disk (encrypted)
+// ReadItemStrict decrypts items/id without syncing linked files and decodes it strictly:
+// unknown TOML keys and invalid fields (see Validate) are errors. Used by fsck.
+func ReadItemStrict(id string, key []byte) (*Item, error) {
+	itemPath, err := GetItemPath(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get item path: %w", err)
+	}
+
+	encryptedData, err := os.ReadFile(itemPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read item file: %w", err)
+	}
+
+	data, err := OpenPayload(encryptedData, key, crypto.AssociatedData(crypto.KindItem, id))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt item: %w", err)
+	}
+	defer secmem.Wipe(data)
+
+	var item Item
+	meta, err := toml.Decode(string(data), &item)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode TOML: %w", err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return &item, fmt.Errorf("unknown key %q", undecoded[0].String())
+	}
+	return &item, item.Validate()
+}
+
+// Validate checks that the item has the fields its type requires.
+func (i *Item) Validate() error {
+	if strings.TrimSpace(i.Title) == "" {
+		return fmt.Errorf("missing title")
+	}
+	if i.Created.IsZero() || i.Modified.IsZero() {
+		return fmt.Errorf("missing created/modified timestamps")
+	}
+
+	switch i.Type {
+	case TypeText:
+		if i.Size != nil || i.Mode != nil {
+			return fmt.Errorf("text item has binary fields (size/mode)")
+		}
+		if err := ValidateFields(i.Content.Fields); err != nil {
+			return err
+		}
+	case TypeBinary:
+		if i.Filename == "" || i.Size == nil || i.Mode == nil {
+			return fmt.Errorf("binary item is missing filename, size or mode")
+		}
+		if *i.Size < 0 {
+			return fmt.Errorf("negative size %d", *i.Size)
+		}
+		if i.Content.Text != "" {
+			return fmt.Errorf("binary item has inline content")
+		}
+		if len(i.Content.Fields) > 0 {
+			return fmt.Errorf("binary item has named fields")
+		}
+	default:
+		return fmt.Errorf("unknown type %q", i.Type)
+	}
+
+	if i.Lock != nil {
+		if i.Content.Text != "" || len(i.Content.Fields) > 0 {
+			return fmt.Errorf("double-locked item has plaintext content")
+		}
+		if i.Lock.Salt == "" || i.Lock.Sealed == "" {
+			return fmt.Errorf("double-locked item is missing its salt or sealed content")
+		}
+		if err := i.Lock.params().Validate(); err != nil {
+			return fmt.Errorf("double-locked item: %w", err)
+		}
+	}
+	return i.validateHistory()
+}
+
+// UpdateItem updates an existing item on disk (encrypted). The state it replaces is
+// kept in the item's history (see recordRevision), whatever item.History says.
 func UpdateItem(id string, item *Item, key []byte) error {
 	itemPath, err := GetItemPath(id)
 	if err != nil {
 		return fmt.Errorf("failed to get item path: %w", err)
 	}
 
-	if _, err := os.Stat(itemPath); os.IsNotExist(err) {
-		return fmt.Errorf("item '%s' not found", id)
+	old, err := readItemFile(id, key)
+	if err != nil {
+		return err
 	}
+	recordRevision(item, old)
 
 	item.UpdateModified()
 
@@ -436,9 +653,10 @@ func UpdateItem(id string, item *Item, key []byte) error {
 	}
 
 	tomlData := buf.Bytes()
+	defer secmem.Wipe(tomlData)
 
 	// Encrypt the TOML data
-	encryptedData, err := crypto.Encrypt(tomlData, key)
+	encryptedData, err := SealPayload(tomlData, key, crypto.AssociatedData(crypto.KindItem, id))
 	if err != nil {
 		return fmt.Errorf("failed to encrypt item: %w", err)
 	}
@@ -457,11 +675,13 @@ func UpdateItem(id string, item *Item, key []byte) error {
 		}
 	}
 
+	indexPut(id, item, key)
+	updateVaultManifest(key, ManifestItemPath(id))
 	return nil
 }
 
-// DeleteItem removes an item from disk
-func DeleteItem(id string) error {
+// DeleteItem removes an item from disk (key updates the index)
+func DeleteItem(id string, key []byte) error {
 	itemPath, err := GetItemPath(id)
 	if err != nil {
 		return fmt.Errorf("failed to get item path: %w", err)
@@ -476,8 +696,183 @@ func DeleteItem(id string) error {
 	}
 
 	// Also remove storage blob if present (silent if missing)
-	_ = DeleteStorageBlob(id)
+	_ = DeleteStorageBlob(id, key)
+
+	indexDelete(id, key)
+	updateVaultManifest(key, ManifestItemPath(id))
+	return nil
+}
+
+// MoveItem renames an item (and its storage blob, if any) from oldID to newID.
+// Ciphertexts are bound to their ID, so both files are re-encrypted under newID
+// rather than renamed. Timestamps are preserved.
+func MoveItem(oldID, newID string, key []byte) error {
+	oldPath, err := GetItemPath(oldID)
+	if err != nil {
+		return fmt.Errorf("failed to get old item path: %w", err)
+	}
+	newPath, err := GetItemPath(newID)
+	if err != nil {
+		return fmt.Errorf("failed to get new item path: %w", err)
+	}
+
+	if _, err := os.Stat(newPath); err == nil {
+		return fmt.Errorf("item with ID '%s' already exists", newID)
+	}
+
+	oldBlobPath, err := GetStoragePath(oldID)
+	if err != nil {
+		return err
+	}
+	newBlobPath, err := GetStoragePath(newID)
+	if err != nil {
+		return err
+	}
+
+	// Blob first: an interrupted move leaves the old item intact and at worst a stray new blob
+	hasBlob := false
+	if _, err := os.Stat(oldBlobPath); err == nil {
+		hasBlob = true
+		if err := rebindFile(oldBlobPath, newBlobPath, key, crypto.KindBlob, oldID, newID); err != nil {
+			return fmt.Errorf("failed to move storage blob: %w", err)
+		}
+	}
+
+	if err := rebindFile(oldPath, newPath, key, crypto.KindItem, oldID, newID); err != nil {
+		if hasBlob {
+			_ = os.Remove(newBlobPath)
+		}
+		return fmt.Errorf("failed to move item: %w", err)
+	}
+
+	if err := os.Remove(oldPath); err != nil {
+		return fmt.Errorf("failed to remove old item file: %w", err)
+	}
+	if hasBlob {
+		_ = os.Remove(oldBlobPath) // Best-effort; the new blob is already in place
+	}
+
+	indexMove(oldID, newID, key)
+	updateVaultManifest(key, ManifestItemPath(oldID), ManifestItemPath(newID), ManifestBlobPath(oldID), ManifestBlobPath(newID))
+	return nil
+}
+
+// RewriteItem re-encrypts items/<id> in place with the current settings (Padding, Compression).
+// Returns false without touching the file if it is already written that way.
+func RewriteItem(id string, key []byte) (bool, error) {
+	itemPath, err := GetItemPath(id)
+	if err != nil {
+		return false, err
+	}
+	rewritten, err := rewriteFile(itemPath, key, crypto.KindItem, id)
+	if rewritten && err == nil {
+		updateVaultManifest(key, ManifestItemPath(id))
+	}
+	return rewritten, err
+}
+
+// RewriteStorageBlob is RewriteItem for storage/<id>.
+func RewriteStorageBlob(id string, key []byte) (bool, error) {
+	blobPath, err := GetStoragePath(id)
+	if err != nil {
+		return false, err
+	}
+	rewritten, err := rewriteFile(blobPath, key, crypto.KindBlob, id)
+	if rewritten && err == nil {
+		updateVaultManifest(key, ManifestBlobPath(id))
+	}
+	return rewritten, err
+}
+
+func rewriteFile(path string, key []byte, kind, id string) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return false, err
+	}
+	prefix := make([]byte, crypto.HeaderSize)
+	n, _ := io.ReadFull(f, prefix)
+	f.Close()
+
+	header, ok := crypto.ParseHeader(prefix[:n])
+	if ok && header.IsBound() && header.IsPadded() == Padding {
+		switch {
+		case header.IsCompressed() == Compression:
+			return false, nil
+		case Compression:
+			// Only files that compress get compressed, so an uncompressed one may be as good as it gets
+			return rewriteIfCompressed(path, key, kind, id)
+		}
+	}
+	return true, rebindFile(path, path, key, kind, id, id)
+}
+
+// rewriteIfCompressed re-encrypts path into a temp file and keeps it only if it came
+// out compressed.
+func rewriteIfCompressed(path string, key []byte, kind, id string) (bool, error) {
+	trialPath := path + tmpFileExt
+	if err := rebindFile(path, trialPath, key, kind, id, id); err != nil {
+		return false, err
+	}
+
+	f, err := os.Open(trialPath)
+	if err != nil {
+		return false, err
+	}
+	prefix := make([]byte, crypto.HeaderSize)
+	n, _ := io.ReadFull(f, prefix)
+	f.Close()
 
+	if header, _ := crypto.ParseHeader(prefix[:n]); !header.IsCompressed() {
+		return false, os.Remove(trialPath)
+	}
+	if err := os.Rename(trialPath, path); err != nil {
+		_ = os.Remove(trialPath)
+		return false, err
+	}
+	return true, nil
+}
+
+// rebindFile re-encrypts the file at src (bound to kind/oldID) into dst bound to kind/newID.
+// Storage blobs are streamed chunk by chunk.
+func rebindFile(src, dst string, key []byte, kind, oldID, newID string) error {
+	if kind == crypto.KindBlob {
+		f, err := os.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		r, err := OpenStream(f, key, crypto.AssociatedData(kind, oldID))
+		if err != nil {
+			return err
+		}
+		_, err = writeStreamFile(dst, r, key, crypto.AssociatedData(kind, newID))
+		return err
+	}
+
+	encrypted, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+
+	data, err := OpenPayload(encrypted, key, crypto.AssociatedData(kind, oldID))
+	if err != nil {
+		return err
+	}
+
+	rebound, err := SealPayload(data, key, crypto.AssociatedData(kind, newID))
+	if err != nil {
+		return err
+	}
+
+	tmpPath := dst + tmpFileExt
+	if err := os.WriteFile(tmpPath, rebound, itemFilePermissions); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, dst); err != nil {
+		_ = os.Remove(tmpPath)
+		return err
+	}
 	return nil
 }
 
@@ -500,6 +895,9 @@ func ListItemIDs() ([]string, error) {
 	extLen := len(itemFileExt)
 	for _, entry := range entries {
 		name := entry.Name()
+		if strings.HasSuffix(name, tmpFileExt) {
+			continue // interrupted write
+		}
 		if !entry.IsDir() && len(name) > extLen && name[len(name)-extLen:] == itemFileExt {
 			id := name[:len(name)-extLen]
 			ids = append(ids, id)
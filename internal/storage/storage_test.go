@@ -1,10 +1,15 @@
 package storage
 
 import (
+	"bytes"
+	"io"
+	"math/rand"
 	"os"
 	"path/filepath"
 	"testing"
 
+	"github.com/BurntSushi/toml"
+
 	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
 )
 
@@ -21,12 +26,15 @@ func setupTestEnv(t *testing.T) (cleanup func()) {
 		t.Fatalf("failed to create temp dir: %v", err)
 	}
 
-	// Set XDG_DATA_HOME to temp directory
+	// Set XDG_DATA_HOME and XDG_STATE_HOME (manifest high-water marks) to temp directory
 	oldXDG := os.Getenv("XDG_DATA_HOME")
 	os.Setenv("XDG_DATA_HOME", tmpDir)
+	oldState := os.Getenv("XDG_STATE_HOME")
+	os.Setenv("XDG_STATE_HOME", tmpDir)
 
 	return func() {
 		os.Setenv("XDG_DATA_HOME", oldXDG)
+		os.Setenv("XDG_STATE_HOME", oldState)
 		SetVaultOverride("")
 		os.RemoveAll(tmpDir)
 		_ = crypto.ClearSession()
@@ -237,7 +245,7 @@ func TestDeleteItem(t *testing.T) {
 	}
 
 	// Delete item
-	if err := DeleteItem("delete-test"); err != nil {
+	if err := DeleteItem("delete-test", testKey); err != nil {
 		t.Fatalf("DeleteItem() failed: %v", err)
 	}
 
@@ -334,3 +342,272 @@ func TestNewBinaryItem(t *testing.T) {
 		t.Errorf("Content = %q, want empty (binary content stored in storage/)", item.Content.Text)
 	}
 }
+
+func TestReadItem_SwappedFilesFail(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := CreateItem("aaa", NewTextItem("A", "alpha", nil), testKey); err != nil {
+		t.Fatalf("CreateItem(aaa) failed: %v", err)
+	}
+	if err := CreateItem("bbb", NewTextItem("B", "beta", nil), testKey); err != nil {
+		t.Fatalf("CreateItem(bbb) failed: %v", err)
+	}
+
+	// Swap the ciphertexts on disk, as someone with write access to the remote could
+	pathA, _ := GetItemPath("aaa")
+	pathB, _ := GetItemPath("bbb")
+	dataA, _ := os.ReadFile(pathA)
+	dataB, _ := os.ReadFile(pathB)
+	os.WriteFile(pathA, dataB, 0600)
+	os.WriteFile(pathB, dataA, 0600)
+
+	if _, err := ReadItem("aaa", testKey); err == nil {
+		t.Error("ReadItem should fail for a ciphertext moved from another ID")
+	}
+
+	// Item TOML placed where a storage blob is expected must not decrypt either
+	if err := WriteStorageBlob("aaa", []byte("blob"), testKey); err != nil {
+		t.Fatalf("WriteStorageBlob failed: %v", err)
+	}
+	blobPath, _ := GetStoragePath("aaa")
+	os.WriteFile(blobPath, dataA, 0600)
+	if _, err := ReadStorageBlob("aaa", testKey); err == nil {
+		t.Error("ReadStorageBlob should fail for an item ciphertext")
+	}
+}
+
+func TestReadItem_UnboundSubstitution(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := CreateItem("bbb", NewTextItem("B", "beta", nil), testKey); err != nil {
+		t.Fatalf("CreateItem(bbb) failed: %v", err)
+	}
+
+	// An unbound ciphertext of another item (as old git history has them) put at bbb
+	var plaintext bytes.Buffer
+	if err := toml.NewEncoder(&plaintext).Encode(NewTextItem("A", "alpha", nil)); err != nil {
+		t.Fatalf("toml encode failed: %v", err)
+	}
+	unbound, err := crypto.Encrypt(plaintext.Bytes(), testKey)
+	if err != nil {
+		t.Fatalf("Encrypt failed: %v", err)
+	}
+	pathB, _ := GetItemPath("bbb")
+	if err := os.WriteFile(pathB, unbound, 0600); err != nil {
+		t.Fatalf("WriteFile failed: %v", err)
+	}
+
+	// Not yet migrated (a vault from before binding has no bound manifest): unbound files still open
+	manifestPath, _ := getVaultManifestPath()
+	os.Remove(manifestPath)
+	forgetVaultBound()
+	if item, err := ReadItem("bbb", testKey); err != nil || item.Title != "A" {
+		t.Fatalf("ReadItem before the vault is bound = %v, %v", item, err)
+	}
+
+	if err := MarkVaultBound(testKey); err != nil {
+		t.Fatalf("MarkVaultBound failed: %v", err)
+	}
+	if !IsVaultBound(testKey) {
+		t.Fatal("IsVaultBound should be true once marked")
+	}
+	if _, err := ReadItem("bbb", testKey); err == nil {
+		t.Error("ReadItem should refuse an unbound ciphertext once the vault is bound")
+	}
+
+	// A fresh clone has no local record, but the manifest says the vault is bound
+	os.Setenv("XDG_STATE_HOME", t.TempDir())
+	forgetVaultBound()
+	if !IsVaultBound(testKey) {
+		t.Fatal("IsVaultBound should be true from the manifest alone")
+	}
+	if _, err := ReadItem("bbb", testKey); err == nil {
+		t.Error("ReadItem should refuse an unbound ciphertext in a fresh clone of a bound vault")
+	}
+
+	// Only the vault key can set the flag
+	forgetVaultBound()
+	if IsVaultBound(crypto.DeriveKey("other", []byte("16-byte-salt-val"))) {
+		t.Error("IsVaultBound should not trust a manifest that fails authentication")
+	}
+	forgetVaultBound()
+
+	// Bound files of course still open
+	if err := CreateItem("ccc", NewTextItem("C", "gamma", nil), testKey); err != nil {
+		t.Fatalf("CreateItem(ccc) failed: %v", err)
+	}
+	if _, err := ReadItem("ccc", testKey); err != nil {
+		t.Errorf("ReadItem(ccc) failed: %v", err)
+	}
+}
+
+func TestMoveItem(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	item := NewBinaryItem("key.pem", "key.pem", 4, 0600, nil)
+	if err := CreateItem("old", item, testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	if err := WriteStorageBlob("old", []byte("blob"), testKey); err != nil {
+		t.Fatalf("WriteStorageBlob failed: %v", err)
+	}
+
+	if err := MoveItem("old", "new", testKey); err != nil {
+		t.Fatalf("MoveItem failed: %v", err)
+	}
+
+	if exists, _ := ItemExists("old"); exists {
+		t.Error("old item should be gone after MoveItem")
+	}
+	moved, err := ReadItem("new", testKey)
+	if err != nil {
+		t.Fatalf("ReadItem(new) failed: %v", err)
+	}
+	if moved.Title != item.Title {
+		t.Errorf("Title = %q, want %q", moved.Title, item.Title)
+	}
+	blob, err := ReadStorageBlob("new", testKey)
+	if err != nil {
+		t.Fatalf("ReadStorageBlob(new) failed: %v", err)
+	}
+	if string(blob) != "blob" {
+		t.Errorf("blob = %q, want %q", blob, "blob")
+	}
+}
+
+func TestStorageBlob_Streaming(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	// Several chunks, generated on the fly rather than held in memory
+	const size = 5*crypto.StreamChunkSize + 123
+	src := io.LimitReader(rand.New(rand.NewSource(1)), size)
+
+	n, err := WriteStorageBlobFrom("big", src, testKey)
+	if err != nil {
+		t.Fatalf("WriteStorageBlobFrom failed: %v", err)
+	}
+	if n != size {
+		t.Errorf("WriteStorageBlobFrom wrote %d bytes, want %d", n, size)
+	}
+
+	blob, err := OpenStorageBlob("big", testKey)
+	if err != nil {
+		t.Fatalf("OpenStorageBlob failed: %v", err)
+	}
+	defer blob.Close()
+
+	want := io.LimitReader(rand.New(rand.NewSource(1)), size)
+	got, _ := io.ReadAll(blob)
+	wantBytes, _ := io.ReadAll(want)
+	if !bytes.Equal(got, wantBytes) {
+		t.Error("streamed blob does not round trip")
+	}
+
+	// Blobs written before streaming (one GCM message) still open
+	legacy, _ := crypto.EncryptWithAD([]byte("old"), testKey, crypto.AssociatedData(crypto.KindBlob, "old"))
+	blobPath, _ := GetStoragePath("old")
+	os.WriteFile(blobPath, legacy, 0600)
+	if data, err := ReadStorageBlob("old", testKey); err != nil || string(data) != "old" {
+		t.Errorf("ReadStorageBlob(old) = %q, %v; want %q", data, err, "old")
+	}
+}
+
+func TestReadItemStrict(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := CreateItem("ok", NewTextItem("Title", "content", nil), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	if _, err := ReadItemStrict("ok", testKey); err != nil {
+		t.Errorf("ReadItemStrict(ok) failed: %v", err)
+	}
+
+	// Decrypts fine but is not a valid item
+	bad := NewTextItem("", "content", nil)
+	if err := CreateItem("bad", bad, testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	if _, err := ReadItemStrict("bad", testKey); err == nil {
+		t.Error("ReadItemStrict accepted an item without a title")
+	}
+
+	// A binary item whose blob is missing its size
+	bin := NewBinaryItem("Bin", "file.bin", 10, 0644, nil)
+	bin.Size = nil
+	if err := bin.Validate(); err == nil {
+		t.Error("Validate accepted a binary item without a size")
+	}
+}
+
+func TestStorageBlobSize_SkipsTempFiles(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := WriteStorageBlob("blb", []byte("0123456789"), testKey); err != nil {
+		t.Fatalf("WriteStorageBlob failed: %v", err)
+	}
+	if size, err := StorageBlobSize("blb", testKey); err != nil || size != 10 {
+		t.Errorf("StorageBlobSize = %d, %v; want 10", size, err)
+	}
+
+	// Leftovers from an interrupted write are not items or blobs
+	itemsDir, _ := GetItemsDir()
+	storageDir, _ := GetStorageDir()
+	os.WriteFile(filepath.Join(itemsDir, "abc"+tmpFileExt), nil, 0600)
+	os.WriteFile(filepath.Join(storageDir, "blb"+tmpFileExt), nil, 0600)
+
+	if ids, _ := ListItemIDs(); len(ids) != 0 {
+		t.Errorf("ListItemIDs = %v, want none", ids)
+	}
+	if ids, _ := ListStorageIDs(); len(ids) != 1 || ids[0] != "blb" {
+		t.Errorf("ListStorageIDs = %v, want [blb]", ids)
+	}
+}
+
+func TestRewrite_Compression(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+	defer func() { Compression = false }()
+
+	noise := make([]byte, 2048)
+	rand.New(rand.NewSource(1)).Read(noise)
+	if err := CreateItem("log", NewTextItem("Log", string(bytes.Repeat([]byte("GET /health 200\n"), 500)), nil), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	if err := WriteStorageBlob("rnd", noise, testKey); err != nil {
+		t.Fatalf("WriteStorageBlob failed: %v", err)
+	}
+
+	compressed := func(path string) bool {
+		data, _ := os.ReadFile(path)
+		header, _ := crypto.ParseHeader(data)
+		return header.IsCompressed()
+	}
+	itemPath, _ := GetItemPath("log")
+	blobPath, _ := GetStoragePath("rnd")
+
+	Compression = true
+	if rewritten, err := RewriteItem("log", testKey); err != nil || !rewritten || !compressed(itemPath) {
+		t.Fatalf("RewriteItem = %v, %v; compressed %v", rewritten, err, compressed(itemPath))
+	}
+	// Incompressible blobs stay as they are, and don't count as rewritten
+	if rewritten, err := RewriteStorageBlob("rnd", testKey); err != nil || rewritten || compressed(blobPath) {
+		t.Errorf("RewriteStorageBlob = %v, %v; compressed %v", rewritten, err, compressed(blobPath))
+	}
+	if ids, _ := ListStorageIDs(); len(ids) != 1 {
+		t.Errorf("ListStorageIDs = %v, want only rnd", ids)
+	}
+	if item, err := ReadItem("log", testKey); err != nil || len(item.Content.Text) != 16*500 {
+		t.Errorf("compressed item did not read back: %v", err)
+	}
+
+	Compression = false
+	if rewritten, err := RewriteItem("log", testKey); err != nil || !rewritten || compressed(itemPath) {
+		t.Errorf("RewriteItem with compression off = %v, %v; compressed %v", rewritten, err, compressed(itemPath))
+	}
+}
@@ -1,10 +1,15 @@
 package storage
 
 import (
+	"bufio"
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"time"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+	"github.com/DeprecatedLuar/dredge-cargo/internal/secmem"
 )
 
 const (
@@ -98,8 +103,8 @@ func EnsureTrashDirectories() error {
 	return nil
 }
 
-// MoveToTrash moves an item to system trash and creates .trashinfo
-func MoveToTrash(id string) error {
+// MoveToTrash moves an item to system trash and creates .trashinfo (key updates the index)
+func MoveToTrash(id string, key []byte) error {
 	// Ensure trash directories exist
 	if err := EnsureTrashDirectories(); err != nil {
 		return fmt.Errorf("failed to ensure trash directories: %w", err)
@@ -152,11 +157,13 @@ func MoveToTrash(id string) error {
 		}
 	}
 
+	indexDelete(id, key)
+	updateVaultManifest(key, ManifestItemPath(id), ManifestBlobPath(id))
 	return nil
 }
 
-// RestoreFromTrash restores an item from trash back to items directory
-func RestoreFromTrash(id string) error {
+// RestoreFromTrash restores an item from trash back to items directory (key updates the manifest)
+func RestoreFromTrash(id string, key []byte) error {
 	// Get paths
 	itemPath, err := GetItemPath(id)
 	if err != nil {
@@ -205,5 +212,181 @@ func RestoreFromTrash(id string) error {
 		}
 	}
 
+	updateVaultManifest(key, ManifestItemPath(id), ManifestBlobPath(id))
 	return nil
 }
+
+// TrashEntry is an item of the active vault sitting in the system trash.
+type TrashEntry struct {
+	ID        string
+	DeletedAt time.Time // zero if the .trashinfo file is missing or unreadable
+	HasInfo   bool      // .trashinfo present (needed by undo)
+	HasBlob   bool      // storage blob trashed alongside
+}
+
+// ListTrash returns the active vault's items in the system trash. The trash is shared
+// by every vault, so entries are matched by the original path in their .trashinfo.
+func ListTrash() ([]TrashEntry, error) {
+	trashFilesDir, err := GetTrashFilesDir()
+	if err != nil {
+		return nil, err
+	}
+	itemsDir, err := GetItemsDir()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := os.ReadDir(trashFilesDir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read trash: %w", err)
+	}
+
+	var entries []TrashEntry
+	for _, f := range files {
+		name := f.Name()
+		if !strings.HasPrefix(name, trashItemPrefix) || strings.HasPrefix(name, trashStorageBlobPrefix) {
+			continue
+		}
+		id := strings.TrimPrefix(name, trashItemPrefix)
+
+		infoPath, err := GetTrashInfoPath(id)
+		if err != nil {
+			return nil, err
+		}
+		origPath, deletedAt, infoErr := readTrashInfo(infoPath)
+		if infoErr == nil && filepath.Dir(origPath) != itemsDir {
+			continue // belongs to another vault
+		}
+
+		entry := TrashEntry{ID: id, DeletedAt: deletedAt, HasInfo: infoErr == nil}
+		if blobPath, err := GetTrashStorageBlobPath(id); err == nil {
+			if _, err := os.Stat(blobPath); err == nil {
+				entry.HasBlob = true
+			}
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
+
+// readTrashInfo returns the original path and deletion date recorded in a .trashinfo file.
+func readTrashInfo(path string) (string, time.Time, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer f.Close()
+
+	var origPath string
+	var deletedAt time.Time
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if v, ok := strings.CutPrefix(line, "Path="); ok {
+			origPath = v
+		} else if v, ok := strings.CutPrefix(line, "DeletionDate="); ok {
+			deletedAt, _ = time.Parse(time.RFC3339, v)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", time.Time{}, err
+	}
+	if origPath == "" {
+		return "", time.Time{}, fmt.Errorf("no Path in %s", path)
+	}
+	return origPath, deletedAt, nil
+}
+
+// ReencryptTrash re-encrypts the active vault's trashed items and blobs from currentKey
+// to newKey (each via a temp file + rename), so they can still be restored after the
+// data key changes. The trash is local and not part of the vault swap, so a file that
+// fails is left as it was; the IDs of those are returned.
+func ReencryptTrash(currentKey, newKey []byte) ([]string, error) {
+	entries, err := ListTrash()
+	if err != nil {
+		return nil, err
+	}
+
+	var failed []string
+	for _, entry := range entries {
+		itemPath, err := GetTrashItemPath(entry.ID)
+		if err == nil {
+			err = reencryptTrashItem(itemPath, entry.ID, currentKey, newKey)
+		}
+		if err == nil && entry.HasBlob {
+			var blobPath string
+			if blobPath, err = GetTrashStorageBlobPath(entry.ID); err == nil {
+				err = reencryptTrashBlob(blobPath, entry.ID, currentKey, newKey)
+			}
+		}
+		if err != nil {
+			failed = append(failed, entry.ID)
+		}
+	}
+	return failed, nil
+}
+
+// reencryptTrashItem re-encrypts the trashed item file at path, bound to id.
+func reencryptTrashItem(path, id string, currentKey, newKey []byte) error {
+	encrypted, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	ad := crypto.AssociatedData(crypto.KindItem, id)
+	data, err := OpenPayload(encrypted, currentKey, ad)
+	if err != nil {
+		return err
+	}
+	defer secmem.Wipe(data)
+
+	sealed, err := SealPayload(data, newKey, ad)
+	if err != nil {
+		return err
+	}
+	return replaceFile(path, func(f *os.File) error {
+		_, err := f.Write(sealed)
+		return err
+	})
+}
+
+// reencryptTrashBlob streams the trashed blob at path into a new one under newKey.
+func reencryptTrashBlob(path, id string, currentKey, newKey []byte) error {
+	src, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	ad := crypto.AssociatedData(crypto.KindBlob, id)
+	r, err := OpenStream(src, currentKey, ad)
+	if err != nil {
+		return err
+	}
+	return replaceFile(path, func(f *os.File) error {
+		_, err := SealStream(f, r, newKey, ad)
+		return err
+	})
+}
+
+// replaceFile writes path anew through write, into a temp file renamed over it.
+func replaceFile(path string, write func(f *os.File) error) error {
+	tmpPath := path + tmpFileExt
+	f, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, itemFilePermissions)
+	if err != nil {
+		return err
+	}
+	err = write(f)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+	}
+	return err
+}
@@ -0,0 +1,54 @@
+package storage
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+)
+
+func TestReencryptTrash(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := CreateItem("txt", NewTextItem("Notes", "keep me", nil), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	blob := []byte("binary content")
+	if err := CreateItem("bin", NewBinaryItem("key.pem", "key.pem", int64(len(blob)), 0600, nil), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	if err := WriteStorageBlob("bin", blob, testKey); err != nil {
+		t.Fatalf("WriteStorageBlob failed: %v", err)
+	}
+	for _, id := range []string{"txt", "bin"} {
+		if err := MoveToTrash(id, testKey); err != nil {
+			t.Fatalf("MoveToTrash(%s) failed: %v", id, err)
+		}
+	}
+
+	// As after a rekey: the trash moves to the new key with the vault
+	newKey := crypto.DeriveKey("rekeyed", []byte("16-byte-salt-val"))
+	failed, err := ReencryptTrash(testKey, newKey)
+	if err != nil || len(failed) > 0 {
+		t.Fatalf("ReencryptTrash = %v, %v", failed, err)
+	}
+	if err := SealVaultManifest(newKey); err != nil {
+		t.Fatalf("SealVaultManifest failed: %v", err)
+	}
+
+	for _, id := range []string{"txt", "bin"} {
+		if err := RestoreFromTrash(id, newKey); err != nil {
+			t.Fatalf("RestoreFromTrash(%s) failed: %v", id, err)
+		}
+	}
+	if item, err := ReadItem("txt", newKey); err != nil || item.Content.Text != "keep me" {
+		t.Errorf("ReadItem(txt) after restore = %v, %v", item, err)
+	}
+	if data, err := ReadStorageBlob("bin", newKey); err != nil || !bytes.Equal(data, blob) {
+		t.Errorf("ReadStorageBlob(bin) after restore = %q, %v", data, err)
+	}
+	if _, err := ReadItem("txt", testKey); err == nil {
+		t.Error("the restored item should no longer open with the old key")
+	}
+}
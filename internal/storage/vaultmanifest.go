@@ -0,0 +1,494 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+)
+
+// ============================================================================
+// Vault manifest
+// ============================================================================
+//
+// Every file is authenticated on its own, so a remote could delete one, or serve an
+// older ciphertext of it, without failing decryption. .dredge-manifest lists the
+// SHA-256 of every file in items/ and storage/ with a counter that goes up on every
+// change, under a MAC keyed by the vault key, so only someone with the key can write
+// it. Each machine remembers the highest counter it has pulled or pushed (the high-water
+// mark) outside the repo: a manifest with a lower counter is a rollback, and a file that
+// does not match its entry was deleted, replaced or rolled back behind dredge's back.
+// Local writes don't raise the mark, so a teammate's push is never mistaken for a
+// rollback of changes that were only ever on this machine.
+
+// VaultManifestFileName is the manifest's file name in the vault (tracked by git).
+const VaultManifestFileName = ".dredge-manifest"
+
+const (
+	vaultManifestVersion = 1
+
+	xdgStateHomeEnv      = "XDG_STATE_HOME"
+	defaultStateDir      = "state"
+	highWaterMarkDirName = "manifest"
+)
+
+// VaultManifest is the authenticated list of the vault's files.
+type VaultManifest struct {
+	Version int               `json:"version"`
+	Counter uint64            `json:"counter"`
+	Files   map[string]string `json:"files"`           // "items/<id>" or "storage/<id>" → hex SHA-256 of the file
+	Bound   bool              `json:"bound,omitempty"` // every file is bound to its ID (see IsVaultBound)
+	MAC     string            `json:"mac"`
+}
+
+// ManifestCheck is the result of checking the manifest against the files on disk.
+type ManifestCheck struct {
+	Exists   bool     // false for vaults that have no manifest yet
+	Problems []string // empty when everything matches
+}
+
+// ManifestItemPath returns the manifest entry name of items/<id>.
+func ManifestItemPath(id string) string {
+	return itemsDirName + "/" + id
+}
+
+// ManifestBlobPath returns the manifest entry name of storage/<id>.
+func ManifestBlobPath(id string) string {
+	return storageDirName + "/" + id
+}
+
+// macInput is what the MAC covers: version, counter, the bound flag and every entry, in
+// a fixed order. The flag is only written when set, so manifests from before it still verify.
+func (m *VaultManifest) macInput() []byte {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "dredge-manifest\n%d\n%d\n", m.Version, m.Counter)
+	if m.Bound {
+		sb.WriteString("bound\n")
+	}
+	paths := make([]string, 0, len(m.Files))
+	for path := range m.Files {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	for _, path := range paths {
+		fmt.Fprintf(&sb, "%s %s\n", path, m.Files[path])
+	}
+	return []byte(sb.String())
+}
+
+// authentic reports whether the manifest's MAC is valid under key.
+func (m *VaultManifest) authentic(key []byte) bool {
+	mac, err := hex.DecodeString(m.MAC)
+	return err == nil && crypto.VerifyManifestMAC(key, m.macInput(), mac)
+}
+
+// getVaultManifestPath returns the path to .dredge-manifest
+func getVaultManifestPath() (string, error) {
+	dredgeDir, err := GetDredgeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dredgeDir, VaultManifestFileName), nil
+}
+
+// readVaultManifest parses .dredge-manifest. Returns nil (no error) if there is none yet.
+func readVaultManifest() (*VaultManifest, error) {
+	path, err := getVaultManifestPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read vault manifest: %w", err)
+	}
+
+	var m VaultManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return nil, fmt.Errorf("vault manifest is corrupted: %w", err)
+	}
+	if m.Version != vaultManifestVersion {
+		return nil, fmt.Errorf("unsupported vault manifest version %d", m.Version)
+	}
+	if m.Files == nil {
+		m.Files = make(map[string]string)
+	}
+	return &m, nil
+}
+
+// writeVaultManifest bumps the counter past everything seen so far, signs m and
+// writes it (temp file + rename).
+func writeVaultManifest(m *VaultManifest, key []byte) error {
+	defer forgetVaultBound()
+
+	m.Version = vaultManifestVersion
+	m.Counter = max(m.Counter, readHighWaterMark()) + 1
+
+	mac, err := crypto.ManifestMAC(key, m.macInput())
+	if err != nil {
+		return fmt.Errorf("failed to sign vault manifest: %w", err)
+	}
+	m.MAC = hex.EncodeToString(mac)
+
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode vault manifest: %w", err)
+	}
+	path, err := getVaultManifestPath()
+	if err != nil {
+		return err
+	}
+	tmpPath := path + tmpFileExt
+	if err := os.WriteFile(tmpPath, append(data, '\n'), gitignorePermissions); err != nil {
+		return fmt.Errorf("failed to write vault manifest: %w", err)
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to write vault manifest: %w", err)
+	}
+	return nil
+}
+
+// hashVaultFile returns the hex SHA-256 of a vault file, or "" if it does not exist.
+func hashVaultFile(path string) (string, error) {
+	dredgeDir, err := GetDredgeDir()
+	if err != nil {
+		return "", err
+	}
+	f, err := os.Open(filepath.Join(dredgeDir, filepath.FromSlash(path)))
+	if os.IsNotExist(err) {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// scanVaultFiles hashes every file in items/ and storage/.
+func scanVaultFiles() (map[string]string, error) {
+	itemIDs, err := ListItemIDs()
+	if err != nil {
+		return nil, err
+	}
+	blobIDs, err := ListStorageIDs()
+	if err != nil {
+		return nil, err
+	}
+
+	paths := make([]string, 0, len(itemIDs)+len(blobIDs))
+	for _, id := range itemIDs {
+		paths = append(paths, ManifestItemPath(id))
+	}
+	for _, id := range blobIDs {
+		paths = append(paths, ManifestBlobPath(id))
+	}
+
+	files := make(map[string]string, len(paths))
+	for _, path := range paths {
+		hash, err := hashVaultFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to hash %s: %w", path, err)
+		}
+		if hash != "" {
+			files[path] = hash
+		}
+	}
+	return files, nil
+}
+
+// SealVaultManifest rebuilds the manifest from the files on disk, accepting them as
+// they are. Used after re-encrypting the whole vault, and by fsck --repair. The vault
+// stays bound if the old manifest (authentic under key) said so, or if every file is.
+func SealVaultManifest(key []byte) error {
+	return sealVaultManifest(key, false)
+}
+
+// sealVaultManifest is SealVaultManifest; bound marks the vault bound regardless.
+func sealVaultManifest(key []byte, bound bool) error {
+	files, err := scanVaultFiles()
+	if err != nil {
+		return err
+	}
+	m := &VaultManifest{Files: files, Bound: bound}
+	if old, err := readVaultManifest(); err == nil && old != nil {
+		m.Counter = old.Counter
+		m.Bound = m.Bound || (old.Bound && old.authentic(key))
+	}
+	if !m.Bound {
+		if m.Bound, err = allFilesBound(files); err != nil {
+			return err
+		}
+	}
+	return writeVaultManifest(m, key)
+}
+
+// allFilesBound reports whether every file in files (manifest paths) is bound to its ID.
+func allFilesBound(files map[string]string) (bool, error) {
+	dredgeDir, err := GetDredgeDir()
+	if err != nil {
+		return false, err
+	}
+	for path := range files {
+		bound, err := isBoundFile(filepath.Join(dredgeDir, filepath.FromSlash(path)))
+		if err != nil || !bound {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+// markManifestBound sets the bound flag of the manifest (building one if there is none
+// yet). A manifest that fails its check is left alone, as in UpdateVaultManifest.
+func markManifestBound(key []byte) error {
+	m, err := readVaultManifest()
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return sealVaultManifest(key, true)
+	}
+	if !m.authentic(key) {
+		return fmt.Errorf("vault manifest failed authentication (run 'dredge fsck')")
+	}
+	if hwm := readHighWaterMark(); m.Counter < hwm {
+		return fmt.Errorf("vault manifest was rolled back (run 'dredge fsck')")
+	}
+	if m.Bound {
+		return nil
+	}
+	m.Bound = true
+	return writeVaultManifest(m, key)
+}
+
+// UpdateVaultManifest records the current content of the given files (manifest paths,
+// see ManifestItemPath), dropping those that no longer exist. A vault without a
+// manifest gets one built from every file. A manifest that fails its check is left
+// alone, so a tampered vault is never signed off by accident.
+func UpdateVaultManifest(key []byte, paths ...string) error {
+	m, err := readVaultManifest()
+	if err != nil {
+		return err
+	}
+	if m == nil {
+		return SealVaultManifest(key)
+	}
+	if !m.authentic(key) {
+		return fmt.Errorf("vault manifest failed authentication (run 'dredge fsck')")
+	}
+	if hwm := readHighWaterMark(); m.Counter < hwm {
+		return fmt.Errorf("vault manifest was rolled back (run 'dredge fsck')")
+	}
+
+	changed := false
+	for _, path := range paths {
+		hash, err := hashVaultFile(path)
+		if err != nil {
+			return fmt.Errorf("failed to hash %s: %w", path, err)
+		}
+		if hash == m.Files[path] {
+			continue
+		}
+		if hash == "" {
+			delete(m.Files, path)
+		} else {
+			m.Files[path] = hash
+		}
+		changed = true
+	}
+	if !changed {
+		return nil
+	}
+	return writeVaultManifest(m, key)
+}
+
+// ManifestVouchesFor reports whether the manifest is authentic and lists the file at
+// path (a manifest path) with its current content. Rewriting such a file in place can
+// carry the entry over without accepting anything the manifest did not already vouch for.
+func ManifestVouchesFor(key []byte, path string) bool {
+	m, err := readVaultManifest()
+	if err != nil || m == nil || !m.authentic(key) {
+		return false
+	}
+	hash, err := hashVaultFile(path)
+	return err == nil && hash != "" && m.Files[path] == hash
+}
+
+// updateVaultManifest is UpdateVaultManifest for the storage write paths: the files are
+// already written, so a failure is only a warning (fsck reports the mismatch).
+func updateVaultManifest(key []byte, paths ...string) {
+	if err := UpdateVaultManifest(key, paths...); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: vault manifest not updated: %v\n", err)
+	}
+}
+
+// CheckVaultManifest verifies the manifest and compares it with the files on disk.
+// Paths in exempt (local changes not yet in the manifest, e.g. after a pull) are not compared.
+func CheckVaultManifest(key []byte, exempt map[string]bool) (*ManifestCheck, error) {
+	m, err := readVaultManifest()
+	if err != nil {
+		return &ManifestCheck{Exists: true, Problems: []string{err.Error()}}, nil
+	}
+	if m == nil {
+		return &ManifestCheck{}, nil
+	}
+
+	check := &ManifestCheck{Exists: true}
+	if !m.authentic(key) {
+		check.Problems = append(check.Problems, "vault manifest failed authentication (tampered, or written without the vault key)")
+		return check, nil
+	}
+	if hwm := readHighWaterMark(); m.Counter < hwm {
+		check.Problems = append(check.Problems, fmt.Sprintf("vault manifest was rolled back: counter %d, this machine has seen %d", m.Counter, hwm))
+	}
+
+	files, err := scanVaultFiles()
+	if err != nil {
+		return nil, err
+	}
+	for _, path := range sortedKeys(m.Files) {
+		if exempt[path] {
+			continue
+		}
+		switch hash, ok := files[path]; {
+		case !ok:
+			check.Problems = append(check.Problems, fmt.Sprintf("%s is missing (deleted outside dredge?)", path))
+		case hash != m.Files[path]:
+			check.Problems = append(check.Problems, fmt.Sprintf("%s does not match the manifest (replaced or rolled back outside dredge?)", path))
+		}
+	}
+	for _, path := range sortedKeys(files) {
+		if _, ok := m.Files[path]; !ok && !exempt[path] {
+			check.Problems = append(check.Problems, fmt.Sprintf("%s is not in the manifest (added outside dredge?)", path))
+		}
+	}
+
+	return check, nil
+}
+
+// AcceptUpstreamManifest verifies the manifest as the remote has it (data is its
+// .dredge-manifest) and records its counter as the high-water mark. Fails when the
+// remote's manifest is not authentic or older than one this machine already saw.
+func AcceptUpstreamManifest(key, data []byte) error {
+	var m VaultManifest
+	if err := json.Unmarshal(data, &m); err != nil {
+		return fmt.Errorf("remote vault manifest is corrupted: %w", err)
+	}
+	if m.Version != vaultManifestVersion {
+		return fmt.Errorf("unsupported remote vault manifest version %d", m.Version)
+	}
+	if !m.authentic(key) {
+		return fmt.Errorf("remote vault manifest failed authentication (tampered, or written without the vault key)")
+	}
+	if hwm := readHighWaterMark(); m.Counter < hwm {
+		return fmt.Errorf("remote vault was rolled back: manifest counter %d, this machine has seen %d", m.Counter, hwm)
+	}
+	forgetVaultBound()
+	return raiseHighWaterMark(m.Counter)
+}
+
+// RecordPushedManifest raises the high-water mark to the counter of the manifest that
+// was just pushed, so the remote can't later serve anything older.
+func RecordPushedManifest() error {
+	m, err := readVaultManifest()
+	if err != nil || m == nil {
+		return err
+	}
+	return raiseHighWaterMark(m.Counter)
+}
+
+// HasSeenManifest reports whether this machine has pulled or pushed a vault manifest.
+// If it has, a remote without one has dropped it.
+func HasSeenManifest() bool {
+	return readHighWaterMark() > 0
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	slices.Sort(keys)
+	return keys
+}
+
+// ============================================================================
+// High-water mark
+// ============================================================================
+
+// getHighWaterMarkPath returns $XDG_STATE_HOME/dredge/manifest/<vaulthash>: outside the
+// repo, so whoever controls the remote cannot reset it.
+func getHighWaterMarkPath() (string, error) {
+	return getVaultStatePath(highWaterMarkDirName)
+}
+
+// getVaultStatePath returns $XDG_STATE_HOME/dredge/<dirName>/<vaulthash>, where this
+// machine keeps what it knows about the active vault.
+func getVaultStatePath(dirName string) (string, error) {
+	baseDir := os.Getenv(xdgStateHomeEnv)
+	if baseDir == "" {
+		homeDir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("failed to get home directory: %w", err)
+		}
+		baseDir = filepath.Join(homeDir, defaultLocalDir, defaultStateDir)
+	}
+
+	dredgeDir, err := GetDredgeDir()
+	if err != nil {
+		return "", err
+	}
+	if abs, err := filepath.Abs(dredgeDir); err == nil {
+		dredgeDir = abs
+	}
+	sum := sha256.Sum256([]byte(dredgeDir))
+	return filepath.Join(baseDir, appName, dirName, hex.EncodeToString(sum[:8])), nil
+}
+
+// readHighWaterMark returns the highest manifest counter seen for this vault (0 if none).
+func readHighWaterMark() uint64 {
+	path, err := getHighWaterMarkPath()
+	if err != nil {
+		return 0
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0
+	}
+	n, _ := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	return n
+}
+
+// raiseHighWaterMark records counter unless a higher one was already seen.
+func raiseHighWaterMark(counter uint64) error {
+	if counter <= readHighWaterMark() {
+		return nil
+	}
+	path, err := getHighWaterMarkPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), dirPermissions); err != nil {
+		return fmt.Errorf("failed to save manifest high-water mark: %w", err)
+	}
+	if err := os.WriteFile(path, []byte(strconv.FormatUint(counter, 10)+"\n"), itemFilePermissions); err != nil {
+		return fmt.Errorf("failed to save manifest high-water mark: %w", err)
+	}
+	return nil
+}
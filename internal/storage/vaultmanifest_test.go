@@ -0,0 +1,154 @@
+package storage
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/crypto"
+)
+
+func manifestProblems(t *testing.T, exempt map[string]bool) []string {
+	t.Helper()
+	check, err := CheckVaultManifest(testKey, exempt)
+	if err != nil {
+		t.Fatalf("CheckVaultManifest failed: %v", err)
+	}
+	if !check.Exists {
+		t.Fatal("vault has no manifest")
+	}
+	return check.Problems
+}
+
+func TestVaultManifest_FollowsMutations(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := CreateItem("aaa", NewTextItem("One", "1", nil), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	if err := CreateItem("bbb", NewBinaryItem("Two", "two.bin", 3, 0600, nil), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	if err := WriteStorageBlob("bbb", []byte("two"), testKey); err != nil {
+		t.Fatalf("WriteStorageBlob failed: %v", err)
+	}
+	item, _ := ReadItem("aaa", testKey)
+	item.Content.Text = "uno"
+	if err := UpdateItem("aaa", item, testKey); err != nil {
+		t.Fatalf("UpdateItem failed: %v", err)
+	}
+	if err := MoveItem("bbb", "ccc", testKey); err != nil {
+		t.Fatalf("MoveItem failed: %v", err)
+	}
+	if err := DeleteItem("aaa", testKey); err != nil {
+		t.Fatalf("DeleteItem failed: %v", err)
+	}
+
+	if problems := manifestProblems(t, nil); len(problems) != 0 {
+		t.Fatalf("problems after ordinary changes: %v", problems)
+	}
+	m, _ := readVaultManifest()
+	if len(m.Files) != 2 || m.Files[ManifestItemPath("ccc")] == "" || m.Files[ManifestBlobPath("ccc")] == "" {
+		t.Errorf("manifest files = %v, want items/ccc and storage/ccc", m.Files)
+	}
+}
+
+func TestVaultManifest_DetectsTampering(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	for _, id := range []string{"aaa", "bbb"} {
+		if err := CreateItem(id, NewTextItem(id, "secret", nil), testKey); err != nil {
+			t.Fatalf("CreateItem failed: %v", err)
+		}
+	}
+	oldCiphertext, _ := os.ReadFile(mustItemPath(t, "aaa"))
+	item, _ := ReadItem("aaa", testKey)
+	item.Content.Text = "rotated"
+	if err := UpdateItem("aaa", item, testKey); err != nil {
+		t.Fatalf("UpdateItem failed: %v", err)
+	}
+
+	// An older, perfectly valid ciphertext and a deleted item
+	if err := os.WriteFile(mustItemPath(t, "aaa"), oldCiphertext, 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Remove(mustItemPath(t, "bbb")); err != nil {
+		t.Fatal(err)
+	}
+
+	problems := strings.Join(manifestProblems(t, nil), "\n")
+	if !strings.Contains(problems, "items/aaa does not match") || !strings.Contains(problems, "items/bbb is missing") {
+		t.Errorf("problems = %q, want aaa modified and bbb missing", problems)
+	}
+	if problems := manifestProblems(t, map[string]bool{"items/aaa": true, "items/bbb": true}); len(problems) != 0 {
+		t.Errorf("exempt paths still reported: %v", problems)
+	}
+
+	// Tampered manifests are refused and never re-signed by a write
+	if err := UpdateVaultManifest(crypto.DeriveKey("other", []byte("16-byte-salt-val")), "items/aaa"); err == nil {
+		t.Error("UpdateVaultManifest accepted a manifest signed with another key")
+	}
+}
+
+func TestVaultManifest_Rollback(t *testing.T) {
+	cleanup := setupTestEnv(t)
+	defer cleanup()
+
+	if err := CreateItem("aaa", NewTextItem("One", "1", nil), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	old, err := os.ReadFile(mustManifestPath(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateItem("bbb", NewTextItem("Two", "2", nil), testKey); err != nil {
+		t.Fatalf("CreateItem failed: %v", err)
+	}
+	current, _ := os.ReadFile(mustManifestPath(t))
+
+	if err := AcceptUpstreamManifest(testKey, current); err != nil {
+		t.Fatalf("AcceptUpstreamManifest(current) failed: %v", err)
+	}
+	if err := AcceptUpstreamManifest(testKey, old); err == nil || !strings.Contains(err.Error(), "rolled back") {
+		t.Errorf("AcceptUpstreamManifest(old) = %v, want rollback", err)
+	}
+
+	// The whole vault rolled back, manifest included, is still caught
+	if err := os.WriteFile(mustManifestPath(t), old, 0644); err != nil {
+		t.Fatal(err)
+	}
+	_ = os.Remove(mustItemPath(t, "bbb"))
+	problems := strings.Join(manifestProblems(t, nil), "\n")
+	if !strings.Contains(problems, "rolled back") {
+		t.Errorf("problems = %q, want rollback", problems)
+	}
+
+	// Local writes never raise the high-water mark on their own
+	if err := SealVaultManifest(testKey); err != nil {
+		t.Fatalf("SealVaultManifest failed: %v", err)
+	}
+	if got := readHighWaterMark(); got != 2 {
+		t.Errorf("high-water mark = %d, want 2", got)
+	}
+}
+
+func mustItemPath(t *testing.T, id string) string {
+	t.Helper()
+	path, err := GetItemPath(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func mustManifestPath(t *testing.T) string {
+	t.Helper()
+	dir, err := GetDredgeDir()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return filepath.Join(dir, VaultManifestFileName)
+}
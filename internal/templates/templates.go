@@ -0,0 +1,113 @@
+// Package templates holds the item templates 'dredge add --template' starts from:
+// built-in ones for common kinds of secrets, and the user's own, which are items tagged
+// with Tag (so they are encrypted and synced like any other item).
+package templates
+
+import (
+	"slices"
+	"strings"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+)
+
+// Tag marks an item as a user template. The item's title is the template name; its
+// other tags, fields (values included) and content are what new items start with.
+const Tag = "template"
+
+// Template is a starting point for a new item.
+type Template struct {
+	Name    string
+	Tags    []string
+	Fields  []storage.Field
+	Content string
+}
+
+func text(name string) storage.Field   { return storage.Field{Name: name, Type: storage.FieldText} }
+func secret(name string) storage.Field { return storage.Field{Name: name, Type: storage.FieldSecret} }
+func otp(name string) storage.Field    { return storage.Field{Name: name, Type: storage.FieldOTP} }
+
+// Builtin lists the templates every vault has. A user template of the same name wins.
+var Builtin = []Template{
+	{
+		Name:   "login",
+		Tags:   []string{"login"},
+		Fields: []storage.Field{text("username"), secret("password"), text("url"), otp("otp")},
+	},
+	{
+		Name:   "api-key",
+		Tags:   []string{"api"},
+		Fields: []storage.Field{secret("key"), secret("secret"), text("account"), text("url")},
+	},
+	{
+		Name:   "ssh",
+		Tags:   []string{"ssh"},
+		Fields: []storage.Field{text("host"), text("user"), secret("passphrase"), text("public_key")},
+	},
+	{
+		Name:   "database",
+		Tags:   []string{"db"},
+		Fields: []storage.Field{secret("dsn"), text("host"), text("port"), text("database"), text("username"), secret("password")},
+	},
+	{
+		Name:   "server",
+		Tags:   []string{"server"},
+		Fields: []storage.Field{text("host"), text("port"), text("username"), secret("password"), text("url")},
+	},
+	{
+		Name:   "wifi",
+		Tags:   []string{"wifi"},
+		Fields: []storage.Field{text("ssid"), secret("password"), text("security")},
+	},
+}
+
+// LookupBuiltin returns the built-in template called name (any case), or nil.
+func LookupBuiltin(name string) *Template {
+	for k := range Builtin {
+		if strings.EqualFold(Builtin[k].Name, name) {
+			return &Builtin[k]
+		}
+	}
+	return nil
+}
+
+// IsTemplate reports whether tags mark an item as a user template.
+func IsTemplate(tags []string) bool {
+	return slices.Contains(tags, Tag)
+}
+
+// FromItem turns a user template item (opened, if double-locked) into a Template.
+func FromItem(item *storage.Item) *Template {
+	var tags []string
+	for _, tag := range item.Tags {
+		if tag != Tag {
+			tags = append(tags, tag)
+		}
+	}
+	return &Template{
+		Name:    item.Title,
+		Tags:    tags,
+		Fields:  slices.Clone(item.Content.Fields),
+		Content: item.Content.Text,
+	}
+}
+
+// Apply returns what a new item made from t starts with: t's tags followed by the
+// given ones (each once), and copies of t's fields and content.
+func (t *Template) Apply(tags []string) ([]string, []storage.Field, string) {
+	var merged []string
+	for _, tag := range append(slices.Clone(t.Tags), tags...) {
+		if !slices.Contains(merged, tag) {
+			merged = append(merged, tag)
+		}
+	}
+	return merged, slices.Clone(t.Fields), t.Content
+}
+
+// Names lists the built-in template names.
+func Names() []string {
+	names := make([]string, len(Builtin))
+	for k, t := range Builtin {
+		names[k] = t.Name
+	}
+	return names
+}
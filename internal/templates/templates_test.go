@@ -0,0 +1,61 @@
+package templates
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/storage"
+)
+
+func TestBuiltin_Valid(t *testing.T) {
+	for _, want := range []string{"login", "api-key", "ssh", "database", "server", "wifi"} {
+		tmpl := LookupBuiltin(want)
+		if tmpl == nil {
+			t.Errorf("no built-in template %q", want)
+			continue
+		}
+		if err := storage.ValidateFields(tmpl.Fields); err != nil {
+			t.Errorf("template %s: %v", want, err)
+		}
+		if IsTemplate(tmpl.Tags) {
+			t.Errorf("template %s would make template items", want)
+		}
+	}
+	if LookupBuiltin("LOGIN") == nil || LookupBuiltin("nope") != nil {
+		t.Error("LookupBuiltin should match names in any case, and only those")
+	}
+}
+
+func TestApply(t *testing.T) {
+	login := LookupBuiltin("login")
+	tags, fields, _ := login.Apply([]string{"work", "login"})
+	if !slices.Equal(tags, []string{"login", "work"}) {
+		t.Errorf("tags = %v, want [login work]", tags)
+	}
+
+	// New items get their own copy of the fields
+	fields[0].Value = "luar"
+	if login.Fields[0].Value != "" {
+		t.Error("Apply should not share fields with the template")
+	}
+}
+
+func TestFromItem(t *testing.T) {
+	item := storage.NewTextItem("aws", "MFA on the root account", []string{"template", "aws", "login"})
+	item.Content.Fields = []storage.Field{
+		{Name: "username", Type: storage.FieldText},
+		{Name: "url", Type: storage.FieldText, Value: "https://console.aws.amazon.com"},
+	}
+	if !IsTemplate(item.Tags) {
+		t.Fatal("IsTemplate should see the reserved tag")
+	}
+
+	tmpl := FromItem(item)
+	tags, fields, content := tmpl.Apply(nil)
+	if tmpl.Name != "aws" || !slices.Equal(tags, []string{"aws", "login"}) {
+		t.Errorf("template %q tags = %v, want the item's tags without %q", tmpl.Name, tags, Tag)
+	}
+	if !slices.Equal(fields, item.Content.Fields) || content != item.Content.Text {
+		t.Errorf("fields/content = %+v / %q", fields, content)
+	}
+}
@@ -0,0 +1,177 @@
+// Package totp computes RFC 6238 time-based one-time passwords from otpauth:// URIs
+// or bare base32 seeds.
+package totp
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Algorithm is the HMAC hash a key uses.
+type Algorithm string
+
+const (
+	SHA1   Algorithm = "SHA1"
+	SHA256 Algorithm = "SHA256"
+	SHA512 Algorithm = "SHA512"
+)
+
+const (
+	DefaultDigits = 6
+	DefaultPeriod = 30 // seconds
+
+	minDigits = 6
+	maxDigits = 10 // the truncated value has 31 bits
+)
+
+// Key is a TOTP secret with its parameters.
+type Key struct {
+	Secret    []byte
+	Algorithm Algorithm
+	Digits    int
+	Period    int // seconds
+
+	// From the URI label and parameters (empty for bare seeds)
+	Issuer  string
+	Account string
+}
+
+// Parse reads an otpauth://totp/ URI, or a base32 seed (spaces and dashes allowed,
+// any case) which gets the usual SHA1, 6 digits and 30 seconds.
+func Parse(s string) (*Key, error) {
+	s = strings.TrimSpace(s)
+	if strings.HasPrefix(strings.ToLower(s), "otpauth:") {
+		return parseURI(s)
+	}
+	secret, err := decodeSeed(s)
+	if err != nil {
+		return nil, err
+	}
+	return &Key{Secret: secret, Algorithm: SHA1, Digits: DefaultDigits, Period: DefaultPeriod}, nil
+}
+
+func parseURI(s string) (*Key, error) {
+	u, err := url.Parse(s)
+	if err != nil {
+		return nil, fmt.Errorf("invalid otpauth URI: %w", err)
+	}
+	switch strings.ToLower(u.Host) {
+	case "totp":
+	case "hotp":
+		return nil, fmt.Errorf("counter-based (hotp) codes are not supported, only totp")
+	default:
+		return nil, fmt.Errorf("invalid otpauth URI: unknown type %q", u.Host)
+	}
+
+	q := u.Query()
+	if q.Get("secret") == "" {
+		return nil, fmt.Errorf("invalid otpauth URI: no secret")
+	}
+	secret, err := decodeSeed(q.Get("secret"))
+	if err != nil {
+		return nil, err
+	}
+	key := &Key{Secret: secret, Algorithm: SHA1, Digits: DefaultDigits, Period: DefaultPeriod}
+
+	if a := q.Get("algorithm"); a != "" {
+		switch alg := Algorithm(strings.ToUpper(a)); alg {
+		case SHA1, SHA256, SHA512:
+			key.Algorithm = alg
+		default:
+			return nil, fmt.Errorf("unsupported algorithm %q (use SHA1, SHA256 or SHA512)", a)
+		}
+	}
+	if d := q.Get("digits"); d != "" {
+		n, err := strconv.Atoi(d)
+		if err != nil || n < minDigits || n > maxDigits {
+			return nil, fmt.Errorf("invalid digits %q (use %d to %d)", d, minDigits, maxDigits)
+		}
+		key.Digits = n
+	}
+	if p := q.Get("period"); p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid period %q (seconds, above 0)", p)
+		}
+		key.Period = n
+	}
+
+	// Label is "issuer:account" or "account"; the issuer parameter wins
+	label := strings.TrimPrefix(u.Path, "/")
+	if issuer, account, ok := strings.Cut(label, ":"); ok {
+		key.Issuer, key.Account = strings.TrimSpace(issuer), strings.TrimSpace(account)
+	} else {
+		key.Account = strings.TrimSpace(label)
+	}
+	if issuer := q.Get("issuer"); issuer != "" {
+		key.Issuer = issuer
+	}
+	return key, nil
+}
+
+// decodeSeed decodes a base32 seed, with or without padding.
+func decodeSeed(s string) ([]byte, error) {
+	s = strings.ToUpper(strings.NewReplacer(" ", "", "-", "", "=", "").Replace(s))
+	secret, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err != nil || len(secret) == 0 {
+		return nil, fmt.Errorf("invalid seed: not base32")
+	}
+	return secret, nil
+}
+
+// Code returns the code for time t.
+func (k *Key) Code(t time.Time) string {
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix())/uint64(k.Period))
+
+	mac := hmac.New(k.hash(), k.Secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	// Dynamic truncation (RFC 4226 section 5.3)
+	offset := sum[len(sum)-1] & 0x0f
+	value := uint64(binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff)
+
+	mod := uint64(1)
+	for i := 0; i < k.Digits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", k.Digits, value%mod)
+}
+
+// Remaining returns how long the code for time t stays valid.
+func (k *Key) Remaining(t time.Time) time.Duration {
+	period := int64(k.Period)
+	return time.Duration(period-t.Unix()%period) * time.Second
+}
+
+// Label names the key for people: "issuer (account)", either one alone, or "".
+func (k *Key) Label() string {
+	switch {
+	case k.Issuer != "" && k.Account != "":
+		return fmt.Sprintf("%s (%s)", k.Issuer, k.Account)
+	case k.Issuer != "":
+		return k.Issuer
+	}
+	return k.Account
+}
+
+func (k *Key) hash() func() hash.Hash {
+	switch k.Algorithm {
+	case SHA256:
+		return sha256.New
+	case SHA512:
+		return sha512.New
+	}
+	return sha1.New
+}
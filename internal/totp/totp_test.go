@@ -0,0 +1,80 @@
+package totp
+
+import (
+	"encoding/base32"
+	"fmt"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B
+func TestCode_RFC6238(t *testing.T) {
+	seeds := map[Algorithm]string{
+		SHA1:   "12345678901234567890",
+		SHA256: "12345678901234567890123456789012",
+		SHA512: "1234567890123456789012345678901234567890123456789012345678901234",
+	}
+	tests := []struct {
+		unix int64
+		want map[Algorithm]string
+	}{
+		{59, map[Algorithm]string{SHA1: "94287082", SHA256: "46119246", SHA512: "90693936"}},
+		{1111111109, map[Algorithm]string{SHA1: "07081804", SHA256: "68084774", SHA512: "25091201"}},
+		{1111111111, map[Algorithm]string{SHA1: "14050471", SHA256: "67062674", SHA512: "99943326"}},
+		{1234567890, map[Algorithm]string{SHA1: "89005924", SHA256: "91819424", SHA512: "93441116"}},
+		{2000000000, map[Algorithm]string{SHA1: "69279037", SHA256: "90698825", SHA512: "38618901"}},
+		{20000000000, map[Algorithm]string{SHA1: "65353130", SHA256: "77737706", SHA512: "47863826"}},
+	}
+
+	for alg, seed := range seeds {
+		secret := base32.StdEncoding.EncodeToString([]byte(seed))
+		key, err := Parse(fmt.Sprintf("otpauth://totp/Example:alice?secret=%s&algorithm=%s&digits=8", secret, alg))
+		if err != nil {
+			t.Fatalf("Parse(%s) failed: %v", alg, err)
+		}
+		for _, tt := range tests {
+			if got := key.Code(time.Unix(tt.unix, 0)); got != tt.want[alg] {
+				t.Errorf("%s at %d = %s, want %s", alg, tt.unix, got, tt.want[alg])
+			}
+		}
+	}
+}
+
+func TestParse(t *testing.T) {
+	key, err := Parse("otpauth://totp/ACME%20Co:john.doe@email.com?secret=HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ&issuer=ACME%20Co&period=60&digits=7")
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if key.Issuer != "ACME Co" || key.Account != "john.doe@email.com" || key.Label() != "ACME Co (john.doe@email.com)" {
+		t.Errorf("label = %q / %q", key.Issuer, key.Account)
+	}
+	if key.Algorithm != SHA1 || key.Digits != 7 || key.Period != 60 {
+		t.Errorf("params = %s %d %d", key.Algorithm, key.Digits, key.Period)
+	}
+	if got := key.Remaining(time.Unix(130, 0)); got != 50*time.Second {
+		t.Errorf("Remaining = %v, want 50s", got)
+	}
+
+	// Bare seeds: any case, spaces, no padding
+	seed, err := Parse("hxdm vjec jjws rb3h wizr 4ifu gftm xboz")
+	if err != nil {
+		t.Fatalf("Parse(seed) failed: %v", err)
+	}
+	if string(seed.Secret) != string(key.Secret) || seed.Digits != DefaultDigits || seed.Period != DefaultPeriod {
+		t.Errorf("seed = %+v", seed)
+	}
+
+	for _, bad := range []string{
+		"",
+		"not base32!",
+		"otpauth://hotp/x?secret=GEZDGNBV&counter=1",
+		"otpauth://totp/x",
+		"otpauth://totp/x?secret=GEZDGNBV&algorithm=MD5",
+		"otpauth://totp/x?secret=GEZDGNBV&digits=4",
+		"otpauth://totp/x?secret=GEZDGNBV&period=0",
+	} {
+		if _, err := Parse(bad); err == nil {
+			t.Errorf("Parse(%q) should fail", bad)
+		}
+	}
+}
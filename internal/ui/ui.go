@@ -3,17 +3,24 @@ package ui
 // #132b21 #234133 #131e22 #82543a #623d34 #31201c #240c16 #fdffdf
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 
 	"golang.org/x/term"
+
+	"github.com/DeprecatedLuar/dredge-cargo/internal/secmem"
 )
 
 // Color constants
 const (
-	ColorTag   = "\033[38;2;128;128;128m" // Muted gray for tags
-	ColorReset = "\033[0m"                // Reset to default
+	ColorTag     = "\033[38;2;128;128;128m" // Muted gray for tags
+	ColorRemoved = "\033[31m"               // Red for removed diff lines
+	ColorAdded   = "\033[32m"               // Green for added diff lines
+	ColorReset   = "\033[0m"                // Reset to default
 
 	StyleStrikethrough = "\033[9m"  // Strikethrough text
 	StyleReset         = "\033[29m" // Reset strikethrough
@@ -43,10 +50,27 @@ func PromptPasswordCustom(prompt string) (string, error) {
 	if err != nil {
 		return "", fmt.Errorf("failed to read password: %w", err)
 	}
+	defer secmem.Wipe(password)
 
 	return strings.TrimSpace(string(password)), nil
 }
 
+// PromptSecret is PromptPasswordCustom that returns the password in a secure buffer
+// instead of a string, which could not be wiped. The caller destroys it.
+func PromptSecret(prompt string) (*secmem.Buffer, error) {
+	fmt.Fprint(os.Stderr, prompt)
+
+	password, err := term.ReadPassword(int(os.Stdin.Fd()))
+	fmt.Fprintln(os.Stderr)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to read password: %w", err)
+	}
+	defer secmem.Wipe(password)
+
+	return secmem.From(bytes.TrimSpace(password)), nil
+}
+
 // PromptPasswordWithConfirmation prompts twice for password confirmation.
 func PromptPasswordWithConfirmation() (string, error) {
 	return PromptPasswordWithConfirmationCustom("Enter password: ", "Confirm password: ")
@@ -70,6 +94,8 @@ func PromptPasswordWithConfirmationCustom(prompt1, prompt2 string) (string, erro
 
 	pwd1 := strings.TrimSpace(string(password1))
 	pwd2 := strings.TrimSpace(string(password2))
+	secmem.Wipe(password1)
+	secmem.Wipe(password2)
 
 	if pwd1 != pwd2 {
 		return "", fmt.Errorf("passwords do not match")
@@ -82,6 +108,18 @@ func PromptPasswordWithConfirmationCustom(prompt1, prompt2 string) (string, erro
 	return pwd1, nil
 }
 
+// PromptLine prompts for a single line of visible input (e.g. a yes/no answer).
+func PromptLine(prompt string) (string, error) {
+	fmt.Fprint(os.Stderr, prompt)
+
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", fmt.Errorf("failed to read input: %w", err)
+	}
+
+	return strings.TrimSpace(line), nil
+}
+
 // ============================================================================
 // Terminal Utilities
 // ============================================================================
@@ -0,0 +1,33 @@
+// Package wordlist provides the BIP-39 English word list (2048 words, 11 bits each).
+// Used for recovery key mnemonics and generated passphrases.
+package wordlist
+
+import (
+	_ "embed"
+	"strings"
+)
+
+//go:embed english.txt
+var englishTxt string
+
+// Size is the number of words in the list.
+const Size = 2048
+
+// English is the BIP-39 English word list in canonical order.
+var English = strings.Fields(englishTxt)
+
+var index = func() map[string]int {
+	m := make(map[string]int, len(English))
+	for i, w := range English {
+		m[w] = i
+	}
+	return m
+}()
+
+// Index returns the position of word in the list, or -1 if it is not a list word.
+func Index(word string) int {
+	if i, ok := index[strings.ToLower(word)]; ok {
+		return i
+	}
+	return -1
+}
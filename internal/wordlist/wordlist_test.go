@@ -0,0 +1,15 @@
+package wordlist
+
+import "testing"
+
+func TestEnglish(t *testing.T) {
+	if len(English) != Size {
+		t.Fatalf("len(English) = %d, want %d", len(English), Size)
+	}
+	if English[0] != "abandon" || English[Size-1] != "zoo" {
+		t.Errorf("unexpected list bounds: %q ... %q", English[0], English[Size-1])
+	}
+	if Index("Zoo") != Size-1 || Index("notaword") != -1 {
+		t.Error("Index lookup mismatch")
+	}
+}